@@ -0,0 +1,353 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"epub-translator/internal/config"
+	"epub-translator/internal/translation"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var batchCmd = &cobra.Command{
+	Use:   "batch <directory|manifest>",
+	Short: "Translate a directory or manifest of EPUB files into several languages",
+	Long: `Batch translates every book in a directory (into the languages given with --to) or every
+book→language pair listed in a JSON or YAML manifest. Books are translated with bounded
+concurrency and a summary report is written after each pair finishes. Re-running the same
+batch skips the pairs the report already lists as completed.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runBatch,
+}
+
+func init() {
+	batchCmd.Flags().StringSlice("to", nil, "Target language codes for directory mode (e.g. fa,de)")
+	batchCmd.Flags().String("from", "", "Source language code (detected per book if empty)")
+	batchCmd.Flags().String("provider", "", "Translation provider for books that do not set one (default: translation.provider)")
+	batchCmd.Flags().String("out", "", "Directory for the translated EPUBs (default: configured output directory)")
+	batchCmd.Flags().String("glossary", "", "CSV or JSON glossary used instead of glossary.path")
+	batchCmd.Flags().Int("concurrency", 2, "Number of books translated at the same time")
+	batchCmd.Flags().String("report", "", "Summary report path (default: batch-report.json in the output directory)")
+
+	rootCmd.AddCommand(batchCmd)
+}
+
+// batchManifest lists the books of a batch and the languages to translate each into.
+type batchManifest struct {
+	Books []struct {
+		Path      string   `json:"path" yaml:"path"`
+		Source    string   `json:"source" yaml:"source"`
+		Provider  string   `json:"provider" yaml:"provider"`
+		Languages []string `json:"languages" yaml:"languages"`
+	} `json:"books" yaml:"books"`
+}
+
+// batchItem is one book→language pair of a batch and its outcome.
+type batchItem struct {
+	Book        string    `json:"book"`
+	SourceLang  string    `json:"source_language,omitempty"`
+	TargetLang  string    `json:"target_language"`
+	Provider    string    `json:"provider,omitempty"`
+	Status      string    `json:"status"` // pending, completed, failed
+	Output      string    `json:"output,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	Duration    string    `json:"duration,omitempty"`
+	CompletedAt time.Time `json:"completed_at,omitempty"`
+}
+
+// batchReport is written to disk after every finished pair so an interrupted
+// batch can be resumed.
+type batchReport struct {
+	StartedAt  time.Time    `json:"started_at"`
+	FinishedAt time.Time    `json:"finished_at,omitempty"`
+	Completed  int          `json:"completed"`
+	Failed     int          `json:"failed"`
+	Pending    int          `json:"pending"`
+	Items      []*batchItem `json:"items"`
+}
+
+func runBatch(cmd *cobra.Command, args []string) {
+	cfg, err := loadConfig(cmd)
+	if err != nil {
+		logger.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	setupLogging(cmd)
+
+	targetLangs, _ := cmd.Flags().GetStringSlice("to")
+	sourceLang, _ := cmd.Flags().GetString("from")
+	providerName, _ := cmd.Flags().GetString("provider")
+	concurrency, _ := cmd.Flags().GetInt("concurrency")
+	outputDir, _ := cmd.Flags().GetString("out")
+	if outputDir == "" {
+		outputDir = cfg.App.OutputDir
+	}
+	if glossaryPath, _ := cmd.Flags().GetString("glossary"); glossaryPath != "" {
+		cfg.Glossary.Path = glossaryPath
+	}
+	reportPath, _ := cmd.Flags().GetString("report")
+	if reportPath == "" {
+		reportPath = filepath.Join(outputDir, "batch-report.json")
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	items, err := loadBatchItems(args[0], sourceLang, providerName, targetLangs)
+	if err != nil {
+		logger.Fatalf("Failed to read batch: %v", err)
+	}
+	if len(items) == 0 {
+		logger.Fatal("Batch is empty: no EPUB files or target languages found")
+	}
+
+	if err := os.MkdirAll(cfg.App.TempDir, 0755); err != nil {
+		logger.Fatalf("Failed to create temp directory: %v", err)
+	}
+
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		logger.Fatalf("Failed to create output directory: %v", err)
+	}
+
+	report := &batchReport{StartedAt: time.Now(), Items: items}
+	resumed := report.resumeFrom(reportPath)
+	if resumed > 0 {
+		fmt.Printf("⏭️  Skipping %d pairs already completed in %s\n", resumed, reportPath)
+	}
+
+	svc, err := translation.NewService(cfg, logger, nil)
+	if err != nil {
+		logger.Fatalf("Failed to create translation service: %v", err)
+	}
+	defer func() { _ = svc.Close() }()
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	runBatchItems(ctx, cfg, svc, report, reportPath, outputDir, concurrency)
+
+	report.FinishedAt = time.Now()
+	report.tally()
+	if err := report.save(reportPath); err != nil {
+		logger.Errorf("Failed to write batch report: %v", err)
+	}
+
+	fmt.Printf("\n📋 Batch finished: %d completed, %d failed (report: %s)\n", report.Completed, report.Failed, reportPath)
+	if report.Failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// runBatchItems translates every pending item with at most concurrency books
+// in flight, saving the report after each one finishes. Once ctx is cancelled
+// no further items are started.
+func runBatchItems(ctx context.Context, cfg *config.Config, svc *translation.Service, report *batchReport, reportPath, outputDir string, concurrency int) {
+	var (
+		wg       sync.WaitGroup
+		reportMu sync.Mutex
+		sem      = make(chan struct{}, concurrency)
+	)
+
+	for _, item := range report.Items {
+		if item.Status == "completed" {
+			continue
+		}
+
+		sem <- struct{}{}
+		if ctx.Err() != nil {
+			<-sem
+			break
+		}
+
+		wg.Add(1)
+		go func(item *batchItem) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			fmt.Printf("▶️  %s → %s\n", filepath.Base(item.Book), item.TargetLang)
+			start := time.Now()
+			outputPath, err := translateFile(ctx, cfg, svc, item.Book, "", item.SourceLang, item.TargetLang, item.Provider, outputDir)
+
+			reportMu.Lock()
+			defer reportMu.Unlock()
+
+			item.Duration = time.Since(start).Round(time.Second).String()
+			if err != nil {
+				item.Status = "failed"
+				item.Error = err.Error()
+				fmt.Printf("❌ %s → %s: %v\n", filepath.Base(item.Book), item.TargetLang, err)
+			} else {
+				item.Status = "completed"
+				item.Error = ""
+				item.Output = outputPath
+				item.CompletedAt = time.Now()
+				fmt.Printf("✅ %s → %s: %s\n", filepath.Base(item.Book), item.TargetLang, outputPath)
+			}
+
+			report.tally()
+			if err := report.save(reportPath); err != nil {
+				logger.Errorf("Failed to write batch report: %v", err)
+			}
+		}(item)
+	}
+
+	wg.Wait()
+}
+
+// loadBatchItems expands a directory of EPUB files or a manifest into pairs.
+func loadBatchItems(source, sourceLang, providerName string, targetLangs []string) ([]*batchItem, error) {
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []*batchItem
+
+	if info.IsDir() {
+		if len(targetLangs) == 0 {
+			return nil, fmt.Errorf("--to is required when translating a directory")
+		}
+
+		books, err := filepath.Glob(filepath.Join(source, "*.epub"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(books)
+
+		for _, book := range books {
+			for _, lang := range targetLangs {
+				items = append(items, &batchItem{Book: book, SourceLang: sourceLang, TargetLang: lang, Provider: providerName, Status: "pending"})
+			}
+		}
+		return items, nil
+	}
+
+	manifest, err := loadBatchManifest(source)
+	if err != nil {
+		return nil, err
+	}
+
+	baseDir := filepath.Dir(source)
+	for _, book := range manifest.Books {
+		path := book.Path
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(baseDir, path)
+		}
+
+		src := book.Source
+		if src == "" {
+			src = sourceLang
+		}
+
+		provider := book.Provider
+		if provider == "" {
+			provider = providerName
+		}
+
+		langs := book.Languages
+		if len(langs) == 0 {
+			langs = targetLangs
+		}
+
+		for _, lang := range langs {
+			items = append(items, &batchItem{Book: path, SourceLang: src, TargetLang: lang, Provider: provider, Status: "pending"})
+		}
+	}
+
+	return items, nil
+}
+
+func loadBatchManifest(path string) (*batchManifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest batchManifest
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, &manifest)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &manifest)
+	default:
+		return nil, fmt.Errorf("unsupported manifest format %q (use .json, .yaml or .yml)", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+
+	return &manifest, nil
+}
+
+// resumeFrom marks pairs that a previous run completed (and whose output still
+// exists) as completed, and returns how many were carried over.
+func (r *batchReport) resumeFrom(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+
+	var previous batchReport
+	if err := json.Unmarshal(data, &previous); err != nil {
+		logger.Warnf("Ignoring unreadable batch report %s: %v", path, err)
+		return 0
+	}
+
+	done := make(map[string]*batchItem)
+	for _, item := range previous.Items {
+		if item.Status == "completed" {
+			done[item.Book+"\x00"+item.TargetLang] = item
+		}
+	}
+
+	resumed := 0
+	for _, item := range r.Items {
+		prev, ok := done[item.Book+"\x00"+item.TargetLang]
+		if !ok {
+			continue
+		}
+		if _, err := os.Stat(prev.Output); err != nil {
+			continue
+		}
+		*item = *prev
+		resumed++
+	}
+
+	return resumed
+}
+
+func (r *batchReport) tally() {
+	r.Completed, r.Failed, r.Pending = 0, 0, 0
+	for _, item := range r.Items {
+		switch item.Status {
+		case "completed":
+			r.Completed++
+		case "failed":
+			r.Failed++
+		default:
+			r.Pending++
+		}
+	}
+}
+
+func (r *batchReport) save(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"epub-translator/internal/epub"
+	"epub-translator/internal/translation"
+
+	"github.com/spf13/cobra"
+)
+
+var estimateCmd = &cobra.Command{
+	Use:   "estimate <book.epub>",
+	Short: "Estimate the tokens and cost of translating a book",
+	Long: `Estimate segments the book exactly as translate would and counts the tokens of the
+requests that would be sent, without sending any. Segments already in the translation
+memory or cache are left out. The cost comes from the pricing table in the configuration.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runEstimate,
+}
+
+func init() {
+	estimateCmd.Flags().String("to", "", "Target language code (e.g. fa)")
+	estimateCmd.Flags().String("from", "", "Source language code (detected automatically if empty)")
+	estimateCmd.Flags().String("provider", "", "Translation provider (default: translation.provider from the configuration)")
+	estimateCmd.Flags().StringSlice("chapters", nil, "Only estimate the chapters with these IDs")
+	estimateCmd.Flags().Bool("json", false, "Print the estimate as JSON")
+	_ = estimateCmd.MarkFlagRequired("to")
+
+	rootCmd.AddCommand(estimateCmd)
+}
+
+func runEstimate(cmd *cobra.Command, args []string) {
+	cfg, err := loadConfig(cmd)
+	if err != nil {
+		logger.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	setupLogging(cmd)
+
+	targetLang, _ := cmd.Flags().GetString("to")
+	sourceLang, _ := cmd.Flags().GetString("from")
+	providerName, _ := cmd.Flags().GetString("provider")
+	chapterIDs, _ := cmd.Flags().GetStringSlice("chapters")
+	asJSON, _ := cmd.Flags().GetBool("json")
+
+	if err := os.MkdirAll(cfg.App.TempDir, 0755); err != nil {
+		logger.Fatalf("Failed to create temp directory: %v", err)
+	}
+
+	svc, err := translation.NewService(cfg, logger, nil)
+	if err != nil {
+		logger.Fatalf("Failed to create translation service: %v", err)
+	}
+	defer func() { _ = svc.Close() }()
+
+	parser := epub.NewParser(logger, cfg.App.TempDir)
+	book, err := parser.Extract(args[0])
+	if err != nil {
+		logger.Fatalf("Failed to extract EPUB: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(book.TempDir) }()
+
+	// Detecting the language is a request of its own; it is cheap next to the book
+	if sourceLang == "" {
+		ctx, stop := interruptContext()
+		sourceLang, err = svc.DetectLanguage(ctx, book)
+		stop()
+		if err != nil {
+			logger.Fatalf("Failed to detect source language: %v", err)
+		}
+	}
+
+	estimate, err := svc.EstimateCost(book, sourceLang, targetLang, providerName, chapterIDs)
+	if err != nil {
+		logger.Fatalf("Estimate failed: %v", err)
+	}
+
+	if asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(estimate); err != nil {
+			logger.Fatalf("Failed to write estimate: %v", err)
+		}
+		return
+	}
+
+	fmt.Printf("📚 %s: %s → %s with %s (%s)\n", args[0], sourceLang, targetLang, estimate.Provider, estimate.Model)
+	fmt.Printf("%-30s %9s %9s %12s %12s %10s\n", "Chapter", "Segments", "Requests", "Input", "Output", "Cost")
+	for _, chapter := range estimate.Chapters {
+		fmt.Printf("%-30s %9d %9d %12d %12d %10.4f\n", truncate(chapter.Title, 30), chapter.Segments-chapter.CachedSegments,
+			chapter.Requests, chapter.InputTokens, chapter.OutputTokens, chapter.Cost)
+	}
+	fmt.Printf("%-30s %9d %9d %12d %12d %10.4f\n", "Total", estimate.Segments-estimate.CachedSegments,
+		estimate.Requests, estimate.InputTokens, estimate.OutputTokens, estimate.Cost)
+
+	if estimate.CachedSegments > 0 {
+		fmt.Printf("🗃️  %d of %d segments are already translated and not counted\n", estimate.CachedSegments, estimate.Segments)
+	}
+	if estimate.Priced {
+		fmt.Printf("💰 Estimated cost: %.2f %s\n", estimate.Cost, estimate.Currency)
+	} else {
+		fmt.Printf("⚠️  No price configured for %s; add it to pricing.models to get a cost\n", estimate.Model)
+	}
+}
+
+// truncate shortens text to at most max characters for table output.
+func truncate(text string, max int) string {
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+	return string(runes[:max-1]) + "…"
+}
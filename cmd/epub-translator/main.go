@@ -103,11 +103,6 @@ func runServer(cmd *cobra.Command, _ []string) {
 	// Setup logging based on config and flags
 	setupLogging(cmd)
 
-	// Validate configuration
-	if cfg.OpenAI.APIKey == "" {
-		logger.Fatal("OpenAI API key is required but not found in configuration")
-	}
-
 	// Create necessary directories
 	if err := os.MkdirAll(cfg.App.TempDir, 0755); err != nil {
 		logger.Fatalf("Failed to create temp directory: %v", err)
@@ -118,7 +113,10 @@ func runServer(cmd *cobra.Command, _ []string) {
 	}
 
 	// Initialize server
-	srv := server.New(cfg, logger)
+	srv, err := server.New(cfg, logger)
+	if err != nil {
+		logger.Fatalf("Failed to initialize server: %v", err)
+	}
 	httpServer := &http.Server{
 		Addr:         fmt.Sprintf(":%d", cfg.Server.Port),
 		Handler:      srv.Handler(),
@@ -154,6 +152,12 @@ func runServer(cmd *cobra.Command, _ []string) {
 		logger.Fatalf("Server forced to shutdown: %v", err)
 	}
 
+	// Running translations are stopped and marked as interrupted, so that they
+	// are restored on the next start
+	if err := srv.Shutdown(ctx); err != nil {
+		logger.Errorf("Failed to stop translations: %v", err)
+	}
+
 	logger.Info("✅ Server exited gracefully")
 }
 
@@ -242,18 +246,55 @@ func showConfig(cmd *cobra.Command) {
 	} else {
 		fmt.Printf("  API Key: ❌ Not set\n")
 	}
+	if cfg.OpenAI.BaseURL != "" {
+		fmt.Printf("  Base URL: %s\n", cfg.OpenAI.BaseURL)
+	}
+	fmt.Printf("  API Type: %s\n", cfg.OpenAI.APIType)
 	fmt.Printf("  Model: %s\n", cfg.OpenAI.Model)
 	fmt.Printf("  Max Tokens: %d\n", cfg.OpenAI.MaxTokens)
 	fmt.Printf("  Temperature: %.1f\n", cfg.OpenAI.Temperature)
 	fmt.Printf("\n")
 
 	fmt.Printf("Translation Settings:\n")
+	fmt.Printf("  Provider: %s\n", cfg.Translation.Provider)
 	fmt.Printf("  Batch Size: %d\n", cfg.Translation.BatchSize)
 	fmt.Printf("  Max Retries: %d\n", cfg.Translation.MaxRetries)
 	fmt.Printf("  Retry Delay: %s\n", cfg.Translation.RetryDelay)
+	fmt.Printf("  Request Timeout: %s\n", cfg.Translation.RequestTimeout)
+	fmt.Printf("  Structured Output: %t\n", cfg.Translation.StructuredOutput)
+	fmt.Printf("  Context Paragraphs: %d\n", cfg.Translation.ContextParagraphs)
+	fmt.Printf("  Parallel Chapters: %d\n", cfg.Translation.ParallelChapters)
 	fmt.Printf("  Supported Languages: %d languages\n", len(cfg.Translation.SupportedLangs))
 	fmt.Printf("\n")
 
+	fmt.Printf("Rate Limits (0 = unlimited):\n")
+	fmt.Printf("  Max Concurrent Requests: %d\n", cfg.RateLimit.MaxConcurrent)
+	fmt.Printf("  Requests per Minute: %d\n", cfg.RateLimit.RequestsPerMinute)
+	fmt.Printf("  Tokens per Minute: %d\n", cfg.RateLimit.TokensPerMinute)
+	fmt.Printf("\n")
+
+	fmt.Printf("Pricing:\n")
+	fmt.Printf("  Currency: %s\n", cfg.Pricing.Currency)
+	fmt.Printf("  Priced Models: %d\n", len(cfg.Pricing.Models))
+	fmt.Printf("\n")
+
+	fmt.Printf("Budget (0 = unlimited):\n")
+	fmt.Printf("  Tokens per Job: %d\n", cfg.Budget.JobTokens)
+	fmt.Printf("  Cost per Job: %.2f %s\n", cfg.Budget.JobCost, cfg.Pricing.Currency)
+	fmt.Printf("  Tokens per Day: %d\n", cfg.Budget.DailyTokens)
+	fmt.Printf("  Cost per Day: %.2f %s\n", cfg.Budget.DailyCost, cfg.Pricing.Currency)
+	fmt.Printf("  Usage File: %s\n", cfg.BudgetPath())
+	fmt.Printf("\n")
+
+	fmt.Printf("Usage:\n")
+	fmt.Printf("  Ledger: %s\n", cfg.UsageLedgerPath())
+	fmt.Printf("\n")
+
+	fmt.Printf("Jobs:\n")
+	fmt.Printf("  Journal: %s\n", cfg.JobStorePath())
+	fmt.Printf("  On Restart: %s\n", cfg.Jobs.OnRestart)
+	fmt.Printf("\n")
+
 	fmt.Printf("Application Settings:\n")
 	fmt.Printf("  Temp Directory: %s\n", cfg.App.TempDir)
 	fmt.Printf("  Output Directory: %s\n", cfg.App.OutputDir)
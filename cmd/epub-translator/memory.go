@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"epub-translator/internal/tm"
+
+	"github.com/spf13/cobra"
+)
+
+var memoryCmd = &cobra.Command{
+	Use:   "tm",
+	Short: "Manage the translation memory",
+}
+
+var memoryImportCmd = &cobra.Command{
+	Use:   "import <file.tmx>...",
+	Short: "Import TMX files into the translation memory",
+	Args:  cobra.MinimumNArgs(1),
+	Run:   runMemoryImport,
+}
+
+var memoryExportCmd = &cobra.Command{
+	Use:   "export <file.tmx>",
+	Short: "Export the translation memory as TMX",
+	Args:  cobra.ExactArgs(1),
+	Run:   runMemoryExport,
+}
+
+func init() {
+	memoryExportCmd.Flags().String("book", "", "Only export segments of this book ID")
+	memoryExportCmd.Flags().String("from", "", "Only export segments from this source language")
+	memoryExportCmd.Flags().String("to", "", "Only export segments into this target language")
+
+	memoryCmd.AddCommand(memoryImportCmd)
+	memoryCmd.AddCommand(memoryExportCmd)
+	rootCmd.AddCommand(memoryCmd)
+}
+
+func openMemory(cmd *cobra.Command) *tm.Memory {
+	cfg, err := loadConfig(cmd)
+	if err != nil {
+		logger.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	setupLogging(cmd)
+
+	if !cfg.TranslationMemory.Enabled {
+		logger.Fatalf("Translation memory is disabled in the configuration")
+	}
+
+	memory, err := tm.Open(cfg.TranslationMemoryPath())
+	if err != nil {
+		logger.Fatalf("Failed to open translation memory: %v", err)
+	}
+	return memory
+}
+
+func runMemoryImport(cmd *cobra.Command, args []string) {
+	memory := openMemory(cmd)
+	defer func() { _ = memory.Close() }()
+
+	for _, path := range args {
+		file, err := os.Open(path)
+		if err != nil {
+			logger.Fatalf("Failed to open %s: %v", path, err)
+		}
+
+		entries, err := tm.ReadTMX(file)
+		_ = file.Close()
+		if err != nil {
+			logger.Fatalf("Failed to import %s: %v", path, err)
+		}
+
+		added, err := memory.Add(entries...)
+		if err != nil {
+			logger.Fatalf("Failed to import %s: %v", path, err)
+		}
+
+		fmt.Printf("📥 %s: %d new entries (%d read)\n", path, added, len(entries))
+	}
+
+	fmt.Printf("✅ Translation memory now holds %d segments\n", memory.Len())
+}
+
+func runMemoryExport(cmd *cobra.Command, args []string) {
+	memory := openMemory(cmd)
+	defer func() { _ = memory.Close() }()
+
+	bookID, _ := cmd.Flags().GetString("book")
+	sourceLang, _ := cmd.Flags().GetString("from")
+	targetLang, _ := cmd.Flags().GetString("to")
+
+	entries := memory.Entries(bookID, sourceLang, targetLang)
+	if len(entries) == 0 {
+		logger.Fatalf("No translation memory entries match")
+	}
+
+	file, err := os.Create(args[0])
+	if err != nil {
+		logger.Fatalf("Failed to create %s: %v", args[0], err)
+	}
+
+	if err := tm.WriteTMX(file, entries); err != nil {
+		_ = file.Close()
+		logger.Fatalf("Failed to export translation memory: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		logger.Fatalf("Failed to write %s: %v", args[0], err)
+	}
+
+	fmt.Printf("✅ Exported %d segments to %s\n", len(entries), args[0])
+}
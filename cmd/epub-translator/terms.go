@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"epub-translator/internal/epub"
+	"epub-translator/internal/glossary"
+	"epub-translator/internal/translation"
+
+	"github.com/spf13/cobra"
+)
+
+var termsCmd = &cobra.Command{
+	Use:   "terms <book.epub>",
+	Short: "Propose a glossary of recurring names and terms in a book",
+	Long: `Terms runs the terminology pass on its own: recurring proper nouns and terms are
+collected from the book and the provider proposes a translation for each. The draft is
+written as a CSV glossary that can be edited and passed to translate with --glossary.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runTerms,
+}
+
+func init() {
+	termsCmd.Flags().String("to", "", "Target language code (e.g. fa)")
+	termsCmd.Flags().String("from", "", "Source language code (detected automatically if empty)")
+	termsCmd.Flags().String("provider", "", "Translation provider (default: translation.provider from the configuration)")
+	termsCmd.Flags().String("out", "glossary.csv", "Path of the draft glossary")
+	_ = termsCmd.MarkFlagRequired("to")
+
+	rootCmd.AddCommand(termsCmd)
+}
+
+func runTerms(cmd *cobra.Command, args []string) {
+	cfg, err := loadConfig(cmd)
+	if err != nil {
+		logger.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	setupLogging(cmd)
+
+	targetLang, _ := cmd.Flags().GetString("to")
+	sourceLang, _ := cmd.Flags().GetString("from")
+	providerName, _ := cmd.Flags().GetString("provider")
+	outputPath, _ := cmd.Flags().GetString("out")
+
+	if err := os.MkdirAll(cfg.App.TempDir, 0755); err != nil {
+		logger.Fatalf("Failed to create temp directory: %v", err)
+	}
+
+	svc, err := translation.NewService(cfg, logger, &consoleProgress{})
+	if err != nil {
+		logger.Fatalf("Failed to create translation service: %v", err)
+	}
+	defer func() { _ = svc.Close() }()
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	parser := epub.NewParser(logger, cfg.App.TempDir)
+	book, err := parser.Extract(args[0])
+	if err != nil {
+		logger.Fatalf("Failed to extract EPUB: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(book.TempDir) }()
+
+	if sourceLang == "" {
+		sourceLang, err = svc.DetectLanguage(ctx, book)
+		if err != nil {
+			logger.Fatalf("Failed to detect source language: %v", err)
+		}
+	}
+
+	draft, err := svc.ExtractTerminology(ctx, book, sourceLang, targetLang, providerName, false)
+	if err != nil {
+		logger.Fatalf("Terminology extraction failed: %v", err)
+	}
+
+	file, err := os.Create(outputPath)
+	if err != nil {
+		logger.Fatalf("Failed to create %s: %v", outputPath, err)
+	}
+
+	if err := glossary.WriteCSV(file, &glossary.Glossary{Terms: draft.Terms}); err != nil {
+		_ = file.Close()
+		logger.Fatalf("Failed to write glossary: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		logger.Fatalf("Failed to write %s: %v", outputPath, err)
+	}
+
+	fmt.Printf("📝 %d terms proposed from %d candidates, written to %s\n", len(draft.Terms), len(draft.Candidates), outputPath)
+	fmt.Printf("   Review the file, then run: translate %s --to %s --glossary %s\n", args[0], targetLang, outputPath)
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+
+	"epub-translator/internal/config"
+	"epub-translator/internal/epub"
+	"epub-translator/internal/translation"
+
+	"github.com/spf13/cobra"
+)
+
+var translateCmd = &cobra.Command{
+	Use:   "translate <book.epub>",
+	Short: "Translate an EPUB file without starting the web server",
+	Long: `Translate runs the whole pipeline in-process: the book is extracted, every chapter is
+translated and the translated EPUB is written to the output directory. The command
+exits with a non-zero status if any step fails, so it can be used from cron jobs and Makefiles.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runTranslate,
+}
+
+func init() {
+	translateCmd.Flags().String("to", "", "Target language code (e.g. fa)")
+	translateCmd.Flags().String("from", "", "Source language code (detected automatically if empty)")
+	translateCmd.Flags().String("provider", "", "Translation provider (default: translation.provider from the configuration)")
+	translateCmd.Flags().String("out", "", "Directory for the translated EPUB (default: configured output directory)")
+	translateCmd.Flags().String("glossary", "", "CSV or JSON glossary used instead of glossary.path")
+	translateCmd.Flags().String("resume", "", "Continue the stopped translation of the book extracted under this ID")
+	_ = translateCmd.MarkFlagRequired("to")
+
+	rootCmd.AddCommand(translateCmd)
+}
+
+func runTranslate(cmd *cobra.Command, args []string) {
+	cfg, err := loadConfig(cmd)
+	if err != nil {
+		logger.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	setupLogging(cmd)
+
+	targetLang, _ := cmd.Flags().GetString("to")
+	sourceLang, _ := cmd.Flags().GetString("from")
+	providerName, _ := cmd.Flags().GetString("provider")
+	outputDir, _ := cmd.Flags().GetString("out")
+	if outputDir == "" {
+		outputDir = cfg.App.OutputDir
+	}
+	if glossaryPath, _ := cmd.Flags().GetString("glossary"); glossaryPath != "" {
+		cfg.Glossary.Path = glossaryPath
+	}
+	resumeID, _ := cmd.Flags().GetString("resume")
+
+	if err := os.MkdirAll(cfg.App.TempDir, 0755); err != nil {
+		logger.Fatalf("Failed to create temp directory: %v", err)
+	}
+
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		logger.Fatalf("Failed to create output directory: %v", err)
+	}
+
+	svc, err := translation.NewService(cfg, logger, &consoleProgress{})
+	if err != nil {
+		logger.Fatalf("Failed to create translation service: %v", err)
+	}
+	defer func() { _ = svc.Close() }()
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	outputPath, err := translateFile(ctx, cfg, svc, args[0], resumeID, sourceLang, targetLang, providerName, outputDir)
+	if err != nil {
+		logger.Fatalf("Translation failed: %v", err)
+	}
+
+	fmt.Printf("✅ Translated EPUB written to %s\n", outputPath)
+}
+
+// interruptContext returns a context that is cancelled on SIGINT or SIGTERM,
+// so that an interrupted translation aborts its requests in flight.
+func interruptContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+// translateFile runs extract → translate → build for a single book and returns
+// the path of the translated EPUB. An empty sourceLang is detected from the text
+// and an empty providerName selects the configured provider. A non-empty
+// resumeID continues the translation of the book left extracted under that ID
+// by a run that stopped.
+func translateFile(ctx context.Context, cfg *config.Config, svc *translation.Service, inputPath, resumeID, sourceLang, targetLang, providerName, outputDir string) (string, error) {
+	parser := epub.NewParser(logger, cfg.App.TempDir)
+	builder := epub.NewBuilder(logger)
+
+	var book *epub.EPUB
+	var err error
+	if resumeID != "" {
+		book, err = parser.LoadFromDirectory(resumeID)
+		if err != nil {
+			return "", fmt.Errorf("failed to load EPUB %s: %w", resumeID, err)
+		}
+	} else {
+		book, err = parser.Extract(inputPath)
+		if err != nil {
+			return "", fmt.Errorf("failed to extract EPUB: %w", err)
+		}
+	}
+	// The extraction, the chapter checkpoints and their state are only needed
+	// while the book is translated. A translation that stopped keeps them, so
+	// that it can be continued with --resume.
+	keep := false
+	defer func() {
+		if keep {
+			return
+		}
+		_ = os.RemoveAll(book.TempDir)
+		_ = os.RemoveAll(fmt.Sprintf("%s_translated_%s", book.TempDir, targetLang))
+		svc.ClearCheckpoints(book.ID)
+	}()
+
+	if err := parser.Validate(book); err != nil {
+		return "", fmt.Errorf("invalid EPUB file: %w", err)
+	}
+
+	if sourceLang == "" {
+		sourceLang, err = svc.DetectLanguage(ctx, book)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if sourceLang == targetLang {
+		return "", fmt.Errorf("source and target languages are the same (%s)", sourceLang)
+	}
+
+	fmt.Printf("📚 %s: %d chapters, %s → %s\n", filepath.Base(inputPath), len(book.Chapters), sourceLang, targetLang)
+
+	defer svc.ClearProgress(book.ID)
+	keep = true
+	if err := svc.TranslateBook(ctx, book, sourceLang, targetLang, providerName); err != nil {
+		return "", fmt.Errorf("%w (continue with --resume %s)", err, book.ID)
+	}
+
+	if progress := svc.GetProgress(book.ID); progress != nil {
+		if progress.CacheHits+progress.CacheMisses > 0 {
+			fmt.Printf("🗃️  Cache: %d hits, %d misses\n", progress.CacheHits, progress.CacheMisses)
+		}
+		if progress.MemoryHits > 0 {
+			fmt.Printf("📖 Translation memory: %d exact matches\n", progress.MemoryHits)
+		}
+		if used := progress.Usage; used.Requests > 0 {
+			fmt.Printf("💰 Usage: %d requests, %d prompt + %d completion tokens, %.4f %s\n",
+				used.Requests, used.PromptTokens, used.CompletionTokens, used.Cost, cfg.Pricing.Currency)
+		}
+		for _, issue := range progress.Issues {
+			fmt.Printf("⚠️  %s: %s\n", issue.ChapterID, issue.Message)
+		}
+	}
+
+	builtPath, err := builder.CreateTranslated(book, targetLang, outputDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to build translated EPUB: %w", err)
+	}
+	keep = false
+
+	outputPath := filepath.Join(outputDir, translatedFileName(inputPath, targetLang))
+	if err := os.Rename(builtPath, outputPath); err != nil {
+		return "", fmt.Errorf("failed to move translated EPUB into place: %w", err)
+	}
+
+	return outputPath, nil
+}
+
+// translatedFileName names the output after the input file, e.g. book.epub -> book_fa.epub
+func translatedFileName(inputPath, targetLang string) string {
+	base := strings.TrimSuffix(filepath.Base(inputPath), filepath.Ext(inputPath))
+	return fmt.Sprintf("%s_%s.epub", base, targetLang)
+}
+
+// consoleProgress prints chapter progress to the terminal. It stands in for the
+// WebSocket hub when the translation service runs without the web server.
+type consoleProgress struct {
+	mu        sync.Mutex
+	completed map[string]int
+}
+
+func (p *consoleProgress) BroadcastMessage(msgType interface{}, data interface{}) {
+	if msgType != "translation_progress" {
+		return
+	}
+
+	msg, ok := data.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	epubID, _ := msg["epub_id"].(string)
+	completed, _ := msg["completed_chapters"].(int)
+	total, _ := msg["total_chapters"].(int)
+	chapter, _ := msg["current_chapter"].(string)
+	percent, _ := msg["progress_percent"].(float64)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.completed == nil {
+		p.completed = make(map[string]int)
+	}
+	if last, seen := p.completed[epubID]; seen && last == completed {
+		return
+	}
+	p.completed[epubID] = completed
+
+	if completed > 0 {
+		fmt.Printf("  [%d/%d] %3.0f%%  %s\n", completed, total, percent, chapter)
+	}
+}
+
+// BroadcastLog is a no-op; the service already writes these lines to the logger.
+func (p *consoleProgress) BroadcastLog(level, message, module string) {}
@@ -0,0 +1,127 @@
+package cache
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Store is a persistent key-value store for translated segments. Entries are
+// kept in memory and appended to a JSON-lines file, so a crash loses at most
+// the entry being written.
+type Store struct {
+	mu      sync.RWMutex
+	path    string
+	file    *os.File
+	entries map[string]string
+}
+
+type record struct {
+	Key   string `json:"k"`
+	Value string `json:"v"`
+}
+
+// Open loads the store at path, creating the file and its directory if needed.
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cache file: %w", err)
+	}
+
+	s := &Store{
+		path:    path,
+		file:    file,
+		entries: make(map[string]string),
+	}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var rec record
+		// A torn last line from an interrupted write is skipped
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil || rec.Key == "" {
+			continue
+		}
+		s.entries[rec.Key] = rec.Value
+	}
+	if err := scanner.Err(); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("failed to read cache file: %w", err)
+	}
+
+	return s, nil
+}
+
+// Get returns the value stored under key.
+func (s *Store) Get(key string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	value, ok := s.entries[key]
+	return value, ok
+}
+
+// Put stores value under key and appends it to the cache file.
+func (s *Store) Put(key, value string) error {
+	data, err := json.Marshal(record{Key: key, Value: value})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.entries[key]; ok && existing == value {
+		return nil
+	}
+
+	if _, err := s.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	s.entries[key] = value
+	return nil
+}
+
+// Len returns the number of cached entries.
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.entries)
+}
+
+// Path returns the location of the cache file.
+func (s *Store) Path() string {
+	return s.path
+}
+
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+// Key hashes the given parts into a cache key.
+func Key(parts ...string) string {
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Normalize collapses whitespace so that reformatted copies of the same text
+// share a cache entry.
+func Normalize(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
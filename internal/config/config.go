@@ -45,21 +45,160 @@ type Config struct {
 		Model       string  `json:"model"`
 		MaxTokens   int     `json:"max_tokens"`
 		Temperature float32 `json:"temperature"`
+		// BaseURL points the client at Azure OpenAI, a proxy or any server
+		// speaking the same protocol. Empty means the public OpenAI API.
+		BaseURL         string            `json:"base_url"`
+		APIType         string            `json:"api_type"` // "openai" or "azure"
+		APIVersion      string            `json:"api_version"`
+		AzureDeployment string            `json:"azure_deployment"`
+		Organization    string            `json:"organization"`
+		Headers         map[string]string `json:"headers"`
 	} `json:"openai"`
 
+	Anthropic struct {
+		APIKey      string  `json:"api_key"`
+		BaseURL     string  `json:"base_url"`
+		Version     string  `json:"version"`
+		Model       string  `json:"model"`
+		MaxTokens   int     `json:"max_tokens"`
+		Temperature float32 `json:"temperature"`
+	} `json:"anthropic"`
+
+	// OpenAICompatible configures a local or self-hosted server that speaks the
+	// OpenAI chat completions protocol (llama.cpp, vLLM, Ollama, ...).
+	OpenAICompatible struct {
+		BaseURL     string  `json:"base_url"`
+		APIKey      string  `json:"api_key"`
+		Model       string  `json:"model"`
+		MaxTokens   int     `json:"max_tokens"`
+		Temperature float32 `json:"temperature"`
+	} `json:"openai_compatible"`
+
 	Translation struct {
+		Provider       string   `json:"provider"`
 		BatchSize      int      `json:"batch_size"`
 		MaxRetries     int      `json:"max_retries"`
 		RetryDelay     Duration `json:"retry_delay"`
 		SupportedLangs []string `json:"supported_languages"`
+		// RequestTimeout limits every attempt of a request on its own.
+		RequestTimeout Duration `json:"request_timeout"`
+		// StructuredOutput requests JSON replies that follow a schema and
+		// re-asks the model when a reply does not validate.
+		StructuredOutput bool `json:"structured_output"`
+		// ContextParagraphs is how many preceding paragraphs, with their
+		// translations, are sent along as read-only context.
+		ContextParagraphs int `json:"context_paragraphs"`
+		// ParallelChapters is how many chapters of a book are translated at
+		// once. Their requests still pass the rate limits below.
+		ParallelChapters int `json:"parallel_chapters"`
 	} `json:"translation"`
 
+	// RateLimit bounds the LLM requests of the whole process, across every
+	// provider and job. Zero means no limit.
+	RateLimit struct {
+		MaxConcurrent     int `json:"max_concurrent"`
+		RequestsPerMinute int `json:"requests_per_minute"`
+		TokensPerMinute   int `json:"tokens_per_minute"`
+	} `json:"rate_limit"`
+
+	// Cache stores translated segments on disk so re-runs and retries do not
+	// pay for the same text twice.
+	Cache struct {
+		Enabled bool `json:"enabled"`
+		// Path defaults to cache/translations.jsonl in the temp directory.
+		Path string `json:"path"`
+	} `json:"cache"`
+
+	// TranslationMemory holds approved segments imported from TMX files and
+	// every segment translated by the tool. Exact matches skip the provider.
+	TranslationMemory struct {
+		Enabled bool `json:"enabled"`
+		// Path defaults to memory/segments.jsonl in the temp directory.
+		Path string `json:"path"`
+	} `json:"translation_memory"`
+
+	// Glossary lists terms that must be translated the same way in every
+	// book, either inline or in a CSV/JSON file. Books can add their own.
+	Glossary struct {
+		Path  string `json:"path"`
+		Terms []struct {
+			Source string `json:"source"`
+			Target string `json:"target"`
+			Note   string `json:"note,omitempty"`
+			Lang   string `json:"lang,omitempty"`
+		} `json:"terms"`
+	} `json:"glossary"`
+
+	// Terminology configures the optional pass that proposes a draft glossary
+	// from recurring names and terms before a book is translated.
+	Terminology struct {
+		MinOccurrences int `json:"min_occurrences"`
+		MaxTerms       int `json:"max_terms"`
+		MaxExtraTerms  int `json:"max_extra_terms"`
+	} `json:"terminology"`
+
+	// BookMemory keeps running notes on the characters, forms of address, tone
+	// and recurring phrasings of a book. They are updated after every chapter
+	// and included in the prompts of the following ones.
+	BookMemory struct {
+		Enabled bool `json:"enabled"`
+		// ChapterChars caps the chapter text sent to update the notes.
+		ChapterChars int `json:"chapter_chars"`
+	} `json:"book_memory"`
+
+	// Pricing lists what models charge per million tokens. It is used to
+	// estimate the cost of a translation before it is started.
+	Pricing struct {
+		Currency string `json:"currency"`
+		// Models is keyed by model name; a key also prices every model whose
+		// name it prefixes, and the longest matching key wins.
+		Models map[string]ModelPrice `json:"models"`
+	} `json:"pricing"`
+
+	// Budget caps what translations spend, in tokens and in the pricing
+	// currency. A zero limit is off. A translation whose next request would
+	// go over a limit is paused.
+	Budget struct {
+		// JobTokens and JobCost apply to every translation that does not set
+		// its own budget.
+		JobTokens int     `json:"job_tokens"`
+		JobCost   float64 `json:"job_cost"`
+		// DailyTokens and DailyCost cover every request of the day.
+		DailyTokens int     `json:"daily_tokens"`
+		DailyCost   float64 `json:"daily_cost"`
+		// Path keeps the usage of the day across restarts; it defaults to
+		// budget.json in the temp directory.
+		Path string `json:"path"`
+	} `json:"budget"`
+
+	// Usage records the tokens and cost of every LLM request, attributed to
+	// the book, job and chapter it was made for.
+	Usage struct {
+		// Path defaults to usage.jsonl in the temp directory.
+		Path string `json:"path"`
+	} `json:"usage"`
+
+	// Jobs keeps the progress of server translations in a journal so that
+	// they survive a restart. Translations interrupted by a restart are
+	// resumed from their checkpoints, or only marked as interrupted.
+	Jobs struct {
+		// Path defaults to jobs.jsonl in the temp directory.
+		Path      string `json:"path"`
+		OnRestart string `json:"on_restart"` // "resume" or "interrupt"
+	} `json:"jobs"`
+
 	App struct {
 		TempDir   string `json:"temp_dir"`
 		OutputDir string `json:"output_dir"`
 	} `json:"app"`
 }
 
+// ModelPrice is the price of a model per million input and output tokens.
+type ModelPrice struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
 func New() *Config {
 	return &Config{
 		Server: struct {
@@ -76,17 +215,57 @@ func New() *Config {
 			Model       string  `json:"model"`
 			MaxTokens   int     `json:"max_tokens"`
 			Temperature float32 `json:"temperature"`
+			// BaseURL points the client at Azure OpenAI, a proxy or any server
+			// speaking the same protocol. Empty means the public OpenAI API.
+			BaseURL         string            `json:"base_url"`
+			APIType         string            `json:"api_type"` // "openai" or "azure"
+			APIVersion      string            `json:"api_version"`
+			AzureDeployment string            `json:"azure_deployment"`
+			Organization    string            `json:"organization"`
+			Headers         map[string]string `json:"headers"`
 		}{
 			Model:       "gpt-4o",
 			MaxTokens:   2048,
 			Temperature: 0.4,
+			APIType:     "openai",
+		},
+		Anthropic: struct {
+			APIKey      string  `json:"api_key"`
+			BaseURL     string  `json:"base_url"`
+			Version     string  `json:"version"`
+			Model       string  `json:"model"`
+			MaxTokens   int     `json:"max_tokens"`
+			Temperature float32 `json:"temperature"`
+		}{
+			BaseURL:     "https://api.anthropic.com",
+			Version:     "2023-06-01",
+			Model:       "claude-3-5-sonnet-latest",
+			MaxTokens:   2048,
+			Temperature: 0.4,
+		},
+		OpenAICompatible: struct {
+			BaseURL     string  `json:"base_url"`
+			APIKey      string  `json:"api_key"`
+			Model       string  `json:"model"`
+			MaxTokens   int     `json:"max_tokens"`
+			Temperature float32 `json:"temperature"`
+		}{
+			BaseURL:     "http://localhost:8000/v1",
+			MaxTokens:   2048,
+			Temperature: 0.4,
 		},
 		Translation: struct {
-			BatchSize      int      `json:"batch_size"`
-			MaxRetries     int      `json:"max_retries"`
-			RetryDelay     Duration `json:"retry_delay"`
-			SupportedLangs []string `json:"supported_languages"`
+			Provider          string   `json:"provider"`
+			BatchSize         int      `json:"batch_size"`
+			MaxRetries        int      `json:"max_retries"`
+			RetryDelay        Duration `json:"retry_delay"`
+			SupportedLangs    []string `json:"supported_languages"`
+			RequestTimeout    Duration `json:"request_timeout"`
+			StructuredOutput  bool     `json:"structured_output"`
+			ContextParagraphs int      `json:"context_paragraphs"`
+			ParallelChapters  int      `json:"parallel_chapters"`
 		}{
+			Provider:   "openai",
 			BatchSize:  10,
 			MaxRetries: 3,
 			RetryDelay: Duration{2 * time.Second},
@@ -94,6 +273,80 @@ func New() *Config {
 				"en", "es", "fr", "de", "it", "pt", "ru", "ja", "ko", "zh",
 				"ar", "fa", "he", "hi", "tr", "pl", "nl", "sv", "da", "no",
 			},
+			RequestTimeout:    Duration{60 * time.Second},
+			StructuredOutput:  true,
+			ContextParagraphs: 2,
+			ParallelChapters:  1,
+		},
+		RateLimit: struct {
+			MaxConcurrent     int `json:"max_concurrent"`
+			RequestsPerMinute int `json:"requests_per_minute"`
+			TokensPerMinute   int `json:"tokens_per_minute"`
+		}{
+			MaxConcurrent: 4,
+		},
+		Cache: struct {
+			Enabled bool   `json:"enabled"`
+			Path    string `json:"path"`
+		}{
+			Enabled: true,
+		},
+		TranslationMemory: struct {
+			Enabled bool   `json:"enabled"`
+			Path    string `json:"path"`
+		}{
+			Enabled: true,
+		},
+		Terminology: struct {
+			MinOccurrences int `json:"min_occurrences"`
+			MaxTerms       int `json:"max_terms"`
+			MaxExtraTerms  int `json:"max_extra_terms"`
+		}{
+			MinOccurrences: 3,
+			MaxTerms:       150,
+			MaxExtraTerms:  20,
+		},
+		BookMemory: struct {
+			Enabled      bool `json:"enabled"`
+			ChapterChars int  `json:"chapter_chars"`
+		}{
+			Enabled:      true,
+			ChapterChars: 12000,
+		},
+		Pricing: struct {
+			Currency string                `json:"currency"`
+			Models   map[string]ModelPrice `json:"models"`
+		}{
+			Currency: "USD",
+			Models: map[string]ModelPrice{
+				"gpt-4o":            {Input: 2.50, Output: 10.00},
+				"gpt-4o-mini":       {Input: 0.15, Output: 0.60},
+				"gpt-4.1":           {Input: 2.00, Output: 8.00},
+				"gpt-4.1-mini":      {Input: 0.40, Output: 1.60},
+				"gpt-4.1-nano":      {Input: 0.10, Output: 0.40},
+				"gpt-4-turbo":       {Input: 10.00, Output: 30.00},
+				"gpt-3.5-turbo":     {Input: 0.50, Output: 1.50},
+				"claude-3-5-sonnet": {Input: 3.00, Output: 15.00},
+				"claude-3-5-haiku":  {Input: 0.80, Output: 4.00},
+				"claude-3-opus":     {Input: 15.00, Output: 75.00},
+				"claude-3-haiku":    {Input: 0.25, Output: 1.25},
+			},
+		},
+		Budget: struct {
+			JobTokens   int     `json:"job_tokens"`
+			JobCost     float64 `json:"job_cost"`
+			DailyTokens int     `json:"daily_tokens"`
+			DailyCost   float64 `json:"daily_cost"`
+			Path        string  `json:"path"`
+		}{},
+		Usage: struct {
+			Path string `json:"path"`
+		}{},
+		Jobs: struct {
+			Path      string `json:"path"`
+			OnRestart string `json:"on_restart"`
+		}{
+			OnRestart: "resume",
 		},
 		App: struct {
 			TempDir   string `json:"temp_dir"`
@@ -105,6 +358,61 @@ func New() *Config {
 	}
 }
 
+// ModelPrice returns the price of model from the pricing table.
+func (c *Config) ModelPrice(model string) (ModelPrice, bool) {
+	model = strings.ToLower(model)
+	best := ""
+	for key := range c.Pricing.Models {
+		if strings.HasPrefix(model, strings.ToLower(key)) && len(key) > len(best) {
+			best = key
+		}
+	}
+	if best == "" {
+		return ModelPrice{}, false
+	}
+	return c.Pricing.Models[best], true
+}
+
+// CachePath returns the location of the translation cache.
+func (c *Config) CachePath() string {
+	if c.Cache.Path != "" {
+		return c.Cache.Path
+	}
+	return filepath.Join(c.App.TempDir, "cache", "translations.jsonl")
+}
+
+// TranslationMemoryPath returns the location of the translation memory.
+func (c *Config) TranslationMemoryPath() string {
+	if c.TranslationMemory.Path != "" {
+		return c.TranslationMemory.Path
+	}
+	return filepath.Join(c.App.TempDir, "memory", "segments.jsonl")
+}
+
+// BudgetPath returns the location of the daily usage file.
+func (c *Config) BudgetPath() string {
+	if c.Budget.Path != "" {
+		return c.Budget.Path
+	}
+	return filepath.Join(c.App.TempDir, "budget.json")
+}
+
+// UsageLedgerPath returns the location of the usage ledger.
+func (c *Config) UsageLedgerPath() string {
+	if c.Usage.Path != "" {
+		return c.Usage.Path
+	}
+	return filepath.Join(c.App.TempDir, "usage.jsonl")
+}
+
+// JobStorePath returns the location of the job journal.
+func (c *Config) JobStorePath() string {
+	if c.Jobs.Path != "" {
+		return c.Jobs.Path
+	}
+	return filepath.Join(c.App.TempDir, "jobs.jsonl")
+}
+
 func (c *Config) LoadFromFile(filepath string) error {
 	data, err := os.ReadFile(filepath)
 	if err != nil {
@@ -128,6 +436,18 @@ func (c *Config) LoadFromEnv() {
 	if model := os.Getenv("OPENAI_MODEL"); model != "" {
 		c.OpenAI.Model = model
 	}
+	if baseURL := os.Getenv("OPENAI_BASE_URL"); baseURL != "" {
+		c.OpenAI.BaseURL = baseURL
+	}
+	if org := os.Getenv("OPENAI_ORGANIZATION"); org != "" {
+		c.OpenAI.Organization = org
+	}
+	if apiKey := os.Getenv("ANTHROPIC_API_KEY"); apiKey != "" {
+		c.Anthropic.APIKey = apiKey
+	}
+	if provider := os.Getenv("TRANSLATION_PROVIDER"); provider != "" {
+		c.Translation.Provider = provider
+	}
 	if port := os.Getenv("PORT"); port != "" {
 		if p := parseInt(port); p > 0 {
 			c.Server.Port = p
@@ -173,8 +493,9 @@ func Load(configPath string) (*Config, error) {
 	// Override with environment variables
 	cfg.LoadFromEnv()
 
-	// Validate and prompt for missing OpenAI API key
-	if cfg.OpenAI.APIKey == "" || cfg.OpenAI.APIKey == "your-openai-api-key-here" {
+	// Validate and prompt for missing OpenAI API key when the public OpenAI API is used
+	if cfg.Translation.Provider == "openai" && cfg.OpenAI.BaseURL == "" &&
+		(cfg.OpenAI.APIKey == "" || cfg.OpenAI.APIKey == "your-openai-api-key-here") {
 		apiKey, err := promptForAPIKey()
 		if err != nil {
 			return nil, fmt.Errorf("failed to get OpenAI API key: %w", err)
@@ -379,8 +379,10 @@ func countWords(text string) int {
 	return len(words)
 }
 
+// generateID uses nanoseconds so books extracted concurrently (e.g. by the
+// batch command) never share an extraction directory.
 func generateID() string {
-	return fmt.Sprintf("epub_%d", time.Now().Unix())
+	return fmt.Sprintf("epub_%d", time.Now().UnixNano())
 }
 
 // ChapterTranslations holds information about available translations for a chapter
@@ -575,6 +577,19 @@ func (p *Parser) SaveTranslatedChapter(epubID, chapterPath, translatedContent, t
 	return nil
 }
 
+// LoadTranslatedChapter returns the body of a chapter saved by
+// SaveTranslatedChapter.
+func (p *Parser) LoadTranslatedChapter(epubID, chapterPath, targetLang string) (string, error) {
+	originalDir := filepath.Join(p.tempDir, epubID)
+	relPath, err := filepath.Rel(originalDir, chapterPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to calculate relative path: %w", err)
+	}
+
+	translatedDir := filepath.Join(p.tempDir, fmt.Sprintf("%s_translated_%s", epubID, targetLang))
+	return p.extractChapterContent(filepath.Join(translatedDir, relPath))
+}
+
 // CreateTranslatedCopyWithLanguage creates a language-specific copy of the EPUB directory for storing translations
 func (p *Parser) CreateTranslatedCopyWithLanguage(epubID, targetLang string) (string, error) {
 	sourceDir := filepath.Join(p.tempDir, epubID)
@@ -1,8 +1,11 @@
 package epub
 
 import (
+	"context"
 	"encoding/xml"
 	"time"
+
+	"epub-translator/internal/usage"
 )
 
 type EPUB struct {
@@ -83,30 +86,55 @@ type Reference struct {
 }
 
 type Chapter struct {
-	ID                    string            `json:"id"`
-	Title                 string            `json:"title"`
-	FilePath              string            `json:"file_path"`
-	RelativePath          string            `json:"relative_path"`
-	Content               string            `json:"content"`
-	TranslatedContent     string            `json:"translated_content,omitempty"`
-	Order                 int               `json:"order"`
-	WordCount             int               `json:"word_count"`
-	IsTranslated          bool              `json:"is_translated"`
-	AvailableTranslations map[string]bool   `json:"available_translations"` // lang -> exists
-	TranslationPaths      map[string]string `json:"translation_paths"`      // lang -> file path
+	ID                    string             `json:"id"`
+	Title                 string             `json:"title"`
+	FilePath              string             `json:"file_path"`
+	RelativePath          string             `json:"relative_path"`
+	Content               string             `json:"content"`
+	TranslatedContent     string             `json:"translated_content,omitempty"`
+	Order                 int                `json:"order"`
+	WordCount             int                `json:"word_count"`
+	IsTranslated          bool               `json:"is_translated"`
+	AvailableTranslations map[string]bool    `json:"available_translations"` // lang -> exists
+	TranslationPaths      map[string]string  `json:"translation_paths"`      // lang -> file path
+	Issues                []TranslationIssue `json:"issues,omitempty"`
+}
+
+// TranslationIssue flags a translated segment that failed a check, e.g. one
+// that does not use the required glossary term. Flagged elements carry a
+// data-translation-issue attribute with the issue kind.
+type TranslationIssue struct {
+	ChapterID string `json:"chapter_id"`
+	Kind      string `json:"kind"`
+	Segment   string `json:"segment"`
+	Message   string `json:"message"`
 }
 
 type TranslationProgress struct {
-	ID                string    `json:"id"`
-	SourceLanguage    string    `json:"source_language"`
-	TargetLanguage    string    `json:"target_language"`
-	TotalChapters     int       `json:"total_chapters"`
-	CompletedChapters int       `json:"completed_chapters"`
-	CurrentChapter    string    `json:"current_chapter"`
-	Status            string    `json:"status"`
-	StartedAt         time.Time `json:"started_at"`
-	CompletedAt       time.Time `json:"completed_at,omitempty"`
-	ErrorMessage      string    `json:"error_message,omitempty"`
+	ID                string             `json:"id"`
+	SourceLanguage    string             `json:"source_language"`
+	TargetLanguage    string             `json:"target_language"`
+	Provider          string             `json:"provider,omitempty"`
+	Model             string             `json:"model,omitempty"`
+	TotalChapters     int                `json:"total_chapters"`
+	CompletedChapters int                `json:"completed_chapters"`
+	CurrentChapter    string             `json:"current_chapter"`
+	Status            string             `json:"status"`
+	StartedAt         time.Time          `json:"started_at"`
+	CompletedAt       time.Time          `json:"completed_at,omitempty"`
+	ErrorMessage      string             `json:"error_message,omitempty"`
+	CacheHits         int                `json:"cache_hits"`
+	CacheMisses       int                `json:"cache_misses"`
+	MemoryHits        int                `json:"memory_hits"`
+	Issues            []TranslationIssue `json:"issues,omitempty"`
+	// PauseReason explains a pause the user did not ask for.
+	PauseReason string `json:"pause_reason,omitempty"`
+	// JobID identifies the translation in the usage ledger; a resumed
+	// translation keeps its ID.
+	JobID string       `json:"job_id,omitempty"`
+	Usage usage.Totals `json:"usage"`
+	// ChapterUsage breaks Usage down by chapter ID.
+	ChapterUsage map[string]usage.Totals `json:"chapter_usage,omitempty"`
 }
 
 type EPUBProcessor interface {
@@ -116,10 +144,10 @@ type EPUBProcessor interface {
 }
 
 type LanguageDetector interface {
-	DetectLanguage(text string) (string, error)
+	DetectLanguage(ctx context.Context, text string) (string, error)
 }
 
 type Translator interface {
-	TranslateText(text, sourceLang, targetLang string) (string, error)
-	TranslateHTML(html, sourceLang, targetLang string) (string, error)
+	TranslateText(ctx context.Context, text, sourceLang, targetLang string) (string, error)
+	TranslateHTML(ctx context.Context, html, sourceLang, targetLang string) (string, error)
 }
@@ -0,0 +1,163 @@
+package glossary
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Candidate is a recurring term found in the source text.
+type Candidate struct {
+	Term  string `json:"term"`
+	Count int    `json:"count"`
+	// Context is a sentence in which the term occurs.
+	Context string `json:"context,omitempty"`
+}
+
+// ignoredWords are capitalized words that are almost never terms.
+var ignoredWords = map[string]bool{
+	"i": true, "i'm": true, "i'd": true, "i'll": true, "i've": true,
+	"mr": true, "mrs": true, "ms": true, "dr": true, "st": true,
+	"chapter": true, "part": true, "book": true,
+}
+
+// ExtractCandidates finds proper nouns and acronyms that occur at least
+// minCount times in texts and returns the most frequent maxTerms of them.
+//
+// Capitalized words in the middle of a sentence are taken as proper nouns and
+// consecutive ones are joined ("New York"). A capitalized word at the start of
+// a sentence only counts when the word is also seen capitalized elsewhere.
+func ExtractCandidates(texts []string, minCount, maxTerms int) []Candidate {
+	counts := make(map[string]int)
+	contexts := make(map[string]string)
+	sentenceStarts := make(map[string]int)
+
+	for _, text := range texts {
+		for _, sentence := range splitSentences(text) {
+			words := strings.FieldsFunc(sentence, func(r rune) bool {
+				return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\'' && r != '’' && r != '-'
+			})
+
+			var phrase []string
+			flush := func() {
+				if len(phrase) == 0 {
+					return
+				}
+				term := strings.Join(phrase, " ")
+				counts[term]++
+				if _, exists := contexts[term]; !exists {
+					contexts[term] = strings.TrimSpace(sentence)
+				}
+				phrase = phrase[:0]
+			}
+
+			for i, word := range words {
+				word = strings.TrimSuffix(strings.TrimSuffix(strings.Trim(word, "'’-"), "'s"), "’s")
+				if !isCandidateWord(word) {
+					flush()
+					continue
+				}
+				if i == 0 {
+					sentenceStarts[word]++
+					continue
+				}
+				phrase = append(phrase, word)
+			}
+			flush()
+		}
+	}
+
+	for word, n := range sentenceStarts {
+		if counts[word] > 0 {
+			counts[word] += n
+		}
+	}
+
+	var candidates []Candidate
+	for term, count := range counts {
+		if count < minCount {
+			continue
+		}
+		candidates = append(candidates, Candidate{Term: term, Count: count, Context: truncate(contexts[term], 200)})
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Count != candidates[j].Count {
+			return candidates[i].Count > candidates[j].Count
+		}
+		return candidates[i].Term < candidates[j].Term
+	})
+
+	if maxTerms > 0 && len(candidates) > maxTerms {
+		candidates = candidates[:maxTerms]
+	}
+	return candidates
+}
+
+// CountOccurrences counts the whole-word, case-insensitive occurrences of term.
+func CountOccurrences(texts []string, term string) int {
+	word := strings.ToLower(term)
+	if word == "" {
+		return 0
+	}
+
+	count := 0
+	for _, text := range texts {
+		lower := strings.ToLower(text)
+		for offset := 0; ; {
+			i := strings.Index(lower[offset:], word)
+			if i < 0 {
+				break
+			}
+			start := offset + i
+			if isWholeWord(lower, start, start+len(word)) {
+				count++
+			}
+			offset = start + len(word)
+		}
+	}
+	return count
+}
+
+func isCandidateWord(word string) bool {
+	runes := []rune(word)
+	if len(runes) < 2 || !unicode.IsUpper(runes[0]) {
+		return false
+	}
+	return !ignoredWords[strings.ToLower(word)]
+}
+
+func splitSentences(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool {
+		switch r {
+		case '.', '!', '?', '…', '\n', ':', ';', '"', '“', '”', '«', '»':
+			return true
+		}
+		return false
+	})
+}
+
+func truncate(text string, maxLength int) string {
+	runes := []rune(text)
+	if len(runes) <= maxLength {
+		return text
+	}
+	return string(runes[:maxLength-3]) + "..."
+}
+
+// WriteCSV writes the glossary in the format read by ParseCSV.
+func WriteCSV(w io.Writer, g *Glossary) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"source", "target", "note", "lang"}); err != nil {
+		return err
+	}
+	for _, term := range g.Terms {
+		if err := writer.Write([]string{term.Source, term.Target, term.Note, term.Lang}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,253 @@
+package glossary
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Term is a source term and the translation that must be used for it.
+type Term struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Note   string `json:"note,omitempty"`
+	// Lang restricts the term to one target language; empty means any.
+	Lang string `json:"lang,omitempty"`
+}
+
+// Glossary is a list of required term translations.
+type Glossary struct {
+	Terms []Term `json:"terms"`
+}
+
+// New builds a glossary from terms, dropping incomplete ones. Later terms
+// override earlier terms with the same source and language.
+func New(terms ...Term) *Glossary {
+	g := &Glossary{}
+	g.Merge(&Glossary{Terms: terms})
+	return g
+}
+
+// Merge adds the terms of other, replacing terms with the same source and
+// language.
+func (g *Glossary) Merge(other *Glossary) {
+	if other == nil {
+		return
+	}
+
+	positions := make(map[string]int, len(g.Terms))
+	for i, term := range g.Terms {
+		positions[termKey(term)] = i
+	}
+
+	for _, term := range other.Terms {
+		term.Source = strings.TrimSpace(term.Source)
+		term.Target = strings.TrimSpace(term.Target)
+		term.Note = strings.TrimSpace(term.Note)
+		term.Lang = strings.ToLower(strings.TrimSpace(term.Lang))
+		if term.Source == "" || term.Target == "" {
+			continue
+		}
+
+		key := termKey(term)
+		if i, exists := positions[key]; exists {
+			g.Terms[i] = term
+			continue
+		}
+		positions[key] = len(g.Terms)
+		g.Terms = append(g.Terms, term)
+	}
+}
+
+func termKey(term Term) string {
+	return term.Lang + "\x00" + strings.ToLower(term.Source)
+}
+
+// Len returns the number of terms.
+func (g *Glossary) Len() int {
+	if g == nil {
+		return 0
+	}
+	return len(g.Terms)
+}
+
+// Match returns the terms for targetLang whose source appears in text as a
+// whole word, ignoring case. A term for targetLang wins over a term with the
+// same source that applies to any language. Longer terms come first so that
+// "New York Times" is preferred over "New York" in prompts.
+func (g *Glossary) Match(text, targetLang string) []Term {
+	if g == nil || len(g.Terms) == 0 {
+		return nil
+	}
+
+	targetLang = strings.ToLower(targetLang)
+	lower := strings.ToLower(text)
+	positions := make(map[string]int)
+	var matches []Term
+	for _, term := range g.Terms {
+		if term.Lang != "" && term.Lang != targetLang {
+			continue
+		}
+		if !containsWord(lower, strings.ToLower(term.Source)) {
+			continue
+		}
+
+		source := strings.ToLower(term.Source)
+		if i, exists := positions[source]; exists {
+			if term.Lang != "" {
+				matches[i] = term
+			}
+			continue
+		}
+		positions[source] = len(matches)
+		matches = append(matches, term)
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return len(matches[i].Source) > len(matches[j].Source)
+	})
+	return matches
+}
+
+// Violations returns the terms whose required target does not appear in the
+// translation. The check ignores case and word boundaries because many target
+// languages attach prefixes and suffixes to names.
+func Violations(translated string, terms []Term) []Term {
+	lower := strings.ToLower(translated)
+	var violations []Term
+	for _, term := range terms {
+		if !strings.Contains(lower, strings.ToLower(term.Target)) {
+			violations = append(violations, term)
+		}
+	}
+	return violations
+}
+
+func containsWord(text, word string) bool {
+	for offset := 0; ; {
+		i := strings.Index(text[offset:], word)
+		if i < 0 {
+			return false
+		}
+		start := offset + i
+		if isWholeWord(text, start, start+len(word)) {
+			return true
+		}
+		offset = start + 1
+	}
+}
+
+// isWholeWord reports whether text[start:end] is not part of a longer word.
+func isWholeWord(text string, start, end int) bool {
+	if start > 0 && isWordRune(lastRune(text[:start])) {
+		return false
+	}
+	if end < len(text) && isWordRune([]rune(text[end:])[0]) {
+		return false
+	}
+	return true
+}
+
+func lastRune(s string) rune {
+	runes := []rune(s)
+	return runes[len(runes)-1]
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// Parse reads a glossary in the given format ("csv" or "json").
+func Parse(r io.Reader, format string) (*Glossary, error) {
+	switch strings.ToLower(strings.TrimPrefix(format, ".")) {
+	case "csv":
+		return ParseCSV(r)
+	case "json":
+		return ParseJSON(r)
+	default:
+		return nil, fmt.Errorf("unsupported glossary format %q (use csv or json)", format)
+	}
+}
+
+// Load reads a glossary file, choosing the format from its extension.
+func Load(path string) (*Glossary, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open glossary: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	g, err := Parse(file, filepath.Ext(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read glossary %s: %w", path, err)
+	}
+	return g, nil
+}
+
+// ParseCSV reads rows of source,target[,note[,lang]]. A header row naming the
+// source and target columns is skipped.
+func ParseCSV(r io.Reader) (*Glossary, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	var terms []Term
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+
+		if len(record) < 2 {
+			return nil, fmt.Errorf("line %d: expected at least source and target columns", line)
+		}
+		if line == 1 && strings.EqualFold(strings.TrimSpace(record[0]), "source") && strings.EqualFold(strings.TrimSpace(record[1]), "target") {
+			continue
+		}
+
+		term := Term{Source: record[0], Target: record[1]}
+		if len(record) > 2 {
+			term.Note = record[2]
+		}
+		if len(record) > 3 {
+			term.Lang = record[3]
+		}
+		terms = append(terms, term)
+	}
+
+	return New(terms...), nil
+}
+
+// ParseJSON accepts either {"terms": [...]} or a bare array of terms.
+func ParseJSON(r io.Reader) (*Glossary, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var terms []Term
+	if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, "[") {
+		if err := json.Unmarshal(data, &terms); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+	} else {
+		var g Glossary
+		if err := json.Unmarshal(data, &g); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+		terms = g.Terms
+	}
+
+	return New(terms...), nil
+}
@@ -0,0 +1,133 @@
+package glossary
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	g := New(
+		Term{Source: "Alice", Target: "آلیس"},
+		Term{Source: "New York", Target: "نیویورک"},
+		Term{Source: "New York Times", Target: "نیویورک تایمز"},
+		Term{Source: "Bob", Target: "Robert", Lang: "de"},
+		Term{Source: "Bob", Target: "باب", Lang: "fa"},
+		Term{Source: "Bob", Target: "Bobby"},
+	)
+
+	testCases := []struct {
+		name       string
+		text       string
+		targetLang string
+		expected   []string
+	}{
+		{
+			name:       "Whole words only",
+			text:       "Alicent met Malice.",
+			targetLang: "fa",
+			expected:   nil,
+		},
+		{
+			name:       "Case insensitive with punctuation",
+			text:       "\"ALICE!\" she said.",
+			targetLang: "fa",
+			expected:   []string{"آلیس"},
+		},
+		{
+			name:       "Longer terms first",
+			text:       "She read the New York Times in New York.",
+			targetLang: "fa",
+			expected:   []string{"نیویورک تایمز", "نیویورک"},
+		},
+		{
+			name:       "Language specific term wins",
+			text:       "Bob smiled.",
+			targetLang: "fa",
+			expected:   []string{"باب"},
+		},
+		{
+			name:       "Falls back to term for any language",
+			text:       "Bob smiled.",
+			targetLang: "es",
+			expected:   []string{"Bobby"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			matches := g.Match(tc.text, tc.targetLang)
+
+			if len(matches) != len(tc.expected) {
+				t.Fatalf("Expected %d matches, but got %d: %+v", len(tc.expected), len(matches), matches)
+			}
+			for i, term := range matches {
+				if term.Target != tc.expected[i] {
+					t.Errorf("Match %d does not match.\nExpected: %q\nGot:      %q", i, tc.expected[i], term.Target)
+				}
+			}
+		})
+	}
+}
+
+func TestViolations(t *testing.T) {
+	terms := []Term{
+		{Source: "Alice", Target: "آلیس"},
+		{Source: "Wonderland", Target: "Wunderland"},
+	}
+
+	violations := Violations("و آلیس به wunderland رفت", terms)
+	if len(violations) != 0 {
+		t.Errorf("Expected no violations, got %+v", violations)
+	}
+
+	violations = Violations("و آلیس به سرزمین عجایب رفت", terms)
+	if len(violations) != 1 || violations[0].Source != "Wonderland" {
+		t.Errorf("Expected Wonderland to be reported, got %+v", violations)
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	input := "source,target,note,lang\n# comment\nAlice,آلیس,main character,fa\n\"Smith, John\",جان اسمیت\n , empty source\n"
+
+	g, err := ParseCSV(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseCSV failed: %v", err)
+	}
+
+	expected := []Term{
+		{Source: "Alice", Target: "آلیس", Note: "main character", Lang: "fa"},
+		{Source: "Smith, John", Target: "جان اسمیت"},
+	}
+	if len(g.Terms) != len(expected) {
+		t.Fatalf("Expected %d terms, but got %d: %+v", len(expected), len(g.Terms), g.Terms)
+	}
+	for i, term := range g.Terms {
+		if term != expected[i] {
+			t.Errorf("Term %d does not match.\nExpected: %+v\nGot:      %+v", i, expected[i], term)
+		}
+	}
+}
+
+func TestExtractCandidates(t *testing.T) {
+	texts := []string{
+		"Alice met Bob in New York. Alice smiled.",
+		"The river was cold. Bob's boat drifted to New York.",
+		"Then Alice and Bob left New York. The end.",
+	}
+
+	candidates := ExtractCandidates(texts, 3, 0)
+
+	expected := []Candidate{
+		{Term: "Alice", Count: 3},
+		{Term: "Bob", Count: 3},
+		{Term: "New York", Count: 3},
+	}
+	if len(candidates) != len(expected) {
+		t.Fatalf("Expected %d candidates, but got %d: %+v", len(expected), len(candidates), candidates)
+	}
+	for i, candidate := range candidates {
+		if candidate.Term != expected[i].Term || candidate.Count != expected[i].Count {
+			t.Errorf("Candidate %d does not match.\nExpected: %+v\nGot:      %+v", i, expected[i], candidate)
+		}
+	}
+}
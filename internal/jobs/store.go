@@ -0,0 +1,195 @@
+package jobs
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"epub-translator/internal/epub"
+)
+
+// Store keeps the progress of translation jobs in a JSON-lines journal, so
+// that jobs and their status survive a restart. Every update appends the
+// job's progress; the journal is compacted to one line per job when opened.
+type Store struct {
+	mu   sync.RWMutex
+	path string
+	file *os.File
+	jobs map[string]*epub.TranslationProgress
+}
+
+type record struct {
+	ID       string                    `json:"id"`
+	Deleted  bool                      `json:"deleted,omitempty"`
+	Progress *epub.TranslationProgress `json:"progress,omitempty"`
+}
+
+// Open loads the journal at path, creating the file and its directory if needed.
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create job store directory: %w", err)
+	}
+
+	s := &Store{
+		path: path,
+		jobs: make(map[string]*epub.TranslationProgress),
+	}
+	if err := s.replay(); err != nil {
+		return nil, err
+	}
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open job store: %w", err)
+	}
+	s.file = file
+	return s, nil
+}
+
+func (s *Store) replay() error {
+	file, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open job store: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var rec record
+		// A torn last line from an interrupted write is skipped
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil || rec.ID == "" {
+			continue
+		}
+		if rec.Deleted || rec.Progress == nil {
+			delete(s.jobs, rec.ID)
+			continue
+		}
+		s.jobs[rec.ID] = rec.Progress
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read job store: %w", err)
+	}
+	return nil
+}
+
+// compact rewrites the journal with the latest progress of every job.
+func (s *Store) compact() error {
+	var buf []byte
+	for _, progress := range s.sorted() {
+		data, err := json.Marshal(record{ID: progress.ID, Progress: progress})
+		if err != nil {
+			return err
+		}
+		buf = append(buf, data...)
+		buf = append(buf, '\n')
+	}
+
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, buf, 0644); err != nil {
+		return fmt.Errorf("failed to compact job store: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("failed to compact job store: %w", err)
+	}
+	return nil
+}
+
+// Put records the progress of a job.
+func (s *Store) Put(progress *epub.TranslationProgress) error {
+	progressCopy := *progress
+	data, err := json.Marshal(record{ID: progress.ID, Progress: &progressCopy})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write job: %w", err)
+	}
+	s.jobs[progress.ID] = &progressCopy
+	return nil
+}
+
+// Get returns the progress of the job with the given ID.
+func (s *Store) Get(id string) (*epub.TranslationProgress, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	progress, ok := s.jobs[id]
+	if !ok {
+		return nil, false
+	}
+	progressCopy := *progress
+	return &progressCopy, true
+}
+
+// All returns the progress of every job, oldest first.
+func (s *Store) All() []*epub.TranslationProgress {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []*epub.TranslationProgress
+	for _, progress := range s.sorted() {
+		progressCopy := *progress
+		result = append(result, &progressCopy)
+	}
+	return result
+}
+
+// Delete removes the job with the given ID.
+func (s *Store) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.jobs[id]; !ok {
+		return nil
+	}
+
+	data, err := json.Marshal(record{ID: id, Deleted: true})
+	if err != nil {
+		return err
+	}
+	if _, err := s.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write job: %w", err)
+	}
+	delete(s.jobs, id)
+	return nil
+}
+
+// Path returns the location of the journal.
+func (s *Store) Path() string {
+	return s.path
+}
+
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+func (s *Store) sorted() []*epub.TranslationProgress {
+	result := make([]*epub.TranslationProgress, 0, len(s.jobs))
+	for _, progress := range s.jobs {
+		result = append(result, progress)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].StartedAt.Equal(result[j].StartedAt) {
+			return result[i].StartedAt.Before(result[j].StartedAt)
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
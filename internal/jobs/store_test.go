@@ -0,0 +1,72 @@
+package jobs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"epub-translator/internal/epub"
+)
+
+func TestStoreReplaysAndCompacts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.jsonl")
+
+	store, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	started := time.Now()
+	for i, status := range []string{"in_progress", "in_progress", "completed"} {
+		progress := &epub.TranslationProgress{ID: "book1", Status: status, CompletedChapters: i, StartedAt: started}
+		if err := store.Put(progress); err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+	}
+	if err := store.Put(&epub.TranslationProgress{ID: "book2", Status: "in_progress", StartedAt: started.Add(time.Second)}); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if err := store.Put(&epub.TranslationProgress{ID: "book3", Status: "failed"}); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if err := store.Delete("book3"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// A torn line left by a crash is ignored
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"id":"book2","progress":{"id":"bo`)
+	file.Close()
+
+	store, err = Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer store.Close()
+
+	all := store.All()
+	if len(all) != 2 || all[0].ID != "book1" || all[1].ID != "book2" {
+		t.Fatalf("All() = %+v, want book1 and book2", all)
+	}
+	if progress, ok := store.Get("book1"); !ok || progress.Status != "completed" || progress.CompletedChapters != 2 {
+		t.Errorf("Get(book1) = %+v, %v, want the last update", progress, ok)
+	}
+	if _, ok := store.Get("book3"); ok {
+		t.Errorf("Get(book3) found a deleted job")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 2 {
+		t.Errorf("journal has %d lines after compaction, want 2", lines)
+	}
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"net/http"
+
+	"epub-translator/internal/translation"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) handleGetBookMemory(c *gin.Context) {
+	memory := s.translationSvc.BookMemory(c.Param("id"))
+	if memory == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No book memory"})
+		return
+	}
+	c.JSON(http.StatusOK, memory)
+}
+
+// handleUpdateBookMemory replaces the notes of the book memory. A running
+// translation uses them from its next chapter on.
+func (s *Server) handleUpdateBookMemory(c *gin.Context) {
+	id := c.Param("id")
+
+	if _, exists := s.book(id); !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "EPUB not found"})
+		return
+	}
+
+	var memory translation.BookMemory
+	if err := c.ShouldBindJSON(&memory); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, s.translationSvc.SetBookMemory(id, &memory))
+}
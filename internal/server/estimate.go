@@ -0,0 +1,43 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleEstimate estimates the tokens and cost of translating a book, or some
+// of its chapters, before the translation is started.
+func (s *Server) handleEstimate(c *gin.Context) {
+	id := c.Param("id")
+
+	var request struct {
+		TargetLang string   `json:"target_lang" binding:"required"`
+		Provider   string   `json:"provider"`
+		Chapters   []string `json:"chapters"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	epubContent, exists := s.book(id)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "EPUB not found"})
+		return
+	}
+
+	sourceLang := epubContent.Package.Metadata.Language
+	if sourceLang == "" || sourceLang == "unknown" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Source language not detected. Please try uploading the file again."})
+		return
+	}
+
+	estimate, err := s.translationSvc.EstimateCost(epubContent, sourceLang, request.TargetLang, request.Provider, request.Chapters)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, estimate)
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+
+	"epub-translator/internal/glossary"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleUploadGlossary sets the glossary of a book from an uploaded CSV or
+// JSON file.
+func (s *Server) handleUploadGlossary(c *gin.Context) {
+	id := c.Param("id")
+
+	if _, exists := s.book(id); !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "EPUB not found"})
+		return
+	}
+
+	file, err := c.FormFile("glossary")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
+		return
+	}
+
+	reader, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
+		return
+	}
+	defer func() { _ = reader.Close() }()
+
+	g, err := glossary.Parse(reader, filepath.Ext(file.Filename))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.translationSvc.SetBookGlossary(id, g)
+	s.wsHub.BroadcastLog("info", fmt.Sprintf("Glossary with %d terms loaded for this book", g.Len()), "translation")
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Glossary uploaded",
+		"terms":   g.Len(),
+	})
+}
+
+// handleGetGlossary returns the book's own terms and the global terms that
+// apply to every book.
+func (s *Server) handleGetGlossary(c *gin.Context) {
+	id := c.Param("id")
+
+	bookTerms := []glossary.Term{}
+	if g := s.translationSvc.BookGlossary(id); g != nil {
+		bookTerms = g.Terms
+	}
+
+	globalTerms := []glossary.Term{}
+	if g := s.translationSvc.GlobalGlossary(); g != nil {
+		globalTerms = g.Terms
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"book":   bookTerms,
+		"global": globalTerms,
+	})
+}
+
+func (s *Server) handleDeleteGlossary(c *gin.Context) {
+	s.translationSvc.ClearBookGlossary(c.Param("id"))
+	c.JSON(http.StatusOK, gin.H{"message": "Glossary removed"})
+}
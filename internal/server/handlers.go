@@ -2,6 +2,7 @@ package server
 
 import (
 	"archive/zip"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -13,6 +14,7 @@ import (
 	"time"
 
 	"epub-translator/internal/epub"
+	"epub-translator/internal/translation"
 
 	"github.com/gin-gonic/gin"
 )
@@ -61,14 +63,14 @@ func (s *Server) handleUpload(c *gin.Context) {
 		return
 	}
 
-	sourceLang, err := s.translationSvc.DetectLanguage(epubContent)
+	sourceLang, err := s.translationSvc.DetectLanguage(c.Request.Context(), epubContent)
 	if err != nil {
 		s.logger.Warnf("Language detection failed: %v", err)
 		sourceLang = "unknown"
 	}
 
 	epubContent.Package.Metadata.Language = sourceLang
-	s.epubStorage[epubContent.ID] = epubContent
+	s.storeBook(epubContent)
 
 	s.logger.Infof("Successfully uploaded and processed EPUB: %s (ID: %s)", file.Filename, epubContent.ID)
 
@@ -83,22 +85,12 @@ func (s *Server) handleUpload(c *gin.Context) {
 
 func (s *Server) handlePreview(c *gin.Context) {
 	id := c.Param("id")
-	epubContent, exists := s.epubStorage[id]
+	epubContent, exists := s.book(id)
 	if !exists {
-		// Try to load from disk if not in memory
-		loadedEpub, err := s.epubParser.LoadFromDirectory(id)
-		if err != nil {
-			s.logger.Debugf("Failed to load EPUB from directory %s: %v", id, err)
-			c.HTML(http.StatusNotFound, "error.html", gin.H{
-				"Error": "EPUB not found",
-			})
-			return
-		}
-
-		// Store in memory for future requests
-		s.epubStorage[id] = loadedEpub
-		epubContent = loadedEpub
-		s.logger.Debugf("Successfully loaded EPUB %s from disk", id)
+		c.HTML(http.StatusNotFound, "error.html", gin.H{
+			"Error": "EPUB not found",
+		})
+		return
 	}
 
 	var chapterSummaries []gin.H
@@ -125,6 +117,12 @@ func (s *Server) handleTranslate(c *gin.Context) {
 	var request struct {
 		ID         string `json:"id" binding:"required"`
 		TargetLang string `json:"target_lang" binding:"required"`
+		Provider   string `json:"provider"`
+		// ReviewTerminology runs the terminology pass first and only starts
+		// the translation once its draft glossary is approved.
+		ReviewTerminology bool `json:"review_terminology"`
+		// Budget overrides the configured budget.job_tokens and job_cost.
+		Budget translation.Budget `json:"budget"`
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -132,7 +130,7 @@ func (s *Server) handleTranslate(c *gin.Context) {
 		return
 	}
 
-	epubContent, exists := s.epubStorage[request.ID]
+	epubContent, exists := s.book(request.ID)
 	if !exists {
 		c.JSON(http.StatusNotFound, gin.H{"error": "EPUB not found"})
 		return
@@ -149,7 +147,31 @@ func (s *Server) handleTranslate(c *gin.Context) {
 		return
 	}
 
-	if err := s.translationSvc.StartTranslation(epubContent, sourceLang, request.TargetLang); err != nil {
+	if _, err := s.translationSvc.Provider(request.Provider); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if request.ReviewTerminology {
+		draft := s.startTerminologyPass(epubContent, sourceLang, request.TargetLang, request.Provider, true, request.Budget)
+		c.JSON(http.StatusAccepted, gin.H{
+			"message":         "Awaiting glossary approval",
+			"status":          "awaiting_glossary_approval",
+			"terminology_url": fmt.Sprintf("/api/terminology/%s", request.ID),
+			"draft":           draft,
+		})
+		return
+	}
+
+	if err := s.translationSvc.StartTranslation(s.ctx, epubContent, sourceLang, request.TargetLang, request.Provider, request.Budget); err != nil {
+		if errors.Is(err, translation.ErrJobRunning) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, translation.ErrNoPrice) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		s.logger.Errorf("Failed to start translation: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start translation"})
 		return
@@ -177,10 +199,23 @@ func (s *Server) handleStatus(c *gin.Context) {
 		"status":             progress.Status,
 		"source_language":    progress.SourceLanguage,
 		"target_language":    progress.TargetLanguage,
+		"provider":           progress.Provider,
+		"model":              progress.Model,
 		"total_chapters":     progress.TotalChapters,
 		"completed_chapters": progress.CompletedChapters,
 		"current_chapter":    progress.CurrentChapter,
 		"started_at":         progress.StartedAt,
+		"cache_hits":         progress.CacheHits,
+		"cache_misses":       progress.CacheMisses,
+		"memory_hits":        progress.MemoryHits,
+		"job_id":             progress.JobID,
+		"usage":              progress.Usage,
+		"book_usage":         s.translationSvc.BookUsage(id),
+		"currency":           s.config.Pricing.Currency,
+	}
+
+	if len(progress.ChapterUsage) > 0 {
+		response["chapter_usage"] = progress.ChapterUsage
 	}
 
 	if progress.Status == "completed" {
@@ -192,6 +227,17 @@ func (s *Server) handleStatus(c *gin.Context) {
 		response["error_message"] = progress.ErrorMessage
 	}
 
+	if progress.Status == "paused" || progress.Status == "interrupted" {
+		response["resume_url"] = fmt.Sprintf("/api/jobs/%s/resume", id)
+		if progress.PauseReason != "" {
+			response["pause_reason"] = progress.PauseReason
+		}
+	}
+
+	if len(progress.Issues) > 0 {
+		response["issues"] = progress.Issues
+	}
+
 	if progress.TotalChapters > 0 {
 		response["progress_percentage"] = (float64(progress.CompletedChapters) / float64(progress.TotalChapters)) * 100
 	}
@@ -202,7 +248,7 @@ func (s *Server) handleStatus(c *gin.Context) {
 func (s *Server) handleDownload(c *gin.Context) {
 	id := c.Param("id")
 
-	epubContent, exists := s.epubStorage[id]
+	epubContent, exists := s.book(id)
 	if !exists {
 		c.JSON(http.StatusNotFound, gin.H{"error": "EPUB not found"})
 		return
@@ -243,16 +289,10 @@ func (s *Server) handleDownloadTranslated(c *gin.Context) {
 	}
 
 	// Load EPUB content
-	epubContent, exists := s.epubStorage[id]
+	epubContent, exists := s.book(id)
 	if !exists {
-		// Try to load from disk if not in memory
-		loadedEpub, err := s.epubParser.LoadFromDirectory(id)
-		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "EPUB not found"})
-			return
-		}
-		s.epubStorage[id] = loadedEpub
-		epubContent = loadedEpub
+		c.JSON(http.StatusNotFound, gin.H{"error": "EPUB not found"})
+		return
 	}
 
 	// Build from the translated directory instead of building a new one
@@ -305,15 +345,10 @@ func (s *Server) handleDownloadProcessed(c *gin.Context) {
 	}
 
 	// Load EPUB content
-	epubContent, exists := s.epubStorage[id]
+	epubContent, exists := s.book(id)
 	if !exists {
-		loadedEpub, err := s.epubParser.LoadFromDirectory(id)
-		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "EPUB not found"})
-			return
-		}
-		s.epubStorage[id] = loadedEpub
-		epubContent = loadedEpub
+		c.JSON(http.StatusNotFound, gin.H{"error": "EPUB not found"})
+		return
 	}
 
 	// Use the language-specific translated directory if it exists, otherwise use the original.
@@ -436,16 +471,10 @@ func (s *Server) createEPUBFromDirectory(sourceDir, outputPath string) error {
 func (s *Server) handleGetChapters(c *gin.Context) {
 	id := c.Param("id")
 
-	epubContent, exists := s.epubStorage[id]
+	epubContent, exists := s.book(id)
 	if !exists {
-		// Try to load from disk if not in memory
-		loadedEpub, err := s.epubParser.LoadFromDirectory(id)
-		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "EPUB not found"})
-			return
-		}
-		s.epubStorage[id] = loadedEpub
-		epubContent = loadedEpub
+		c.JSON(http.StatusNotFound, gin.H{"error": "EPUB not found"})
+		return
 	}
 
 	page := 0
@@ -500,13 +529,21 @@ func (s *Server) handleGetChapters(c *gin.Context) {
 func (s *Server) handleDeleteEpub(c *gin.Context) {
 	id := c.Param("id")
 
-	if _, exists := s.epubStorage[id]; !exists {
+	if _, exists := s.book(id); !exists {
 		c.JSON(http.StatusNotFound, gin.H{"error": "EPUB not found"})
 		return
 	}
 
-	delete(s.epubStorage, id)
+	if err := s.translationSvc.CancelTranslation(c.Request.Context(), id); err != nil && !errors.Is(err, translation.ErrJobNotRunning) {
+		s.logger.Warnf("Failed to cancel translation of %s: %v", id, err)
+	}
+
+	s.removeBook(id)
 	s.translationSvc.ClearProgress(id)
+	s.translationSvc.ClearBookGlossary(id)
+	s.translationSvc.ClearTerminologyDraft(id)
+	s.translationSvc.ClearBookMemory(id)
+	s.translationSvc.ClearCheckpoints(id)
 
 	c.JSON(http.StatusOK, gin.H{"message": "EPUB deleted successfully"})
 }
@@ -538,16 +575,10 @@ func (s *Server) handleGetChapter(c *gin.Context) {
 	epubID := c.Param("epub_id")
 	chapterID := c.Param("chapter_id")
 
-	epubContent, exists := s.epubStorage[epubID]
+	epubContent, exists := s.book(epubID)
 	if !exists {
-		// Try to load from disk if not in memory
-		loadedEpub, err := s.epubParser.LoadFromDirectory(epubID)
-		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "EPUB not found"})
-			return
-		}
-		s.epubStorage[epubID] = loadedEpub
-		epubContent = loadedEpub
+		c.JSON(http.StatusNotFound, gin.H{"error": "EPUB not found"})
+		return
 	}
 
 	// Find the specific chapter
@@ -589,7 +620,7 @@ func (s *Server) handleTranslatePage(c *gin.Context) {
 		return
 	}
 
-	epubContent, exists := s.epubStorage[request.EPUBID]
+	epubContent, exists := s.book(request.EPUBID)
 	if !exists {
 		c.JSON(http.StatusNotFound, gin.H{"error": "EPUB not found"})
 		return
@@ -605,7 +636,7 @@ func (s *Server) handleTranslatePage(c *gin.Context) {
 
 	// Perform translation
 	go func() {
-		translatedText, err := s.translationSvc.TranslateText(request.Content, sourceLang, request.TargetLang)
+		translatedText, err := s.translationSvc.TranslateBookText(s.ctx, request.EPUBID, request.Content, sourceLang, request.TargetLang)
 		if err != nil {
 			s.logger.Errorf("Failed to translate page: %v", err)
 			s.wsHub.BroadcastLog("error", fmt.Sprintf("Page translation failed: %v", err), "translation")
@@ -663,22 +694,12 @@ func (s *Server) handleReader(c *gin.Context) {
 	chapter := c.Param("chapter") // Optional chapter parameter
 	mode := c.Param("mode")       // Optional mode parameter: "original", "translated", "side-by-side"
 
-	epubContent, exists := s.epubStorage[id]
+	epubContent, exists := s.book(id)
 	if !exists {
-		// Try to load from disk if not in memory
-		loadedEpub, err := s.epubParser.LoadFromDirectory(id)
-		if err != nil {
-			s.logger.Debugf("Failed to load EPUB from directory %s: %v", id, err)
-			c.HTML(http.StatusNotFound, "error.html", gin.H{
-				"Error": "EPUB not found",
-			})
-			return
-		}
-
-		// Store in memory for future requests
-		s.epubStorage[id] = loadedEpub
-		epubContent = loadedEpub
-		s.logger.Debugf("Successfully loaded EPUB %s from disk", id)
+		c.HTML(http.StatusNotFound, "error.html", gin.H{
+			"Error": "EPUB not found",
+		})
+		return
 	}
 
 	// Set default mode if not specified
@@ -950,14 +971,14 @@ func (s *Server) handleProcessFile(c *gin.Context) {
 	}
 
 	// Detect language
-	sourceLang, err := s.translationSvc.DetectLanguage(epubContent)
+	sourceLang, err := s.translationSvc.DetectLanguage(c.Request.Context(), epubContent)
 	if err != nil {
 		s.logger.Warnf("Language detection failed: %v", err)
 		sourceLang = "unknown"
 	}
 
 	epubContent.Package.Metadata.Language = sourceLang
-	s.epubStorage[epubContent.ID] = epubContent
+	s.storeBook(epubContent)
 
 	filename := filepath.Base(absPath)
 	s.logger.Infof("Successfully processed existing EPUB: %s (ID: %s)", filename, epubContent.ID)
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"epub-translator/internal/translation"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleCancelJob stops the running translation of a book. Requests in flight
+// are aborted and the progress ends up as cancelled; chapters translated so
+// far are kept.
+func (s *Server) handleCancelJob(c *gin.Context) {
+	id := c.Param("id")
+
+	err := s.translationSvc.CancelTranslation(c.Request.Context(), id)
+	if errors.Is(err, translation.ErrJobNotRunning) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No translation running"})
+		return
+	}
+	if err != nil {
+		s.logger.Errorf("Failed to cancel translation: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := gin.H{"message": "Translation cancelled", "status": "cancelled"}
+	if progress := s.translationSvc.GetProgress(id); progress != nil {
+		response["completed_chapters"] = progress.CompletedChapters
+		response["total_chapters"] = progress.TotalChapters
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// handlePauseJob stops the running translation of a book so that it can be
+// resumed later from its checkpoint.
+func (s *Server) handlePauseJob(c *gin.Context) {
+	id := c.Param("id")
+
+	err := s.translationSvc.PauseTranslation(c.Request.Context(), id)
+	if errors.Is(err, translation.ErrJobNotRunning) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No translation running"})
+		return
+	}
+	if err != nil {
+		s.logger.Errorf("Failed to pause translation: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := gin.H{
+		"message":    "Translation paused",
+		"status":     "paused",
+		"resume_url": fmt.Sprintf("/api/jobs/%s/resume", id),
+	}
+	if progress := s.translationSvc.GetProgress(id); progress != nil {
+		response["completed_chapters"] = progress.CompletedChapters
+		response["total_chapters"] = progress.TotalChapters
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// handleResumeJob continues a paused, cancelled or failed translation from its
+// checkpoint. The target language defaults to the one of the last run; a
+// budget raises the limits of a translation paused by its budget.
+func (s *Server) handleResumeJob(c *gin.Context) {
+	id := c.Param("id")
+
+	var request struct {
+		TargetLang string             `json:"target_lang"`
+		Budget     translation.Budget `json:"budget"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	targetLang := request.TargetLang
+	if targetLang == "" {
+		if progress := s.translationSvc.GetProgress(id); progress != nil {
+			targetLang = progress.TargetLanguage
+		}
+	}
+	if targetLang == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "target_lang is required"})
+		return
+	}
+
+	epubContent, exists := s.book(id)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "EPUB not found"})
+		return
+	}
+
+	err := s.translationSvc.ResumeTranslation(s.ctx, epubContent, targetLang, request.Budget)
+	switch {
+	case errors.Is(err, translation.ErrNoCheckpoint):
+		c.JSON(http.StatusNotFound, gin.H{"error": "No checkpoint to resume from"})
+		return
+	case errors.Is(err, translation.ErrJobRunning):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		s.logger.Errorf("Failed to resume translation: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resume translation"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":         "Translation resumed",
+		"status_url":      fmt.Sprintf("/status/%s", id),
+		"target_language": targetLang,
+	})
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleImportTMX adds an uploaded TMX file to the translation memory.
+func (s *Server) handleImportTMX(c *gin.Context) {
+	file, err := c.FormFile("tmx")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
+		return
+	}
+
+	if ext := strings.ToLower(filepath.Ext(file.Filename)); ext != ".tmx" && ext != ".xml" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File must be a TMX document"})
+		return
+	}
+
+	reader, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
+		return
+	}
+	defer func() { _ = reader.Close() }()
+
+	added, err := s.translationSvc.ImportTMX(reader)
+	if err != nil {
+		s.logger.Errorf("Failed to import TMX: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.wsHub.BroadcastLog("info", fmt.Sprintf("Imported %d translation memory entries from %s", added, file.Filename), "translation")
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Translation memory imported",
+		"imported": added,
+	})
+}
+
+// handleExportTMX downloads the translation memory entries of a book as TMX.
+// The optional source_lang and target_lang query parameters narrow the export.
+func (s *Server) handleExportTMX(c *gin.Context) {
+	id := c.Param("id")
+
+	var buf bytes.Buffer
+	count, err := s.translationSvc.ExportTMX(&buf, id, c.Query("source_lang"), c.Query("target_lang"))
+	if err != nil {
+		s.logger.Errorf("Failed to export TMX: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if count == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No translation memory entries for this book"})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.tmx", sanitizeFilename(id)))
+	c.Data(http.StatusOK, "application/x-tmx+xml", buf.Bytes())
+}
@@ -1,7 +1,11 @@
 package server
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"path/filepath"
+	"sync"
 
 	"epub-translator/internal/config"
 	"epub-translator/internal/epub"
@@ -18,34 +22,32 @@ type Server struct {
 	epubBuilder    *epub.Builder
 	translationSvc *translation.Service
 	epubStorage    map[string]*epub.EPUB
+	epubMu         sync.Mutex
 	router         *gin.Engine
 	wsHub          *Hub
+
+	// ctx bounds the background work started by requests; it is cancelled
+	// on shutdown.
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
-func New(cfg *config.Config, logger *logrus.Logger) *Server {
+func New(cfg *config.Config, logger *logrus.Logger) (*Server, error) {
 	gin.SetMode(gin.ReleaseMode)
 
 	epubParser := epub.NewParser(logger, cfg.App.TempDir)
 	epubBuilder := epub.NewBuilder(logger)
 
-	openaiClient := translation.NewOpenAIClient(
-		cfg.OpenAI.APIKey,
-		cfg.OpenAI.Model,
-		cfg.OpenAI.MaxTokens,
-		cfg.OpenAI.Temperature,
-		cfg.Translation.MaxRetries,
-		cfg.Translation.RetryDelay.Duration,
-		logger,
-	)
-
 	// Create WebSocket hub
 	wsHub := NewHub(logger)
-	go wsHub.Run()
 
-	// Set WebSocket broadcaster on OpenAI client for LLM logging
-	openaiClient.SetWebSocketBroadcaster(wsHub)
+	// The hub is also used by the providers for LLM logging
+	translationSvc, err := translation.NewService(cfg, logger, wsHub)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create translation service: %w", err)
+	}
 
-	translationSvc := translation.NewService(openaiClient, logger, cfg.Translation.BatchSize, wsHub)
+	go wsHub.Run()
 
 	s := &Server{
 		config:         cfg,
@@ -56,15 +58,78 @@ func New(cfg *config.Config, logger *logrus.Logger) *Server {
 		epubStorage:    make(map[string]*epub.EPUB),
 		wsHub:          wsHub,
 	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+
+	if err := translationSvc.RestoreJobs(s.ctx, s.book); err != nil {
+		s.cancel()
+		return nil, errors.Join(err, translationSvc.Close())
+	}
 
 	s.setupRoutes()
-	return s
+	return s, nil
 }
 
 func (s *Server) Handler() *gin.Engine {
 	return s.router
 }
 
+// book returns the uploaded EPUB with the given ID. Books stay extracted in
+// the temp directory, so one not in memory, e.g. after a restart, is loaded
+// from there.
+func (s *Server) book(id string) (*epub.EPUB, bool) {
+	s.epubMu.Lock()
+	epubContent, exists := s.epubStorage[id]
+	s.epubMu.Unlock()
+	if exists {
+		return epubContent, true
+	}
+	if id == "" || filepath.Base(id) != id {
+		return nil, false
+	}
+
+	// Loading reads the whole book, so it is done without holding the lock;
+	// a book loaded meanwhile by another request wins
+	loadedEpub, err := s.epubParser.LoadFromDirectory(id)
+	if err != nil {
+		s.logger.Debugf("Failed to load EPUB from directory %s: %v", id, err)
+		return nil, false
+	}
+
+	s.epubMu.Lock()
+	defer s.epubMu.Unlock()
+	if epubContent, exists := s.epubStorage[id]; exists {
+		return epubContent, true
+	}
+	s.epubStorage[id] = loadedEpub
+	s.logger.Debugf("Successfully loaded EPUB %s from disk", id)
+	return loadedEpub, true
+}
+
+func (s *Server) storeBook(epubContent *epub.EPUB) {
+	s.epubMu.Lock()
+	defer s.epubMu.Unlock()
+	s.epubStorage[epubContent.ID] = epubContent
+}
+
+func (s *Server) removeBook(id string) {
+	s.epubMu.Lock()
+	defer s.epubMu.Unlock()
+	delete(s.epubStorage, id)
+}
+
+// Shutdown stops the running translations and background requests, waits for
+// them to wind down until ctx is done, and closes the translation service.
+// Translations are stopped first so that they are recorded as interrupted
+// rather than cancelled.
+func (s *Server) Shutdown(ctx context.Context) error {
+	defer s.cancel()
+	if err := s.translationSvc.Shutdown(ctx); err != nil {
+		return fmt.Errorf("translations did not stop in time: %w", err)
+	}
+	s.cancel()
+	return s.translationSvc.Close()
+}
+
 func (s *Server) setupRoutes() {
 	s.router = gin.New()
 
@@ -111,8 +176,44 @@ func (s *Server) setupRoutes() {
 	s.router.POST("/api/delete-file", s.handleDeleteFile)
 	s.router.POST("/api/process-file", s.handleProcessFile)
 
+	// Translation memory endpoints
+	s.router.POST("/api/tm/import", s.handleImportTMX)
+	s.router.GET("/api/tm/export/:id", s.handleExportTMX)
+
+	// Glossary endpoints
+	s.router.GET("/api/glossary/:id", s.handleGetGlossary)
+	s.router.POST("/api/glossary/:id", s.handleUploadGlossary)
+	s.router.DELETE("/api/glossary/:id", s.handleDeleteGlossary)
+
+	// Terminology endpoints
+	s.router.POST("/api/terminology/:id", s.handleExtractTerminology)
+	s.router.GET("/api/terminology/:id", s.handleGetTerminology)
+	s.router.PUT("/api/terminology/:id", s.handleUpdateTerminology)
+	s.router.POST("/api/terminology/:id/approve", s.handleApproveTerminology)
+
+	// Book memory endpoints
+	s.router.GET("/api/book-memory/:id", s.handleGetBookMemory)
+	s.router.PUT("/api/book-memory/:id", s.handleUpdateBookMemory)
+
+	// Cost estimate endpoint
+	s.router.POST("/api/estimate/:id", s.handleEstimate)
+
+	// Usage report endpoint
+	s.router.GET("/api/usage", s.handleUsageReport)
+
+	// Job endpoints
+	s.router.POST("/api/jobs/:id/cancel", s.handleCancelJob)
+	s.router.POST("/api/jobs/:id/pause", s.handlePauseJob)
+	s.router.POST("/api/jobs/:id/resume", s.handleResumeJob)
+
 	s.router.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{"status": "ok", "websocket_clients": s.wsHub.GetClientCount()})
+		hits, misses := s.translationSvc.CacheStats()
+		c.JSON(200, gin.H{
+			"status":            "ok",
+			"websocket_clients": s.wsHub.GetClientCount(),
+			"cache_hits":        hits,
+			"cache_misses":      misses,
+		})
 	})
 }
 
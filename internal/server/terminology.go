@@ -0,0 +1,119 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"epub-translator/internal/epub"
+	"epub-translator/internal/glossary"
+	"epub-translator/internal/translation"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleExtractTerminology starts the terminology pass for a book. The draft
+// is reported as extracting until the provider has proposed translations.
+func (s *Server) handleExtractTerminology(c *gin.Context) {
+	id := c.Param("id")
+
+	var request struct {
+		TargetLang string `json:"target_lang" binding:"required"`
+		SourceLang string `json:"source_lang"`
+		Provider   string `json:"provider"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	epubContent, exists := s.book(id)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "EPUB not found"})
+		return
+	}
+
+	sourceLang := request.SourceLang
+	if sourceLang == "" {
+		sourceLang = epubContent.Package.Metadata.Language
+	}
+
+	if _, err := s.translationSvc.Provider(request.Provider); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	draft := s.startTerminologyPass(epubContent, sourceLang, request.TargetLang, request.Provider, false, translation.Budget{})
+	c.JSON(http.StatusAccepted, draft)
+}
+
+func (s *Server) startTerminologyPass(book *epub.EPUB, sourceLang, targetLang, provider string, startTranslation bool, budget translation.Budget) *translation.TerminologyDraft {
+	draft := s.translationSvc.PrepareTerminology(book.ID, sourceLang, targetLang, provider, startTranslation, budget)
+
+	go func() {
+		if _, err := s.translationSvc.ExtractTerminology(s.ctx, book, sourceLang, targetLang, provider, startTranslation); err != nil {
+			s.logger.Errorf("Terminology extraction failed: %v", err)
+			s.wsHub.BroadcastLog("error", fmt.Sprintf("Terminology extraction failed: %v", err), "translation")
+		}
+	}()
+
+	return draft
+}
+
+func (s *Server) handleGetTerminology(c *gin.Context) {
+	draft := s.translationSvc.TerminologyDraft(c.Param("id"))
+	if draft == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No terminology draft"})
+		return
+	}
+	c.JSON(http.StatusOK, draft)
+}
+
+// handleUpdateTerminology replaces the draft's terms with the edited list.
+func (s *Server) handleUpdateTerminology(c *gin.Context) {
+	var request struct {
+		Terms []glossary.Term `json:"terms"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	draft, err := s.translationSvc.UpdateTerminologyDraft(c.Param("id"), request.Terms)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, draft)
+}
+
+// handleApproveTerminology adds the draft to the book glossary and starts the
+// translation when it was requested together with the terminology pass.
+func (s *Server) handleApproveTerminology(c *gin.Context) {
+	id := c.Param("id")
+
+	draft, err := s.translationSvc.ApproveTerminologyDraft(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := gin.H{
+		"message": "Terminology approved",
+		"terms":   len(draft.Terms),
+	}
+
+	epubContent, exists := s.book(id)
+	if draft.StartTranslation && exists {
+		if err := s.translationSvc.StartTranslation(s.ctx, epubContent, draft.SourceLang, draft.TargetLang, draft.Provider, draft.Budget); err != nil {
+			s.logger.Errorf("Failed to start translation: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start translation"})
+			return
+		}
+		response["message"] = "Terminology approved, translation started"
+		response["status_url"] = fmt.Sprintf("/status/%s", id)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"epub-translator/internal/usage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleUsageReport adds up the tokens and cost of the LLM requests recorded
+// in the usage ledger. The query selects them by book_id, job_id and a range
+// of days (from and to, inclusive, as YYYY-MM-DD) and group_by breaks the
+// totals down by book, job, chapter, provider, model, request_type or day.
+func (s *Server) handleUsageReport(c *gin.Context) {
+	filter := usage.Filter{
+		BookID: c.Query("book_id"),
+		JobID:  c.Query("job_id"),
+	}
+
+	if from := c.Query("from"); from != "" {
+		day, err := time.ParseInLocation("2006-01-02", from, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be a date like 2006-01-02"})
+			return
+		}
+		filter.From = day
+	}
+	if to := c.Query("to"); to != "" {
+		day, err := time.ParseInLocation("2006-01-02", to, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be a date like 2006-01-02"})
+			return
+		}
+		filter.To = day.AddDate(0, 0, 1)
+	}
+
+	report, err := s.translationSvc.UsageReport(filter, c.Query("group_by"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"currency": s.config.Pricing.Currency,
+		"report":   report,
+	})
+}
@@ -0,0 +1,255 @@
+package tm
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Origins of translation memory entries.
+const (
+	OriginImport  = "import"  // imported from a TMX file
+	OriginMachine = "machine" // produced by a translation provider
+)
+
+// Entry is one source segment and its translation.
+type Entry struct {
+	Source     string    `json:"source"`
+	Target     string    `json:"target"`
+	SourceLang string    `json:"source_lang"`
+	TargetLang string    `json:"target_lang"`
+	Origin     string    `json:"origin"`
+	BookID     string    `json:"book_id,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// use is a line of the file recording that a book reused the entry of a
+// segment recorded for another book.
+type use struct {
+	Source     string `json:"source"`
+	SourceLang string `json:"source_lang"`
+	TargetLang string `json:"target_lang"`
+	UsedBy     string `json:"used_by"`
+}
+
+// Memory is a translation memory backed by a JSON-lines file. Lookups are exact
+// matches on the whitespace-normalized source text and the language pair.
+type Memory struct {
+	mu      sync.RWMutex
+	path    string
+	file    *os.File
+	entries []Entry
+	index   map[string]int
+
+	// usedBy holds the books that reused each segment, by index key
+	usedBy map[string]map[string]bool
+}
+
+// Open loads the memory at path, creating the file and its directory if needed.
+func Open(path string) (*Memory, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create translation memory directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open translation memory: %w", err)
+	}
+
+	m := &Memory{
+		path:   path,
+		file:   file,
+		index:  make(map[string]int),
+		usedBy: make(map[string]map[string]bool),
+	}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var line struct {
+			Entry
+			UsedBy string `json:"used_by"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil || line.Source == "" {
+			continue
+		}
+		if line.UsedBy != "" {
+			m.markUsed(indexKey(line.Source, line.SourceLang, line.TargetLang), line.UsedBy)
+			continue
+		}
+		m.insert(line.Entry)
+	}
+	if err := scanner.Err(); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("failed to read translation memory: %w", err)
+	}
+
+	return m, nil
+}
+
+// Lookup returns the entry translating source from sourceLang to targetLang.
+func (m *Memory) Lookup(source, sourceLang, targetLang string) (Entry, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	i, ok := m.index[indexKey(source, sourceLang, targetLang)]
+	if !ok {
+		return Entry{}, false
+	}
+	return m.entries[i], true
+}
+
+// Add records entries and persists them. Imported entries replace machine
+// translations of the same segment; machine translations never replace
+// imported ones.
+func (m *Memory) Add(entries ...Entry) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var buf []byte
+	added := 0
+	for _, entry := range entries {
+		entry.SourceLang = NormalizeLang(entry.SourceLang)
+		entry.TargetLang = NormalizeLang(entry.TargetLang)
+		if strings.TrimSpace(entry.Source) == "" || strings.TrimSpace(entry.Target) == "" {
+			continue
+		}
+		if entry.CreatedAt.IsZero() {
+			entry.CreatedAt = time.Now()
+		}
+
+		if i, exists := m.index[indexKey(entry.Source, entry.SourceLang, entry.TargetLang)]; exists {
+			existing := m.entries[i]
+			if existing.Target == entry.Target && existing.BookID == entry.BookID {
+				continue
+			}
+			if existing.Origin == OriginImport && entry.Origin != OriginImport && existing.Target != entry.Target {
+				continue
+			}
+		}
+
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return added, err
+		}
+		buf = append(buf, data...)
+		buf = append(buf, '\n')
+
+		m.insert(entry)
+		added++
+	}
+
+	if len(buf) > 0 {
+		if _, err := m.file.Write(buf); err != nil {
+			return added, fmt.Errorf("failed to write translation memory: %w", err)
+		}
+	}
+
+	return added, nil
+}
+
+// Use records that bookID reused the entry translating source, so that the
+// entry is listed among the book's entries without being copied.
+func (m *Memory) Use(source, sourceLang, targetLang, bookID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := indexKey(source, sourceLang, targetLang)
+	if _, exists := m.index[key]; !exists || m.usedBy[key][bookID] {
+		return nil
+	}
+
+	data, err := json.Marshal(use{
+		Source:     source,
+		SourceLang: NormalizeLang(sourceLang),
+		TargetLang: NormalizeLang(targetLang),
+		UsedBy:     bookID,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := m.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write translation memory: %w", err)
+	}
+
+	m.markUsed(key, bookID)
+	return nil
+}
+
+// Entries returns the entries matching the given book and language pair. Empty
+// arguments match everything. Only the latest matching entry per segment is
+// returned, in the order the segments were first recorded.
+func (m *Memory) Entries(bookID, sourceLang, targetLang string) []Entry {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	sourceLang = NormalizeLang(sourceLang)
+	targetLang = NormalizeLang(targetLang)
+
+	positions := make(map[string]int)
+	var result []Entry
+	for _, entry := range m.entries {
+		key := indexKey(entry.Source, entry.SourceLang, entry.TargetLang)
+		if bookID != "" && entry.BookID != bookID && !m.usedBy[key][bookID] {
+			continue
+		}
+		if sourceLang != "" && entry.SourceLang != sourceLang {
+			continue
+		}
+		if targetLang != "" && entry.TargetLang != targetLang {
+			continue
+		}
+
+		if i, seen := positions[key]; seen {
+			result[i] = entry
+			continue
+		}
+		positions[key] = len(result)
+		result = append(result, entry)
+	}
+	return result
+}
+
+// Len returns the number of distinct segments in the memory.
+func (m *Memory) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.index)
+}
+
+func (m *Memory) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.file.Close()
+}
+
+func (m *Memory) insert(entry Entry) {
+	m.entries = append(m.entries, entry)
+	m.index[indexKey(entry.Source, entry.SourceLang, entry.TargetLang)] = len(m.entries) - 1
+}
+
+func (m *Memory) markUsed(key, bookID string) {
+	if m.usedBy[key] == nil {
+		m.usedBy[key] = make(map[string]bool)
+	}
+	m.usedBy[key][bookID] = true
+}
+
+func indexKey(source, sourceLang, targetLang string) string {
+	return NormalizeLang(sourceLang) + "\x00" + NormalizeLang(targetLang) + "\x00" + strings.Join(strings.Fields(source), " ")
+}
+
+// NormalizeLang reduces a language tag such as "en-US" or "pt_BR" to the
+// lower-case primary subtag used throughout the application.
+func NormalizeLang(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if i := strings.IndexAny(lang, "-_"); i > 0 {
+		lang = lang[:i]
+	}
+	return lang
+}
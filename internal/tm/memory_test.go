@@ -0,0 +1,53 @@
+package tm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMemoryUse(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "segments.jsonl")
+	memory, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if _, err := memory.Add(Entry{Source: "Hello", Target: "Bonjour", SourceLang: "en", TargetLang: "fr", Origin: OriginMachine, BookID: "book1"}); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	// Reusing a segment twice records the book once and copies nothing
+	for i := 0; i < 2; i++ {
+		if err := memory.Use("Hello", "en", "fr", "book2"); err != nil {
+			t.Fatalf("Use() error = %v", err)
+		}
+	}
+	if err := memory.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 2 {
+		t.Errorf("memory file has %d lines, want 2", lines)
+	}
+
+	memory, err = Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer memory.Close()
+
+	if entries := memory.Entries("book2", "en", "fr"); len(entries) != 1 || entries[0].Target != "Bonjour" || entries[0].BookID != "book1" {
+		t.Errorf("Entries(book2) = %+v, want the entry of book1", entries)
+	}
+	if entries := memory.Entries("book3", "", ""); len(entries) != 0 {
+		t.Errorf("Entries(book3) = %+v, want none", entries)
+	}
+	if memory.Len() != 1 {
+		t.Errorf("Len() = %d, want 1", memory.Len())
+	}
+}
@@ -0,0 +1,159 @@
+package tm
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+type tmxDocument struct {
+	XMLName xml.Name  `xml:"tmx"`
+	Version string    `xml:"version,attr"`
+	Header  tmxHeader `xml:"header"`
+	Units   []tmxUnit `xml:"body>tu"`
+}
+
+type tmxHeader struct {
+	CreationTool        string `xml:"creationtool,attr"`
+	CreationToolVersion string `xml:"creationtoolversion,attr"`
+	SegType             string `xml:"segtype,attr"`
+	AdminLang           string `xml:"adminlang,attr"`
+	SrcLang             string `xml:"srclang,attr"`
+	DataType            string `xml:"datatype,attr"`
+	OTMF                string `xml:"o-tmf,attr"`
+}
+
+type tmxUnit struct {
+	SrcLang      string       `xml:"srclang,attr,omitempty"`
+	CreationDate string       `xml:"creationdate,attr,omitempty"`
+	Variants     []tmxVariant `xml:"tuv"`
+}
+
+type tmxVariant struct {
+	Lang string `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+	// Old TMX 1.1 files use a plain lang attribute
+	LegacyLang string `xml:"lang,attr,omitempty"`
+	Seg        tmxSeg `xml:"seg"`
+}
+
+type tmxSeg struct {
+	Inner string `xml:",innerxml"`
+}
+
+var (
+	// nativeCodePattern matches inline elements whose content is native markup
+	// of the original document rather than text
+	nativeCodePattern = regexp.MustCompile(`(?s)<(?:bpt|ept|ph|it)\b[^>]*?(?:/>|>.*?</(?:bpt|ept|ph|it)>)`)
+	// inlineTagPattern matches the remaining inline tags (hi, sub, ut, ...)
+	inlineTagPattern = regexp.MustCompile(`<[^>]+>`)
+)
+
+// text returns the segment content with inline markup removed.
+func (s tmxSeg) text() string {
+	text := nativeCodePattern.ReplaceAllString(s.Inner, "")
+	text = inlineTagPattern.ReplaceAllString(text, "")
+	return strings.TrimSpace(html.UnescapeString(text))
+}
+
+func (v tmxVariant) lang() string {
+	if v.Lang != "" {
+		return NormalizeLang(v.Lang)
+	}
+	return NormalizeLang(v.LegacyLang)
+}
+
+// ReadTMX parses a TMX document into memory entries. Each translation unit
+// yields one entry from its source language to every other language; when the
+// source language is "*all*" every pair is produced.
+func ReadTMX(r io.Reader) ([]Entry, error) {
+	var doc tmxDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to parse TMX: %w", err)
+	}
+
+	var entries []Entry
+	for _, unit := range doc.Units {
+		srcLang := unit.SrcLang
+		if srcLang == "" {
+			srcLang = doc.Header.SrcLang
+		}
+		srcLang = NormalizeLang(srcLang)
+
+		createdAt, _ := time.Parse("20060102T150405Z", unit.CreationDate)
+
+		for _, source := range unit.Variants {
+			if srcLang != "*all*" && source.lang() != srcLang {
+				continue
+			}
+			for _, target := range unit.Variants {
+				if target.lang() == source.lang() {
+					continue
+				}
+				entries = append(entries, Entry{
+					Source:     source.Seg.text(),
+					Target:     target.Seg.text(),
+					SourceLang: source.lang(),
+					TargetLang: target.lang(),
+					Origin:     OriginImport,
+					CreatedAt:  createdAt,
+				})
+			}
+		}
+	}
+
+	return entries, nil
+}
+
+// WriteTMX writes entries as a TMX 1.4 document. Entries sharing a source
+// segment and source language are grouped into one translation unit.
+func WriteTMX(w io.Writer, entries []Entry) error {
+	doc := tmxDocument{
+		Version: "1.4",
+		Header: tmxHeader{
+			CreationTool:        "EPUB Translator",
+			CreationToolVersion: "1.0",
+			SegType:             "paragraph",
+			AdminLang:           "en",
+			SrcLang:             "*all*",
+			DataType:            "plaintext",
+			OTMF:                "jsonl",
+		},
+	}
+
+	units := make(map[string]int)
+	for _, entry := range entries {
+		key := entry.SourceLang + "\x00" + entry.Source
+		i, exists := units[key]
+		if !exists {
+			doc.Units = append(doc.Units, tmxUnit{
+				SrcLang:      entry.SourceLang,
+				CreationDate: entry.CreatedAt.UTC().Format("20060102T150405Z"),
+				Variants:     []tmxVariant{newVariant(entry.SourceLang, entry.Source)},
+			})
+			i = len(doc.Units) - 1
+			units[key] = i
+		}
+		doc.Units[i].Variants = append(doc.Units[i].Variants, newVariant(entry.TargetLang, entry.Target))
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return fmt.Errorf("failed to write TMX: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func newVariant(lang, text string) tmxVariant {
+	var escaped strings.Builder
+	_ = xml.EscapeText(&escaped, []byte(text))
+	return tmxVariant{Lang: lang, Seg: tmxSeg{Inner: escaped.String()}}
+}
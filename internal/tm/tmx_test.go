@@ -0,0 +1,99 @@
+package tm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestReadTMX(t *testing.T) {
+	testCases := []struct {
+		name     string
+		tmx      string
+		expected []Entry
+	}{
+		{
+			name: "Header source language",
+			tmx: `<tmx version="1.4"><header srclang="en-US"/><body>
+				<tu><tuv xml:lang="en-US"><seg>Hello</seg></tuv><tuv xml:lang="fa-IR"><seg>سلام</seg></tuv></tu>
+			</body></tmx>`,
+			expected: []Entry{
+				{Source: "Hello", Target: "سلام", SourceLang: "en", TargetLang: "fa"},
+			},
+		},
+		{
+			name: "Inline native codes are dropped",
+			tmx: `<tmx version="1.4"><header srclang="en"/><body>
+				<tu><tuv xml:lang="en"><seg>A <bpt i="1">&lt;b&gt;</bpt>bold<ept i="1">&lt;/b&gt;</ept> word<ph x="1"/></seg></tuv>
+				<tuv xml:lang="de"><seg>Ein <hi>fettes</hi> Wort &amp; mehr</seg></tuv></tu>
+			</body></tmx>`,
+			expected: []Entry{
+				{Source: "A bold word", Target: "Ein fettes Wort & mehr", SourceLang: "en", TargetLang: "de"},
+			},
+		},
+		{
+			name: "All languages as source",
+			tmx: `<tmx version="1.4"><header srclang="*all*"/><body>
+				<tu><tuv xml:lang="en"><seg>Yes</seg></tuv><tuv xml:lang="es"><seg>Sí</seg></tuv></tu>
+			</body></tmx>`,
+			expected: []Entry{
+				{Source: "Yes", Target: "Sí", SourceLang: "en", TargetLang: "es"},
+				{Source: "Sí", Target: "Yes", SourceLang: "es", TargetLang: "en"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			entries, err := ReadTMX(strings.NewReader(tc.tmx))
+			if err != nil {
+				t.Fatalf("ReadTMX failed: %v", err)
+			}
+
+			if len(entries) != len(tc.expected) {
+				t.Fatalf("Expected %d entries, but got %d: %+v", len(tc.expected), len(entries), entries)
+			}
+
+			for i, entry := range entries {
+				want := tc.expected[i]
+				if entry.Source != want.Source || entry.Target != want.Target ||
+					entry.SourceLang != want.SourceLang || entry.TargetLang != want.TargetLang {
+					t.Errorf("Entry %d does not match.\nExpected: %+v\nGot:      %+v", i, want, entry)
+				}
+				if entry.Origin != OriginImport {
+					t.Errorf("Entry %d has origin %q, expected %q", i, entry.Origin, OriginImport)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteTMXRoundTrip(t *testing.T) {
+	entries := []Entry{
+		{Source: "Fish & <chips>", Target: "Poisson & frites", SourceLang: "en", TargetLang: "fr"},
+		{Source: "Fish & <chips>", Target: "Fisch & Pommes", SourceLang: "en", TargetLang: "de"},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteTMX(&buf, entries); err != nil {
+		t.Fatalf("WriteTMX failed: %v", err)
+	}
+
+	if count := strings.Count(buf.String(), "<tu "); count != 1 {
+		t.Errorf("Expected entries to share one translation unit, got %d", count)
+	}
+
+	read, err := ReadTMX(&buf)
+	if err != nil {
+		t.Fatalf("ReadTMX failed: %v", err)
+	}
+
+	if len(read) != len(entries) {
+		t.Fatalf("Expected %d entries, but got %d: %+v", len(entries), len(read), read)
+	}
+	for i, entry := range read {
+		if entry.Source != entries[i].Source || entry.Target != entries[i].Target {
+			t.Errorf("Entry %d does not match.\nExpected: %+v\nGot:      %+v", i, entries[i], entry)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package translation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"epub-translator/internal/config"
+
+	"github.com/sirupsen/logrus"
+)
+
+func init() {
+	RegisterProvider("anthropic", func(cfg *config.Config, logger *logrus.Logger) (Provider, error) {
+		if cfg.Anthropic.APIKey == "" {
+			return nil, fmt.Errorf("Anthropic API key is required but not found in configuration")
+		}
+		client := NewAnthropicClient(
+			cfg.Anthropic.APIKey,
+			cfg.Anthropic.BaseURL,
+			cfg.Anthropic.Version,
+			cfg.Anthropic.Model,
+			cfg.Anthropic.MaxTokens,
+			cfg.Anthropic.Temperature,
+			cfg.Translation.MaxRetries,
+			cfg.Translation.RetryDelay.Duration,
+			logger,
+		)
+		client.structured = cfg.Translation.StructuredOutput
+		client.requestTimeout = cfg.Translation.RequestTimeout.Duration
+		return client, nil
+	})
+}
+
+// AnthropicClient talks to an Anthropic-style messages API.
+type AnthropicClient struct {
+	*llmTranslator
+	httpClient *http.Client
+	apiKey     string
+	baseURL    string
+	version    string
+}
+
+func NewAnthropicClient(apiKey, baseURL, version, model string, maxTokens int, temperature float32, maxRetries int, retryDelay time.Duration, logger *logrus.Logger) *AnthropicClient {
+	c := &AnthropicClient{
+		httpClient: &http.Client{},
+		apiKey:     apiKey,
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		version:    version,
+	}
+	c.llmTranslator = &llmTranslator{
+		name:        "anthropic",
+		backend:     c,
+		logger:      logger,
+		model:       model,
+		maxTokens:   maxTokens,
+		temperature: temperature,
+		maxRetries:  maxRetries,
+		retryDelay:  retryDelay,
+	}
+	return c
+}
+
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type anthropicRequest struct {
+	Model       string               `json:"model"`
+	MaxTokens   int                  `json:"max_tokens"`
+	Temperature float32              `json:"temperature"`
+	Messages    []anthropicMessage   `json:"messages"`
+	Tools       []anthropicTool      `json:"tools,omitempty"`
+	ToolChoice  *anthropicToolChoice `json:"tool_choice,omitempty"`
+}
+
+// anthropicTool describes a tool the model has to call. Structured replies are
+// requested by forcing a call whose input schema is the reply schema.
+type anthropicTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+type anthropicToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+}
+
+type anthropicResponse struct {
+	Content []struct {
+		Type  string          `json:"type"`
+		Text  string          `json:"text"`
+		Input json.RawMessage `json:"input"`
+	} `json:"content"`
+	StopReason string `json:"stop_reason"`
+	Usage      struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+func (c *AnthropicClient) createCompletion(ctx context.Context, chat chatRequest) (*completion, error) {
+	request := anthropicRequest{
+		Model:       c.model,
+		MaxTokens:   c.maxTokens,
+		Temperature: c.temperature,
+	}
+	for _, message := range chat.Messages {
+		request.Messages = append(request.Messages, anthropicMessage{Role: message.Role, Content: message.Content})
+	}
+	if chat.Schema != nil {
+		request.Tools = []anthropicTool{{
+			Name:        chat.Schema.Name,
+			Description: chat.Schema.Description,
+			InputSchema: chat.Schema.Schema,
+		}}
+		request.ToolChoice = &anthropicToolChoice{Type: "tool", Name: chat.Schema.Name}
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/v1/messages", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", c.apiKey)
+	req.Header.Set("anthropic-version", c.version)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var parsed anthropicResponse
+	decodeErr := json.Unmarshal(data, &parsed)
+
+	if resp.StatusCode != http.StatusOK {
+		apiErr := &apiError{
+			StatusCode: resp.StatusCode,
+			Message:    truncateText(string(data), 200),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+		if decodeErr == nil && parsed.Error != nil {
+			apiErr.Type = parsed.Error.Type
+			apiErr.Message = parsed.Error.Message
+		}
+		return nil, apiErr
+	}
+	if decodeErr != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", decodeErr)
+	}
+
+	// A forced tool call carries the structured reply as its input
+	var content strings.Builder
+	var toolInput []byte
+	for _, block := range parsed.Content {
+		switch block.Type {
+		case "text":
+			content.WriteString(block.Text)
+		case "tool_use":
+			toolInput = block.Input
+		}
+	}
+	if toolInput != nil {
+		content.Reset()
+		content.Write(toolInput)
+	}
+
+	return &completion{
+		Content:      content.String(),
+		FinishReason: parsed.StopReason,
+		Usage: Usage{
+			Requests:         1,
+			PromptTokens:     parsed.Usage.InputTokens,
+			CompletionTokens: parsed.Usage.OutputTokens,
+			TotalTokens:      parsed.Usage.InputTokens + parsed.Usage.OutputTokens,
+		},
+	}, nil
+}
@@ -0,0 +1,258 @@
+package translation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"epub-translator/internal/epub"
+)
+
+// BookMemory is the running "book bible" of a translation: what the model has
+// learned about the book so far. It is updated after every chapter and shown
+// to the model while the following chapters are translated. Users may edit it
+// between chapters.
+type BookMemory struct {
+	BookID     string      `json:"book_id"`
+	TargetLang string      `json:"target_lang"`
+	Characters []Character `json:"characters"`
+	Tone       string      `json:"tone"`
+	Phrasings  []Phrasing  `json:"phrasings"`
+	Notes      []string    `json:"notes"`
+	// Chapters is the number of chapters the memory has been updated from.
+	Chapters int `json:"chapters"`
+	// Revision changes on every update, so that an update computed from an
+	// older revision does not overwrite the user's edits.
+	Revision  int       `json:"revision"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Character is a person of the book and how the translation refers to them.
+type Character struct {
+	Name        string `json:"name"`
+	Translation string `json:"translation"`
+	Gender      string `json:"gender"`
+	// Address describes forms of address, e.g. who is spoken to formally.
+	Address string `json:"address"`
+	Notes   string `json:"notes"`
+}
+
+// Phrasing is a recurring expression and the translation chosen for it.
+type Phrasing struct {
+	Source      string `json:"source"`
+	Translation string `json:"translation"`
+}
+
+// MemoryRequest asks the provider to update the memory with a translated chapter.
+type MemoryRequest struct {
+	Memory       *BookMemory
+	ChapterTitle string
+	Paragraphs   []ContextParagraph
+	SourceLang   string
+	TargetLang   string
+}
+
+// IsEmpty reports whether the memory holds anything worth sending to the model.
+func (m *BookMemory) IsEmpty() bool {
+	return m == nil || (len(m.Characters) == 0 && m.Tone == "" && len(m.Phrasings) == 0 && len(m.Notes) == 0)
+}
+
+// promptText renders the memory for a translation prompt.
+func (m *BookMemory) promptText() string {
+	if m.IsEmpty() {
+		return ""
+	}
+
+	var b strings.Builder
+	if len(m.Characters) > 0 {
+		b.WriteString("Characters:\n")
+		for _, character := range m.Characters {
+			details := []string{}
+			for _, detail := range []string{character.Gender, character.Address, character.Notes} {
+				if detail != "" {
+					details = append(details, detail)
+				}
+			}
+			name := character.Name
+			if character.Translation != "" {
+				name += " → " + character.Translation
+			}
+			if len(details) > 0 {
+				fmt.Fprintf(&b, "- %s: %s\n", name, strings.Join(details, "; "))
+			} else {
+				fmt.Fprintf(&b, "- %s\n", name)
+			}
+		}
+	}
+	if m.Tone != "" {
+		fmt.Fprintf(&b, "Tone: %s\n", m.Tone)
+	}
+	if len(m.Phrasings) > 0 {
+		b.WriteString("Recurring phrasings:\n")
+		for _, phrasing := range m.Phrasings {
+			fmt.Fprintf(&b, "- %s → %s\n", phrasing.Source, phrasing.Translation)
+		}
+	}
+	if len(m.Notes) > 0 {
+		b.WriteString("Other notes:\n")
+		for _, note := range m.Notes {
+			fmt.Fprintf(&b, "- %s\n", note)
+		}
+	}
+	return b.String()
+}
+
+// parseBookMemory returns the memory of an update reply.
+func parseBookMemory(content string) (*BookMemory, error) {
+	var memory BookMemory
+	if err := json.Unmarshal([]byte(extractJSON(content, '{', '}')), &memory); err != nil {
+		return nil, fmt.Errorf("reply is not the requested JSON object: %v", err)
+	}
+	return &memory, nil
+}
+
+// BookMemory returns a copy of the book's memory, or nil.
+func (s *Service) BookMemory(bookID string) *BookMemory {
+	s.bookMemoriesMu.RLock()
+	defer s.bookMemoriesMu.RUnlock()
+
+	memory, exists := s.bookMemories[bookID]
+	if !exists {
+		return nil
+	}
+	return memory.copy()
+}
+
+// SetBookMemory replaces the notes of the book's memory with the user's
+// edited version. The next chapter is translated with the new notes.
+func (s *Service) SetBookMemory(bookID string, edited *BookMemory) *BookMemory {
+	s.bookMemoriesMu.Lock()
+	memory, exists := s.bookMemories[bookID]
+	if !exists {
+		memory = &BookMemory{BookID: bookID}
+		s.bookMemories[bookID] = memory
+	}
+	if edited.TargetLang != "" {
+		memory.TargetLang = edited.TargetLang
+	}
+	memory.Characters = edited.Characters
+	memory.Tone = edited.Tone
+	memory.Phrasings = edited.Phrasings
+	memory.Notes = edited.Notes
+	memory.Revision++
+	memory.UpdatedAt = time.Now()
+	updated := memory.copy()
+	s.bookMemoriesMu.Unlock()
+
+	s.saveBookState(bookID)
+	s.broadcastBookMemory(updated)
+	return updated
+}
+
+// memoryParagraphs returns the paragraphs of a chapter sent to update the
+// memory: the start of the chapter when all of it does not fit into
+// book_memory.chapter_chars.
+func (s *Service) memoryParagraphs(paragraphs []ContextParagraph) []ContextParagraph {
+	var sent []ContextParagraph
+	chars := 0
+	for _, paragraph := range paragraphs {
+		chars += len(paragraph.Source) + len(paragraph.Translation)
+		if chars > s.config.BookMemory.ChapterChars && len(sent) > 0 {
+			break
+		}
+		sent = append(sent, paragraph)
+	}
+	return sent
+}
+
+func (s *Service) ClearBookMemory(bookID string) {
+	s.bookMemoriesMu.Lock()
+	delete(s.bookMemories, bookID)
+	s.bookMemoriesMu.Unlock()
+	s.saveBookState(bookID)
+}
+
+// startBookMemory prepares the memory for translating the book into
+// targetLang. A memory kept for another language is started over; one the user
+// prepared before the translation is kept.
+func (s *Service) startBookMemory(bookID, targetLang string) {
+	if !s.config.BookMemory.Enabled {
+		return
+	}
+
+	s.bookMemoriesMu.Lock()
+	memory, exists := s.bookMemories[bookID]
+	if !exists || (memory.TargetLang != "" && memory.TargetLang != targetLang) {
+		s.bookMemories[bookID] = &BookMemory{BookID: bookID, TargetLang: targetLang, UpdatedAt: time.Now()}
+	} else {
+		memory.TargetLang = targetLang
+	}
+	s.bookMemoriesMu.Unlock()
+	s.saveBookState(bookID)
+}
+
+// updateBookMemory asks the provider to fold a translated chapter into the
+// book's memory. Failures only cost consistency, so they are logged and the
+// translation goes on with the previous notes.
+func (s *Service) updateBookMemory(ctx context.Context, job *translationJob, chapter *epub.Chapter, paragraphs []ContextParagraph) {
+	memory := s.BookMemory(job.book.ID)
+	if memory == nil || len(paragraphs) == 0 {
+		return
+	}
+
+	updated, err := job.provider.UpdateMemory(ctx, MemoryRequest{
+		Memory:       memory,
+		ChapterTitle: chapter.Title,
+		Paragraphs:   s.memoryParagraphs(paragraphs),
+		SourceLang:   job.sourceLang,
+		TargetLang:   job.targetLang,
+	})
+	if err != nil {
+		if ctx.Err() == nil {
+			s.logger.Warnf("Failed to update book memory after chapter %s: %v", chapter.Title, err)
+		}
+		return
+	}
+
+	s.bookMemoriesMu.Lock()
+	current, exists := s.bookMemories[job.book.ID]
+	if !exists || current.Revision != memory.Revision {
+		s.bookMemoriesMu.Unlock()
+		s.logger.Infof("Book memory was edited while chapter %s was summarised, keeping the edit", chapter.Title)
+		return
+	}
+	current.Characters = updated.Characters
+	current.Tone = updated.Tone
+	current.Phrasings = updated.Phrasings
+	current.Notes = updated.Notes
+	current.Chapters++
+	current.Revision++
+	current.UpdatedAt = time.Now()
+	result := current.copy()
+	s.bookMemoriesMu.Unlock()
+	s.saveBookState(job.book.ID)
+
+	s.logger.Debugf("Book memory updated after chapter %s: %d characters, %d phrasings", chapter.Title, len(result.Characters), len(result.Phrasings))
+	s.broadcastBookMemory(result)
+}
+
+func (s *Service) broadcastBookMemory(memory *BookMemory) {
+	if s.wsHub != nil {
+		s.wsHub.BroadcastMessage("book_memory", map[string]interface{}{
+			"epub_id":    memory.BookID,
+			"revision":   memory.Revision,
+			"chapters":   memory.Chapters,
+			"characters": len(memory.Characters),
+		})
+	}
+}
+
+func (m *BookMemory) copy() *BookMemory {
+	memoryCopy := *m
+	memoryCopy.Characters = append([]Character(nil), m.Characters...)
+	memoryCopy.Phrasings = append([]Phrasing(nil), m.Phrasings...)
+	memoryCopy.Notes = append([]string(nil), m.Notes...)
+	return &memoryCopy
+}
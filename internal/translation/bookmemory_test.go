@@ -0,0 +1,20 @@
+package translation
+
+import "testing"
+
+func TestBookMemoryPromptText(t *testing.T) {
+	var empty *BookMemory
+	if text := empty.promptText(); text != "" {
+		t.Errorf("Expected no text for a missing memory, but got %q", text)
+	}
+
+	memory, err := parseBookMemory(`Notes: {"characters": [{"name": "Alice", "translation": "Alicia", "gender": "female", "address": "", "notes": "narrator"}], "tone": "wry, past tense", "phrasings": [{"source": "Off with her head", "translation": "Que le corten la cabeza"}], "notes": []}`)
+	if err != nil {
+		t.Fatalf("Failed to parse memory: %v", err)
+	}
+
+	expected := "Characters:\n- Alice → Alicia: female; narrator\nTone: wry, past tense\nRecurring phrasings:\n- Off with her head → Que le corten la cabeza\n"
+	if text := memory.promptText(); text != expected {
+		t.Errorf("Prompt text does not match.\nExpected: %q\nGot:      %q", expected, text)
+	}
+}
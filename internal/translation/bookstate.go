@@ -0,0 +1,120 @@
+package translation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"epub-translator/internal/glossary"
+)
+
+// bookState is what the translation of a book depends on besides its
+// checkpoint: the glossary uploaded for the book, its terminology draft and
+// its book memory. Once RestoreJobs has opened the job store, it is saved next
+// to the job journal whenever one of them changes, so that a translation
+// resumed after a restart goes on with them.
+type bookState struct {
+	Glossary []glossary.Term   `json:"glossary,omitempty"`
+	Draft    *TerminologyDraft `json:"terminology_draft,omitempty"`
+	Memory   *BookMemory       `json:"book_memory,omitempty"`
+}
+
+func (st *bookState) isEmpty() bool {
+	return len(st.Glossary) == 0 && st.Draft == nil && st.Memory == nil
+}
+
+// bookStatePath is where the state of the book is kept.
+func (s *Service) bookStatePath(bookID string) string {
+	return filepath.Join(s.bookStateDir, bookID+".json")
+}
+
+// saveBookState writes the current state of the book, or removes its file
+// once nothing is left. Failures only cost the state after a restart, so they
+// are logged.
+func (s *Service) saveBookState(bookID string) {
+	if s.bookStateDir == "" {
+		return
+	}
+
+	s.bookStatesMu.Lock()
+	defer s.bookStatesMu.Unlock()
+
+	state := &bookState{
+		Draft:  s.TerminologyDraft(bookID),
+		Memory: s.BookMemory(bookID),
+	}
+	if g := s.BookGlossary(bookID); g != nil {
+		state.Glossary = g.Terms
+	}
+
+	if err := writeBookState(s.bookStatePath(bookID), state); err != nil {
+		s.logger.Warnf("Failed to save the state of book %s: %v", bookID, err)
+	}
+}
+
+func writeBookState(path string, state *bookState) error {
+	if state.isEmpty() {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// loadBookStates restores the state of every book saved in dir and keeps
+// saving it there from now on.
+func (s *Service) loadBookStates(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read book state: %w", err)
+		}
+		var state bookState
+		if err := json.Unmarshal(data, &state); err != nil {
+			s.logger.Warnf("Ignoring book state %s: %v", path, err)
+			continue
+		}
+
+		bookID := strings.TrimSuffix(filepath.Base(path), ".json")
+		if len(state.Glossary) > 0 {
+			s.glossariesMu.Lock()
+			s.glossaries[bookID] = glossary.New(state.Glossary...)
+			s.glossariesMu.Unlock()
+		}
+		if state.Draft != nil {
+			s.draftsMu.Lock()
+			s.drafts[bookID] = state.Draft
+			s.draftsMu.Unlock()
+		}
+		if state.Memory != nil {
+			s.bookMemoriesMu.Lock()
+			s.bookMemories[bookID] = state.Memory
+			s.bookMemoriesMu.Unlock()
+		}
+	}
+
+	s.bookStateDir = dir
+	s.logger.Debugf("Restored the state of %d books from %s", len(paths), dir)
+	return nil
+}
@@ -0,0 +1,277 @@
+package translation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"epub-translator/internal/config"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrBudgetExceeded is returned for a request that would take a translation or
+// the day over its budget. The translation is paused with it as the cause.
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// ErrNoPrice reports a cost budget for a model missing from the pricing table.
+var ErrNoPrice = errors.New("model has no price")
+
+// Budget caps the tokens and cost of a translation. Zero fields are not
+// limited.
+type Budget struct {
+	MaxTokens int     `json:"max_tokens,omitempty"`
+	MaxCost   float64 `json:"max_cost,omitempty"`
+}
+
+// Spend is what has been used against a budget.
+type Spend struct {
+	Tokens int     `json:"tokens"`
+	Cost   float64 `json:"cost"`
+}
+
+func (s *Spend) add(other Spend) {
+	s.Tokens += other.Tokens
+	s.Cost += other.Cost
+}
+
+// cost is the price of the given input and output tokens.
+func cost(price config.ModelPrice, inputTokens, outputTokens int) float64 {
+	return float64(inputTokens)/1e6*price.Input + float64(outputTokens)/1e6*price.Output
+}
+
+// budgetAccount charges requests against a budget. Every request reserves its
+// expected use before it is sent and settles with its actual use, so that
+// requests in flight together cannot overshoot the budget.
+type budgetAccount struct {
+	// name is "job" or "daily", for messages
+	name     string
+	currency string
+	price    func(model string) (config.ModelPrice, bool)
+
+	mu       sync.Mutex
+	budget   Budget
+	spent    Spend
+	reserved Spend
+
+	// day and path are set for the daily account, which starts over every day
+	// and is saved after every request
+	day    string
+	path   string
+	logger *logrus.Logger
+}
+
+func newBudgetAccount(name string, budget Budget, spent Spend, cfg *config.Config) *budgetAccount {
+	return &budgetAccount{
+		name:     name,
+		currency: cfg.Pricing.Currency,
+		price:    cfg.ModelPrice,
+		budget:   budget,
+		spent:    spent,
+	}
+}
+
+// openDailyBudget loads the usage of the day from cfg.BudgetPath. It returns
+// nil when no daily limit is set.
+func openDailyBudget(cfg *config.Config, logger *logrus.Logger) (*budgetAccount, error) {
+	budget := Budget{MaxTokens: cfg.Budget.DailyTokens, MaxCost: cfg.Budget.DailyCost}
+	if budget == (Budget{}) {
+		return nil, nil
+	}
+
+	account := newBudgetAccount("daily", budget, Spend{}, cfg)
+	account.path = cfg.BudgetPath()
+	account.logger = logger
+	account.day = today()
+
+	data, err := os.ReadFile(account.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return account, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read daily usage: %w", err)
+	}
+
+	var saved struct {
+		Day string `json:"day"`
+		Spend
+	}
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("failed to parse daily usage %s: %w", account.path, err)
+	}
+	if saved.Day == account.day {
+		account.spent = saved.Spend
+	}
+	return account, nil
+}
+
+func today() string {
+	return time.Now().Format("2006-01-02")
+}
+
+// Spent returns what has been settled so far.
+func (a *budgetAccount) Spent() Spend {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.rollOver()
+	return a.spent
+}
+
+// reserve admits a request to model expected to use expected. It returns the
+// function settling the request with its actual use, or an error wrapping
+// ErrBudgetExceeded if the request would take the account over its budget.
+func (a *budgetAccount) reserve(model string, expected Usage) (func(used Usage), error) {
+	price, _ := a.price(model)
+	want := Spend{Tokens: expected.TotalTokens, Cost: cost(price, expected.PromptTokens, expected.CompletionTokens)}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.rollOver()
+
+	committed := a.spent
+	committed.add(a.reserved)
+	if a.budget.MaxTokens > 0 && committed.Tokens+want.Tokens > a.budget.MaxTokens {
+		return nil, fmt.Errorf("%w: the %s budget of %d tokens would be exceeded (%d used)",
+			ErrBudgetExceeded, a.name, a.budget.MaxTokens, committed.Tokens)
+	}
+	if a.budget.MaxCost > 0 && committed.Cost+want.Cost > a.budget.MaxCost {
+		return nil, fmt.Errorf("%w: the %s budget of %.2f %s would be exceeded (%.4f spent)",
+			ErrBudgetExceeded, a.name, a.budget.MaxCost, a.currency, committed.Cost)
+	}
+	a.reserved.add(want)
+	day := a.day
+
+	return func(used Usage) {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+
+		// A reservation made before midnight no longer counts
+		if a.day == day {
+			a.reserved.add(Spend{Tokens: -want.Tokens, Cost: -want.Cost})
+		}
+		a.rollOver()
+		if used.TotalTokens == 0 {
+			return
+		}
+		a.spent.add(Spend{Tokens: used.TotalTokens, Cost: cost(price, used.PromptTokens, used.CompletionTokens)})
+		a.save()
+	}, nil
+}
+
+// rollOver starts the daily account over on a new day. a.mu must be held.
+func (a *budgetAccount) rollOver() {
+	if a.day == "" {
+		return
+	}
+	if day := today(); day != a.day {
+		a.day = day
+		a.spent = Spend{}
+		a.reserved = Spend{}
+	}
+}
+
+// save writes the usage of the daily account through a temporary file. a.mu
+// must be held.
+func (a *budgetAccount) save() {
+	if a.path == "" {
+		return
+	}
+
+	data, err := json.Marshal(struct {
+		Day string `json:"day"`
+		Spend
+	}{a.day, a.spent})
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(a.path), 0755)
+	}
+	if err == nil {
+		tmpPath := a.path + ".tmp"
+		if err = os.WriteFile(tmpPath, data, 0644); err == nil {
+			err = os.Rename(tmpPath, a.path)
+		}
+	}
+	if err != nil && a.logger != nil {
+		a.logger.Warnf("Failed to save daily usage: %v", err)
+	}
+}
+
+type jobBudgetKey struct{}
+
+// jobBudget is the budget of the translation a request belongs to.
+type jobBudget struct {
+	account *budgetAccount
+	// stop pauses the translation once a request is refused
+	stop context.CancelCauseFunc
+}
+
+// withJobBudget charges the requests sent with ctx to account. The first
+// request refused by it or by the daily budget calls stop with the refusal.
+func withJobBudget(ctx context.Context, account *budgetAccount, stop context.CancelCauseFunc) context.Context {
+	return context.WithValue(ctx, jobBudgetKey{}, &jobBudget{account: account, stop: stop})
+}
+
+// reserveBudget reserves the expected use of a request against the budget of
+// its translation and the daily budget, and returns the function settling it.
+func (t *llmTranslator) reserveBudget(ctx context.Context, expected Usage) (func(used Usage), error) {
+	job, _ := ctx.Value(jobBudgetKey{}).(*jobBudget)
+
+	var accounts []*budgetAccount
+	if job != nil {
+		accounts = append(accounts, job.account)
+	}
+	if t.daily != nil {
+		accounts = append(accounts, t.daily)
+	}
+
+	settles := make([]func(Usage), 0, len(accounts))
+	settle := func(used Usage) {
+		for _, settle := range settles {
+			settle(used)
+		}
+	}
+	for _, account := range accounts {
+		accountSettle, err := account.reserve(t.model, expected)
+		if err != nil {
+			settle(Usage{})
+			if job != nil {
+				job.stop(err)
+			}
+			return nil, err
+		}
+		settles = append(settles, accountSettle)
+	}
+	return settle, nil
+}
+
+// setDailyBudget charges every request to the daily budget account.
+func (t *llmTranslator) setDailyBudget(daily *budgetAccount) {
+	t.daily = daily
+}
+
+// withDefaults fills the limits budget leaves unset from the configuration.
+func withDefaults(budget Budget, cfg *config.Config) Budget {
+	if budget.MaxTokens == 0 {
+		budget.MaxTokens = cfg.Budget.JobTokens
+	}
+	if budget.MaxCost == 0 {
+		budget.MaxCost = cfg.Budget.JobCost
+	}
+	return budget
+}
+
+// checkBudget refuses a translation with a cost limit, of its own or for the
+// day, when the provider's model has no price to enforce it with.
+func (s *Service) checkBudget(provider Provider, budget Budget) error {
+	if budget.MaxCost == 0 && s.config.Budget.DailyCost == 0 {
+		return nil
+	}
+	if _, priced := s.config.ModelPrice(provider.Model()); !priced {
+		return fmt.Errorf("%w: %s needs an entry in pricing.models to enforce the cost budget", ErrNoPrice, provider.Model())
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package translation
+
+import (
+	"context"
+	"errors"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"epub-translator/internal/config"
+
+	"github.com/sirupsen/logrus"
+)
+
+// fixedBackend answers every request with the same usage.
+type fixedBackend struct {
+	usage Usage
+	calls int
+}
+
+func (b *fixedBackend) createCompletion(ctx context.Context, req chatRequest) (*completion, error) {
+	b.calls++
+	return &completion{Content: "ok", Usage: b.usage}, nil
+}
+
+func TestBudgetStopsRequests(t *testing.T) {
+	cfg := config.New()
+	cfg.App.TempDir = t.TempDir()
+	cfg.Budget.DailyTokens = 1000
+	cfg.Pricing.Models = map[string]config.ModelPrice{"test-model": {Input: 1, Output: 2}}
+
+	daily, err := openDailyBudget(cfg, nil)
+	if err != nil {
+		t.Fatalf("openDailyBudget() error = %v", err)
+	}
+	backend := &fixedBackend{usage: Usage{Requests: 1, PromptTokens: 50, CompletionTokens: 30, TotalTokens: 80}}
+	provider := &llmTranslator{name: "test", model: "test-model", backend: backend, daily: daily}
+
+	job := newBudgetAccount("job", Budget{MaxTokens: 200}, Spend{}, cfg)
+	ctx, stop := context.WithCancelCause(context.Background())
+	defer stop(nil)
+	ctx = withJobBudget(ctx, job, stop)
+
+	req := newChatRequest("Translate this.")
+	for i := 0; i < 2; i++ {
+		if _, err := provider.complete(ctx, req); err != nil {
+			t.Fatalf("request %d error = %v", i+1, err)
+		}
+	}
+	// 160 tokens are spent and the third request is expected to use more
+	// than the 40 left
+	req = newChatRequest("Translate this paragraph, which is long enough to need more than forty tokens between its prompt and the reply that is expected for it.")
+	if _, err := provider.complete(ctx, req); !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("third request error = %v, want ErrBudgetExceeded", err)
+	}
+	if backend.calls != 2 {
+		t.Errorf("backend got %d requests, want 2", backend.calls)
+	}
+	if !errors.Is(context.Cause(ctx), ErrBudgetExceeded) {
+		t.Errorf("job context cause = %v, want ErrBudgetExceeded", context.Cause(ctx))
+	}
+
+	spent := job.Spent()
+	if spent.Tokens != 160 || math.Abs(spent.Cost-2*(50e-6+60e-6)) > 1e-12 {
+		t.Errorf("job spent = %+v, want 160 tokens costing 0.00022", spent)
+	}
+
+	// The day's usage survives a restart
+	reopened, err := openDailyBudget(cfg, nil)
+	if err != nil {
+		t.Fatalf("openDailyBudget() error = %v", err)
+	}
+	if got := reopened.Spent(); got.Tokens != 160 {
+		t.Errorf("daily spent after reopening = %+v, want 160 tokens", got)
+	}
+	if reopened.path != filepath.Join(cfg.App.TempDir, "budget.json") {
+		t.Errorf("daily usage path = %s", reopened.path)
+	}
+}
+
+func TestDailyBudgetRefusalIsNotRetried(t *testing.T) {
+	cfg := config.New()
+	cfg.App.TempDir = t.TempDir()
+	cfg.Budget.DailyTokens = 10
+
+	daily, err := openDailyBudget(cfg, nil)
+	if err != nil {
+		t.Fatalf("openDailyBudget() error = %v", err)
+	}
+	backend := &fixedBackend{}
+	// A retry would wait for an hour, so the test only returns in time if the
+	// refusal is final
+	provider := &llmTranslator{name: "test", model: "test-model", backend: backend, daily: daily, maxRetries: 3, retryDelay: time.Hour, logger: logrus.New()}
+
+	// Outside a translation there is no job to pause, so the refusal is the
+	// error of the request
+	_, err = provider.sendWithRetries(context.Background(), newChatRequest("Translate this sentence, which needs more than ten tokens."))
+	if !errors.Is(err, ErrBudgetExceeded) || strings.Contains(err.Error(), "max retries") {
+		t.Fatalf("sendWithRetries() error = %v, want ErrBudgetExceeded without retries", err)
+	}
+	if backend.calls != 0 {
+		t.Errorf("backend got %d requests, want none", backend.calls)
+	}
+}
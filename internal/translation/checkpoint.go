@@ -0,0 +1,229 @@
+package translation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"epub-translator/internal/cache"
+	"epub-translator/internal/epub"
+	"epub-translator/internal/usage"
+
+	"github.com/google/uuid"
+)
+
+// ErrNoCheckpoint reports a resume request for a book without a checkpoint.
+var ErrNoCheckpoint = errors.New("no checkpoint to resume from")
+
+// checkpoint is the saved state of a book translation. Finished chapters are
+// written to the book's _translated_<lang> directory and marked done here;
+// for unfinished chapters the segments translated so far are kept. A paused,
+// cancelled or failed translation resumes from it.
+type checkpoint struct {
+	BookID     string `json:"book_id"`
+	SourceLang string `json:"source_lang"`
+	TargetLang string `json:"target_lang"`
+	Provider   string `json:"provider,omitempty"`
+	// JobID identifies the translation in the usage ledger.
+	JobID string `json:"job_id"`
+	// Budget and Usage carry the budget of the translation and what it has
+	// used by chapter across resumes.
+	Budget Budget                  `json:"budget"`
+	Usage  map[string]usage.Totals `json:"usage,omitempty"`
+	// Chapters holds the state of every chapter started so far by chapter ID.
+	Chapters  map[string]*chapterState `json:"chapters"`
+	UpdatedAt time.Time                `json:"updated_at"`
+
+	path string
+	mu   sync.Mutex
+}
+
+type chapterState struct {
+	Done   bool                    `json:"done"`
+	Issues []epub.TranslationIssue `json:"issues,omitempty"`
+	// Segments holds the translated segments of an unfinished chapter by index.
+	Segments map[int]savedSegment `json:"segments,omitempty"`
+}
+
+type savedSegment struct {
+	// Key identifies the source text, so that a segment whose text changed is
+	// translated again.
+	Key    string                  `json:"key"`
+	Text   string                  `json:"text"`
+	Issues []epub.TranslationIssue `json:"issues,omitempty"`
+}
+
+func newCheckpoint(path, bookID, sourceLang, targetLang, provider string) *checkpoint {
+	return &checkpoint{
+		BookID:     bookID,
+		SourceLang: sourceLang,
+		TargetLang: targetLang,
+		Provider:   provider,
+		JobID:      uuid.New().String(),
+		Chapters:   make(map[string]*chapterState),
+		path:       path,
+	}
+}
+
+func loadCheckpoint(path string) (*checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNoCheckpoint
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+
+	var cp checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint %s: %w", path, err)
+	}
+	if cp.Chapters == nil {
+		cp.Chapters = make(map[string]*chapterState)
+	}
+	if cp.JobID == "" {
+		cp.JobID = uuid.New().String()
+	}
+	cp.path = path
+	return &cp, nil
+}
+
+// save writes the checkpoint through a temporary file, so that a crash leaves
+// either the old or the new state behind.
+func (cp *checkpoint) save() error {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	cp.UpdatedAt = time.Now()
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cp.path), 0755); err != nil {
+		return err
+	}
+	tmpPath := cp.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, cp.path)
+}
+
+func (cp *checkpoint) remove() error {
+	if err := os.Remove(cp.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// setUsage records what the translation has used; the next save keeps it.
+func (cp *checkpoint) setUsage(u *jobUsage) {
+	_, chapters := u.snapshot()
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	cp.Usage = chapters
+}
+
+// chapterDone reports whether the chapter was finished and returns its issues.
+func (cp *checkpoint) chapterDone(chapterID string) ([]epub.TranslationIssue, bool) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	state, exists := cp.Chapters[chapterID]
+	if !exists || !state.Done {
+		return nil, false
+	}
+	return state.Issues, true
+}
+
+// finishChapter marks the chapter done and drops its segments.
+func (cp *checkpoint) finishChapter(chapterID string, issues []epub.TranslationIssue) error {
+	cp.mu.Lock()
+	cp.Chapters[chapterID] = &chapterState{Done: true, Issues: issues}
+	cp.mu.Unlock()
+
+	return cp.save()
+}
+
+// completedChapters returns the number of finished chapters.
+func (cp *checkpoint) completedChapters() int {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	completed := 0
+	for _, state := range cp.Chapters {
+		if state.Done {
+			completed++
+		}
+	}
+	return completed
+}
+
+// chapter returns the checkpoint of one chapter's segments.
+func (cp *checkpoint) chapter(chapterID string) *chapterCheckpoint {
+	return &chapterCheckpoint{cp: cp, chapterID: chapterID}
+}
+
+// chapterCheckpoint restores and records the segments of one chapter. A nil
+// chapterCheckpoint restores and records nothing.
+type chapterCheckpoint struct {
+	cp        *checkpoint
+	chapterID string
+}
+
+// lookup returns the saved translation of the segment at index, or nil.
+func (c *chapterCheckpoint) lookup(index int, req SegmentRequest) *segmentTranslation {
+	if c == nil {
+		return nil
+	}
+
+	c.cp.mu.Lock()
+	defer c.cp.mu.Unlock()
+
+	state, exists := c.cp.Chapters[c.chapterID]
+	if !exists {
+		return nil
+	}
+	saved, exists := state.Segments[index]
+	if !exists || saved.Key != segmentKey(req) {
+		return nil
+	}
+	return &segmentTranslation{Text: saved.Text, Source: fromCheckpoint, Issues: saved.Issues}
+}
+
+// record adds the translation of the segment at index. It is written with the
+// next save.
+func (c *chapterCheckpoint) record(index int, req SegmentRequest, result *segmentTranslation) {
+	if c == nil {
+		return
+	}
+
+	c.cp.mu.Lock()
+	defer c.cp.mu.Unlock()
+
+	state, exists := c.cp.Chapters[c.chapterID]
+	if !exists {
+		state = &chapterState{}
+		c.cp.Chapters[c.chapterID] = state
+	}
+	if state.Segments == nil {
+		state.Segments = make(map[int]savedSegment)
+	}
+	state.Segments[index] = savedSegment{Key: segmentKey(req), Text: result.Text, Issues: result.Issues}
+}
+
+func (c *chapterCheckpoint) save() error {
+	if c == nil {
+		return nil
+	}
+	return c.cp.save()
+}
+
+func segmentKey(req SegmentRequest) string {
+	return cache.Key(req.Text, req.SourceLang, req.TargetLang)
+}
@@ -0,0 +1,53 @@
+package translation
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckpointResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book_checkpoint_fa.json")
+	if _, err := loadCheckpoint(path); !errors.Is(err, ErrNoCheckpoint) {
+		t.Fatalf("loadCheckpoint() of a missing file error = %v, want ErrNoCheckpoint", err)
+	}
+
+	first := SegmentRequest{Text: "Hello", SourceLang: "en", TargetLang: "fa"}
+	second := SegmentRequest{Text: "World", SourceLang: "en", TargetLang: "fa"}
+
+	cp := newCheckpoint(path, "book", "en", "fa", "openai")
+	saved := cp.chapter("ch1")
+	saved.record(0, first, &segmentTranslation{Text: "سلام"})
+	saved.record(1, second, &segmentTranslation{Text: "دنیا"})
+	if err := saved.save(); err != nil {
+		t.Fatalf("save() error = %v", err)
+	}
+	if err := cp.finishChapter("ch0", nil); err != nil {
+		t.Fatalf("finishChapter() error = %v", err)
+	}
+
+	loaded, err := loadCheckpoint(path)
+	if err != nil {
+		t.Fatalf("loadCheckpoint() error = %v", err)
+	}
+	if loaded.Provider != "openai" || loaded.completedChapters() != 1 {
+		t.Errorf("loaded checkpoint = %+v, want provider openai and 1 finished chapter", loaded)
+	}
+	if _, done := loaded.chapterDone("ch1"); done {
+		t.Errorf("chapterDone(ch1) = true for an unfinished chapter")
+	}
+
+	restored := loaded.chapter("ch1")
+	if result := restored.lookup(0, first); result == nil || result.Text != "سلام" || result.Source != fromCheckpoint {
+		t.Errorf("lookup(0) = %+v, want the saved translation", result)
+	}
+	// A segment whose source text changed is translated again
+	if result := restored.lookup(1, SegmentRequest{Text: "Earth", SourceLang: "en", TargetLang: "fa"}); result != nil {
+		t.Errorf("lookup(1) of changed text = %+v, want nil", result)
+	}
+
+	var none *chapterCheckpoint
+	if none.lookup(0, first) != nil || none.save() != nil {
+		t.Errorf("nil chapterCheckpoint should restore and save nothing")
+	}
+}
@@ -0,0 +1,132 @@
+package translation
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// minChunkTokens keeps chunks from becoming uselessly small for models with a
+// tiny completion limit.
+const minChunkTokens = 128
+
+// promptReserveTokens is kept free in the context window for the instructions,
+// glossary, book memory and context paragraphs sent with every chunk.
+const promptReserveTokens = 1500
+
+// chunk is a piece of a text sent in its own request. Separator is the
+// whitespace that followed it in the text and is put back after its translation.
+type chunk struct {
+	Text      string
+	Separator string
+}
+
+var (
+	paragraphBreakPattern = regexp.MustCompile(`\n[ \t\r]*\n\s*`)
+	// A sentence ends with terminal punctuation, optionally followed by closing
+	// quotes or brackets, and then whitespace. CJK terminals need no space.
+	sentenceEndPattern = regexp.MustCompile(`[.!?…؟۔]+["'”’»)\]]*\s+|[。！？]+[」』”’）]*\s*`)
+	wordPattern        = regexp.MustCompile(`(<[^>]+>|<|[^\s<]+)(\s*)`)
+)
+
+// chunkText splits text into chunks of at most budget tokens as counted by
+// count. It breaks between paragraphs or sentences, and only falls back to
+// words, and then characters, for sentences longer than the budget. HTML tags
+// and placeholders are never split.
+func chunkText(text string, budget int, count func(string) int) []chunk {
+	if count(text) <= budget {
+		return []chunk{{Text: text}}
+	}
+
+	// Leading whitespace stays in front of the first chunk
+	body := strings.TrimLeft(text, " \t\r\n")
+	leading := text[:len(text)-len(body)]
+
+	var pieces []chunk
+	var split func(text, separator string, level int)
+	split = func(text, separator string, level int) {
+		if count(text) <= budget || level > 3 {
+			pieces = append(pieces, chunk{Text: text, Separator: separator})
+			return
+		}
+
+		parts := splitLevel(text, level)
+		if len(parts) == 1 {
+			split(text, separator, level+1)
+			return
+		}
+		for i, part := range parts {
+			if i == len(parts)-1 {
+				part.Separator += separator
+			}
+			split(part.Text, part.Separator, level+1)
+		}
+	}
+	split(body, "", 0)
+
+	var chunks []chunk
+	current := chunk{}
+	tokens := 0
+	for _, piece := range pieces {
+		pieceTokens := count(piece.Text)
+		if current.Text != "" && tokens+pieceTokens > budget {
+			chunks = append(chunks, current)
+			current, tokens = chunk{}, 0
+		}
+		current.Text += current.Separator + piece.Text
+		current.Separator = piece.Separator
+		tokens += pieceTokens
+	}
+	if current.Text != "" || len(chunks) == 0 {
+		chunks = append(chunks, current)
+	}
+	chunks[0].Text = leading + chunks[0].Text
+	return chunks
+}
+
+// splitLevel splits text into paragraphs (level 0), sentences (1), words and
+// tags (2) or characters (3), each with the whitespace that follows it.
+func splitLevel(text string, level int) []chunk {
+	switch level {
+	case 0:
+		return splitAfter(text, paragraphBreakPattern)
+	case 1:
+		return splitAfter(text, sentenceEndPattern)
+	case 2:
+		var parts []chunk
+		for _, match := range wordPattern.FindAllStringSubmatch(text, -1) {
+			parts = append(parts, chunk{Text: match[1], Separator: match[2]})
+		}
+		if len(parts) == 0 {
+			return []chunk{{Text: text}}
+		}
+		return parts
+	default:
+		// A tag is never split, even when it is longer than the budget
+		if strings.HasPrefix(text, "<") {
+			return []chunk{{Text: text}}
+		}
+		parts := make([]chunk, 0, utf8.RuneCountInString(text))
+		for _, r := range text {
+			parts = append(parts, chunk{Text: string(r)})
+		}
+		return parts
+	}
+}
+
+// splitAfter splits text after every match of pattern. The whitespace of the
+// match becomes the separator of the part before it.
+func splitAfter(text string, pattern *regexp.Regexp) []chunk {
+	var parts []chunk
+	start := 0
+	for _, match := range pattern.FindAllStringIndex(text, -1) {
+		if match[1] == len(text) {
+			break
+		}
+		end := match[1]
+		body := strings.TrimRight(text[start:end], " \t\r\n")
+		parts = append(parts, chunk{Text: body, Separator: text[start+len(body) : end]})
+		start = end
+	}
+	return append(parts, chunk{Text: text[start:]})
+}
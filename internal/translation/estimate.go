@@ -0,0 +1,268 @@
+package translation
+
+import (
+	"fmt"
+	"strings"
+
+	"epub-translator/internal/config"
+	"epub-translator/internal/epub"
+)
+
+const (
+	// memoryNotesTokens is the size assumed for the book memory notes, which
+	// do not exist before the translation: they are sent with every request
+	// after the first chapter and rewritten after every chapter.
+	memoryNotesTokens = 600
+	// jsonReplyTokens is what the JSON object around a structured reply adds.
+	jsonReplyTokens = 6
+	// batchItemTokens is what the id and fields of a batch reply item add.
+	batchItemTokens = 10
+)
+
+// Estimator is implemented by providers that can work out the requests and
+// tokens of a translation without sending it.
+type Estimator interface {
+	// EstimateSegments returns what translating reqs would use: one batch
+	// request, or for a single segment one request per chunk.
+	EstimateSegments(reqs []SegmentRequest) (Usage, error)
+	// EstimateMemoryUpdate returns what UpdateMemory would use for req.
+	EstimateMemoryUpdate(req MemoryRequest) Usage
+}
+
+// UsageEstimate counts the segments, requests and tokens of a book or chapter.
+type UsageEstimate struct {
+	Segments int `json:"segments"`
+	// CachedSegments are found in the translation memory or cache and are
+	// not sent.
+	CachedSegments int     `json:"cached_segments"`
+	Requests       int     `json:"requests"`
+	InputTokens    int     `json:"input_tokens"`
+	OutputTokens   int     `json:"output_tokens"`
+	Cost           float64 `json:"cost"`
+}
+
+func (u *UsageEstimate) add(other UsageEstimate) {
+	u.Segments += other.Segments
+	u.CachedSegments += other.CachedSegments
+	u.Requests += other.Requests
+	u.InputTokens += other.InputTokens
+	u.OutputTokens += other.OutputTokens
+	u.Cost += other.Cost
+}
+
+// ChapterEstimate is the estimate of one chapter.
+type ChapterEstimate struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	UsageEstimate
+}
+
+// CostEstimate is the expected use and price of translating a book.
+type CostEstimate struct {
+	BookID         string `json:"book_id"`
+	Provider       string `json:"provider"`
+	Model          string `json:"model"`
+	SourceLanguage string `json:"source_language"`
+	TargetLanguage string `json:"target_language"`
+	Currency       string `json:"currency"`
+	// Priced is false when the pricing table has no entry for the model. The
+	// costs are zero then.
+	Priced bool              `json:"priced"`
+	Price  config.ModelPrice `json:"price"`
+	UsageEstimate
+	Chapters []ChapterEstimate `json:"chapters"`
+}
+
+// EstimateCost estimates the requests, tokens and price of translating the
+// book, or only the chapters listed in chapterIDs, with the named provider.
+// The chapters are segmented and batched exactly as for the translation, and
+// the prompts are built as they would be sent; only the replies are guessed
+// from the length of the source text.
+func (s *Service) EstimateCost(epubContent *epub.EPUB, sourceLang, targetLang, providerName string, chapterIDs []string) (*CostEstimate, error) {
+	provider, err := s.Provider(providerName)
+	if err != nil {
+		return nil, err
+	}
+	estimator, ok := provider.(Estimator)
+	if !ok {
+		return nil, fmt.Errorf("provider %s cannot estimate costs", provider.Name())
+	}
+
+	known := make(map[string]bool)
+	for _, chapter := range epubContent.Chapters {
+		known[chapter.ID] = true
+	}
+	selected := make(map[string]bool)
+	for _, id := range chapterIDs {
+		if !known[id] {
+			return nil, fmt.Errorf("chapter %q not found", id)
+		}
+		selected[id] = true
+	}
+
+	price, priced := s.config.ModelPrice(provider.Model())
+	estimate := &CostEstimate{
+		BookID:         epubContent.ID,
+		Provider:       provider.Name(),
+		Model:          provider.Model(),
+		SourceLanguage: sourceLang,
+		TargetLanguage: targetLang,
+		Currency:       s.config.Pricing.Currency,
+		Priced:         priced,
+		Price:          price,
+	}
+
+	for i := range epubContent.Chapters {
+		chapter := &epubContent.Chapters[i]
+		if len(selected) > 0 && !selected[chapter.ID] {
+			continue
+		}
+
+		usage, err := s.estimateChapter(estimator, provider, epubContent, i, sourceLang, targetLang)
+		if err != nil {
+			return nil, fmt.Errorf("failed to estimate chapter %s: %w", chapter.Title, err)
+		}
+		usage.Cost = cost(price, usage.InputTokens, usage.OutputTokens)
+
+		estimate.Chapters = append(estimate.Chapters, ChapterEstimate{ID: chapter.ID, Title: chapter.Title, UsageEstimate: usage})
+		estimate.add(usage)
+	}
+
+	return estimate, nil
+}
+
+// estimateChapter estimates the chapter at index like translateChapters would
+// translate it. The source text stands in for the translations that the
+// context paragraphs and the memory update carry.
+func (s *Service) estimateChapter(estimator Estimator, provider Provider, epubContent *epub.EPUB, index int, sourceLang, targetLang string) (UsageEstimate, error) {
+	var estimate UsageEstimate
+	chapter := &epubContent.Chapters[index]
+	if strings.TrimSpace(chapter.Content) == "" {
+		return estimate, nil
+	}
+
+	_, segments, err := parseChapter(chapter.Content)
+	if err != nil {
+		return estimate, err
+	}
+
+	memory := s.BookMemory(epubContent.ID)
+	reqs := make([]SegmentRequest, len(segments))
+	results := make([]*segmentTranslation, len(segments))
+	var pending []int
+	for i, seg := range segments {
+		reqs[i], _ = segmentRequest(seg, sourceLang, targetLang)
+		reqs[i].Memory = memory
+		reqs[i].Glossary = s.glossaryFor(epubContent.ID).Match(reqs[i].Text, targetLang)
+		results[i] = &segmentTranslation{Text: reqs[i].Text}
+		if s.hasTranslation(provider, reqs[i]) {
+			estimate.CachedSegments++
+			continue
+		}
+		pending = append(pending, i)
+	}
+	estimate.Segments = len(segments)
+
+	var usage Usage
+	for _, batch := range s.batches(reqs, pending) {
+		preceding := precedingContext(reqs, results, batch[0], s.config.Translation.ContextParagraphs)
+		batchReqs := make([]SegmentRequest, len(batch))
+		for j, i := range batch {
+			reqs[i].Context = preceding
+			batchReqs[j] = reqs[i]
+		}
+
+		batchUsage, err := estimator.EstimateSegments(batchReqs)
+		if err != nil {
+			return estimate, err
+		}
+		usage.Add(batchUsage)
+	}
+
+	if s.config.BookMemory.Enabled {
+		// The notes gathered from the earlier chapters go with every request
+		if memory.IsEmpty() && index > 0 {
+			usage.PromptTokens += memoryNotesTokens * usage.Requests
+		}
+		if index < len(epubContent.Chapters)-1 && len(segments) > 0 {
+			paragraphs := make([]ContextParagraph, len(segments))
+			for i := range segments {
+				paragraphs[i] = contextParagraph(reqs[i], results[i])
+			}
+			usage.Add(estimator.EstimateMemoryUpdate(MemoryRequest{
+				Memory:       memory,
+				ChapterTitle: chapter.Title,
+				Paragraphs:   s.memoryParagraphs(paragraphs),
+				SourceLang:   sourceLang,
+				TargetLang:   targetLang,
+			}))
+		}
+	}
+
+	estimate.Requests = usage.Requests
+	estimate.InputTokens = usage.PromptTokens
+	estimate.OutputTokens = usage.CompletionTokens
+	return estimate, nil
+}
+
+// EstimateSegments builds the requests TranslateBatch or TranslateSegment
+// would send for reqs and counts their tokens.
+func (t *llmTranslator) EstimateSegments(reqs []SegmentRequest) (Usage, error) {
+	if len(reqs) == 1 {
+		return t.estimateSegment(reqs[0]), nil
+	}
+
+	chatReq, err := t.batchRequest(reqs)
+	if err != nil {
+		return Usage{}, err
+	}
+	reply := jsonReplyTokens
+	for _, req := range reqs {
+		reply += t.tokenizer().CountTranslation(req.Text, req.TargetLang) + batchItemTokens
+	}
+	return t.estimateUsage(chatReq, reply), nil
+}
+
+func (t *llmTranslator) estimateSegment(req SegmentRequest) Usage {
+	var usage Usage
+	if req.Text == "" {
+		return usage
+	}
+
+	for _, piece := range chunkText(req.Text, t.chunkBudget(), t.tokenizer().Count) {
+		prompt := textTranslationPrompt(req, piece.Text, t.structured)
+		if req.Format == FormatHTML {
+			prompt = htmlTranslationPrompt(req, piece.Text, t.structured)
+		}
+		reply := t.tokenizer().CountTranslation(piece.Text, req.TargetLang)
+		if t.structured {
+			reply += jsonReplyTokens
+		}
+		usage.Add(t.estimateUsage(newChatRequest(prompt), reply))
+	}
+	return usage
+}
+
+// EstimateMemoryUpdate counts the tokens of the memory update request for req.
+// The reply is assumed to be as long as the current notes, or
+// memoryNotesTokens while they are short.
+func (t *llmTranslator) EstimateMemoryUpdate(req MemoryRequest) Usage {
+	reply := t.tokenizer().Count(req.Memory.promptText())
+	if reply < memoryNotesTokens {
+		reply = memoryNotesTokens
+	}
+	if req.Memory == nil {
+		req.Memory = &BookMemory{}
+	}
+	return t.estimateUsage(newChatRequest(memoryUpdatePrompt(req, t.structured)), reply)
+}
+
+// estimateUsage is the usage of one request with the given reply length, which
+// cannot exceed the completion limit.
+func (t *llmTranslator) estimateUsage(req chatRequest, reply int) Usage {
+	prompt := t.promptTokens(req)
+	if t.maxTokens > 0 && reply > t.maxTokens {
+		reply = t.maxTokens
+	}
+	return Usage{Requests: 1, PromptTokens: prompt, CompletionTokens: reply, TotalTokens: prompt + reply}
+}
@@ -0,0 +1,75 @@
+package translation
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"epub-translator/internal/config"
+	"epub-translator/internal/epub"
+	"epub-translator/internal/glossary"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestEstimateCost(t *testing.T) {
+	cfg := config.New()
+	cfg.BookMemory.Enabled = false
+	cfg.Pricing.Models = map[string]config.ModelPrice{
+		"gpt-4o":      {Input: 2.5, Output: 10},
+		"gpt-4o-mini": {Input: 0.15, Output: 0.6},
+	}
+
+	provider := &llmTranslator{name: "openai", model: "gpt-4o-mini-2024-07-18", maxTokens: 2048, structured: true}
+	s := &Service{
+		config:       cfg,
+		logger:       logrus.New(),
+		batchSize:    2,
+		provider:     provider,
+		providers:    make(map[string]Provider),
+		glossaries:   make(map[string]*glossary.Glossary),
+		bookMemories: make(map[string]*BookMemory),
+	}
+
+	book := &epub.EPUB{ID: "book", Chapters: []epub.Chapter{
+		{ID: "ch1", Title: "One", Content: "<html><body><p>The first paragraph.</p><p>The second one.</p><p>A third.</p></body></html>"},
+		{ID: "ch2", Title: "Two", Content: "<html><body><p>" + strings.Repeat("A long sentence. ", 50) + "</p></body></html>"},
+	}}
+
+	estimate, err := s.EstimateCost(book, "en", "fa", "", nil)
+	if err != nil {
+		t.Fatalf("EstimateCost() error = %v", err)
+	}
+	if !estimate.Priced || estimate.Price.Input != 0.15 {
+		t.Errorf("price = %+v, want the gpt-4o-mini price for a dated model name", estimate.Price)
+	}
+	if len(estimate.Chapters) != 2 || estimate.Segments != 4 {
+		t.Fatalf("estimate has %d chapters and %d segments, want 2 and 4", len(estimate.Chapters), estimate.Segments)
+	}
+	// Three paragraphs in batches of two, and one paragraph on its own
+	if estimate.Chapters[0].Requests != 2 || estimate.Chapters[1].Requests != 1 {
+		t.Errorf("requests = %d and %d, want 2 and 1", estimate.Chapters[0].Requests, estimate.Chapters[1].Requests)
+	}
+	if estimate.Requests != 3 || estimate.InputTokens != estimate.Chapters[0].InputTokens+estimate.Chapters[1].InputTokens {
+		t.Errorf("totals = %+v, want the sum of the chapters", estimate.UsageEstimate)
+	}
+	// Persian takes more tokens than the English source
+	if long := estimate.Chapters[1]; long.OutputTokens <= provider.tokenizer().Count(strings.Repeat("A long sentence. ", 50)) {
+		t.Errorf("output tokens of chapter 2 = %d, want more than the source", long.OutputTokens)
+	}
+	wantCost := float64(estimate.InputTokens)/1e6*0.15 + float64(estimate.OutputTokens)/1e6*0.6
+	if math.Abs(estimate.Cost-wantCost) > 1e-9 {
+		t.Errorf("cost = %v, want %v", estimate.Cost, wantCost)
+	}
+
+	selected, err := s.EstimateCost(book, "en", "fa", "", []string{"ch2"})
+	if err != nil {
+		t.Fatalf("EstimateCost(ch2) error = %v", err)
+	}
+	if len(selected.Chapters) != 1 || selected.InputTokens != estimate.Chapters[1].InputTokens {
+		t.Errorf("estimate of ch2 = %+v, want only chapter 2", selected.UsageEstimate)
+	}
+	if _, err := s.EstimateCost(book, "en", "fa", "", []string{"missing"}); err == nil {
+		t.Errorf("EstimateCost() of an unknown chapter succeeded")
+	}
+}
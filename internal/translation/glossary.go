@@ -0,0 +1,73 @@
+package translation
+
+import (
+	"fmt"
+
+	"epub-translator/internal/config"
+	"epub-translator/internal/glossary"
+)
+
+// loadGlobalGlossary combines the glossary file and the inline terms of the
+// configuration. Inline terms win over terms from the file.
+func loadGlobalGlossary(cfg *config.Config) (*glossary.Glossary, error) {
+	g := glossary.New()
+
+	if cfg.Glossary.Path != "" {
+		fromFile, err := glossary.Load(cfg.Glossary.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load global glossary: %w", err)
+		}
+		g.Merge(fromFile)
+	}
+
+	for _, term := range cfg.Glossary.Terms {
+		g.Merge(glossary.New(glossary.Term{
+			Source: term.Source,
+			Target: term.Target,
+			Note:   term.Note,
+			Lang:   term.Lang,
+		}))
+	}
+
+	return g, nil
+}
+
+// GlobalGlossary returns the glossary from the configuration.
+func (s *Service) GlobalGlossary() *glossary.Glossary {
+	return s.globalGlossary
+}
+
+// BookGlossary returns the glossary uploaded for a book, or nil.
+func (s *Service) BookGlossary(bookID string) *glossary.Glossary {
+	s.glossariesMu.RLock()
+	defer s.glossariesMu.RUnlock()
+	return s.glossaries[bookID]
+}
+
+// SetBookGlossary replaces the glossary of a book. Its terms override global
+// terms with the same source.
+func (s *Service) SetBookGlossary(bookID string, g *glossary.Glossary) {
+	s.glossariesMu.Lock()
+	s.glossaries[bookID] = g
+	s.glossariesMu.Unlock()
+	s.saveBookState(bookID)
+}
+
+func (s *Service) ClearBookGlossary(bookID string) {
+	s.glossariesMu.Lock()
+	delete(s.glossaries, bookID)
+	s.glossariesMu.Unlock()
+	s.saveBookState(bookID)
+}
+
+// glossaryFor returns the global glossary extended with the book's terms.
+func (s *Service) glossaryFor(bookID string) *glossary.Glossary {
+	book := s.BookGlossary(bookID)
+	if book.Len() == 0 {
+		return s.globalGlossary
+	}
+
+	merged := glossary.New(s.globalGlossary.Terms...)
+	merged.Merge(book)
+	return merged
+}
@@ -0,0 +1,166 @@
+package translation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"epub-translator/internal/epub"
+	"epub-translator/internal/jobs"
+)
+
+var (
+	// ErrJobRunning reports a translation started for a book that is already
+	// being translated.
+	ErrJobRunning = errors.New("a translation is already running for this book")
+	// ErrJobNotRunning reports a cancel or pause request for a book that is
+	// not being translated.
+	ErrJobNotRunning = errors.New("no translation is running for this book")
+	// ErrPaused is the cause of the cancellation of a paused translation.
+	ErrPaused = errors.New("translation paused")
+	// ErrShutdown is the cause of the cancellation of the translations
+	// running when the service shuts down.
+	ErrShutdown = errors.New("translation interrupted by shutdown")
+)
+
+// runningJob is a book translation in progress.
+type runningJob struct {
+	cancel context.CancelCauseFunc
+	// done is closed once the translation has stopped.
+	done chan struct{}
+}
+
+// beginJob registers the translation of a book. It returns the context of the
+// translation, which CancelTranslation, PauseTranslation and Shutdown cancel,
+// and the function to call once the translation has stopped.
+func (s *Service) beginJob(ctx context.Context, bookID string) (context.Context, func(), error) {
+	s.jobsMu.Lock()
+	defer s.jobsMu.Unlock()
+
+	if _, running := s.jobs[bookID]; running {
+		return nil, nil, ErrJobRunning
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	job := &runningJob{cancel: cancel, done: make(chan struct{})}
+	s.jobs[bookID] = job
+
+	return ctx, func() {
+		cancel(nil)
+		s.jobsMu.Lock()
+		delete(s.jobs, bookID)
+		s.jobsMu.Unlock()
+		close(job.done)
+	}, nil
+}
+
+// CancelTranslation stops the translation of a book: requests in flight are
+// aborted and the progress is marked as cancelled. It waits until the
+// translation has stopped or ctx is done.
+func (s *Service) CancelTranslation(ctx context.Context, bookID string) error {
+	s.logger.Infof("Cancelling translation of book %s", bookID)
+	return s.stopJob(ctx, bookID, context.Canceled)
+}
+
+// PauseTranslation stops the translation of a book like CancelTranslation but
+// marks the progress as paused. ResumeTranslation continues it from its
+// checkpoint.
+func (s *Service) PauseTranslation(ctx context.Context, bookID string) error {
+	s.logger.Infof("Pausing translation of book %s", bookID)
+	return s.stopJob(ctx, bookID, ErrPaused)
+}
+
+// stopJob cancels the translation of a book with cause and waits until it has
+// stopped or ctx is done.
+func (s *Service) stopJob(ctx context.Context, bookID string, cause error) error {
+	s.jobsMu.Lock()
+	job, running := s.jobs[bookID]
+	s.jobsMu.Unlock()
+
+	if !running {
+		return ErrJobNotRunning
+	}
+
+	job.cancel(cause)
+
+	select {
+	case <-job.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown cancels every running translation and waits until they have
+// stopped or ctx is done. Their progress is marked as interrupted.
+func (s *Service) Shutdown(ctx context.Context) error {
+	s.jobsMu.Lock()
+	running := make([]*runningJob, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		job.cancel(ErrShutdown)
+		running = append(running, job)
+	}
+	s.jobsMu.Unlock()
+
+	for _, job := range running {
+		select {
+		case <-job.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// RestoreJobs opens the job store, so that the progress of translations is
+// kept across restarts, and loads the jobs recorded in it together with the
+// glossaries, terminology drafts and memories of the books. Translations that
+// were running when the process stopped are resumed from their checkpoints
+// with ctx if jobs.on_restart is "resume" and load finds the book; the others
+// are marked as interrupted.
+func (s *Service) RestoreJobs(ctx context.Context, load func(bookID string) (*epub.EPUB, bool)) error {
+	store, err := jobs.Open(s.config.JobStorePath())
+	if err != nil {
+		return fmt.Errorf("failed to open job store: %w", err)
+	}
+
+	records := store.All()
+	s.progressMu.Lock()
+	for _, progress := range records {
+		s.progress[progress.ID] = progress
+	}
+	s.jobStore = store
+	s.progressMu.Unlock()
+	s.logger.Debugf("Job store %s loaded with %d jobs", store.Path(), len(records))
+
+	if err := s.loadBookStates(filepath.Join(filepath.Dir(store.Path()), "book_state")); err != nil {
+		return err
+	}
+
+	for _, progress := range records {
+		if progress.Status != "in_progress" && progress.Status != "interrupted" {
+			continue
+		}
+
+		if s.config.Jobs.OnRestart == "resume" {
+			if epubContent, ok := load(progress.ID); ok {
+				err := s.ResumeTranslation(ctx, epubContent, progress.TargetLanguage, Budget{})
+				if err == nil {
+					continue
+				}
+				s.logger.Warnf("Failed to resume translation of book %s: %v", progress.ID, err)
+			} else {
+				s.logger.Warnf("Cannot resume translation of book %s: book not found", progress.ID)
+			}
+		}
+
+		if progress.Status == "in_progress" {
+			s.logger.Infof("Translation of book %s was interrupted after %d/%d chapters",
+				progress.ID, progress.CompletedChapters, progress.TotalChapters)
+			progress.Status = "interrupted"
+			s.setProgress(progress.ID, progress)
+		}
+	}
+	return nil
+}
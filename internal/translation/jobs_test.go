@@ -0,0 +1,82 @@
+package translation
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"epub-translator/internal/config"
+	"epub-translator/internal/epub"
+	"epub-translator/internal/glossary"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestCancelTranslation(t *testing.T) {
+	s := &Service{logger: logrus.New(), jobs: make(map[string]*runningJob)}
+
+	ctx, done, err := s.beginJob(context.Background(), "book")
+	if err != nil {
+		t.Fatalf("beginJob() error = %v", err)
+	}
+	if _, _, err := s.beginJob(context.Background(), "book"); !errors.Is(err, ErrJobRunning) {
+		t.Fatalf("second beginJob() error = %v, want ErrJobRunning", err)
+	}
+
+	go func() {
+		<-ctx.Done()
+		done()
+	}()
+
+	waitCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := s.CancelTranslation(waitCtx, "book"); err != nil {
+		t.Fatalf("CancelTranslation() error = %v", err)
+	}
+	if err := s.CancelTranslation(waitCtx, "book"); !errors.Is(err, ErrJobNotRunning) {
+		t.Errorf("CancelTranslation() of a stopped job error = %v, want ErrJobNotRunning", err)
+	}
+}
+
+func TestBookStateSurvivesRestart(t *testing.T) {
+	cfg := config.New()
+	cfg.App.TempDir = t.TempDir()
+	newService := func() *Service {
+		s := &Service{
+			config:       cfg,
+			logger:       logrus.New(),
+			progress:     make(map[string]*epub.TranslationProgress),
+			glossaries:   make(map[string]*glossary.Glossary),
+			drafts:       make(map[string]*TerminologyDraft),
+			bookMemories: make(map[string]*BookMemory),
+		}
+		if err := s.RestoreJobs(context.Background(), func(string) (*epub.EPUB, bool) { return nil, false }); err != nil {
+			t.Fatalf("RestoreJobs() error = %v", err)
+		}
+		t.Cleanup(func() { _ = s.jobStore.Close() })
+		return s
+	}
+
+	s := newService()
+	s.SetBookGlossary("book", glossary.New(glossary.Term{Source: "Holmes", Target: "هولمز"}))
+	s.PrepareTerminology("book", "en", "fa", "", true, Budget{})
+	s.startBookMemory("book", "fa")
+	s.SetBookMemory("book", &BookMemory{Tone: "formal"})
+	s.startBookMemory("other", "fa")
+	s.ClearBookMemory("other")
+
+	restarted := newService()
+	if g := restarted.BookGlossary("book"); g.Len() != 1 || g.Terms[0].Target != "هولمز" {
+		t.Errorf("BookGlossary() = %+v, want the glossary saved before the restart", g)
+	}
+	if draft := restarted.TerminologyDraft("book"); draft == nil || draft.TargetLang != "fa" || !draft.StartTranslation {
+		t.Errorf("TerminologyDraft() = %+v, want the draft saved before the restart", draft)
+	}
+	if memory := restarted.BookMemory("book"); memory == nil || memory.Tone != "formal" || memory.TargetLang != "fa" {
+		t.Errorf("BookMemory() = %+v, want the memory saved before the restart", memory)
+	}
+	if restarted.BookMemory("other") != nil {
+		t.Errorf("Expected the cleared memory to stay cleared")
+	}
+}
@@ -0,0 +1,775 @@
+package translation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"epub-translator/internal/glossary"
+	"epub-translator/internal/usage"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// defaultRequestTimeout limits an attempt when no timeout is configured.
+const defaultRequestTimeout = 60 * time.Second
+
+// completion is the reply to a single chat request.
+type completion struct {
+	Content      string
+	FinishReason string
+	Usage        Usage
+}
+
+// truncated reports whether the model stopped at its output token limit
+// ("length" for OpenAI, "max_tokens" for Anthropic).
+func (c *completion) truncated() bool {
+	return c.FinishReason == "length" || c.FinishReason == "max_tokens"
+}
+
+// chatBackend sends one request to a chat-style model. It performs exactly one
+// attempt; retries, validation, logging and usage accounting are handled by
+// llmTranslator.
+type chatBackend interface {
+	createCompletion(ctx context.Context, req chatRequest) (*completion, error)
+}
+
+// llmTranslator implements the provider behaviour shared by every chat model:
+// chunking, prompts, retries, LLM logging and usage accounting. Providers embed
+// it and supply the transport through chatBackend.
+type llmTranslator struct {
+	name        string
+	backend     chatBackend
+	logger      *logrus.Logger
+	model       string
+	maxTokens   int
+	temperature float32
+	maxRetries  int
+	retryDelay  time.Duration
+	// requestTimeout limits each attempt on its own.
+	requestTimeout time.Duration
+	// structured asks for JSON replies that follow a schema and validates them.
+	structured bool
+	wsHub      WebSocketBroadcaster
+	// scheduler is shared by all providers of the process; nil means no limits.
+	scheduler *Scheduler
+	// daily is the daily budget shared by all providers; nil means no limit.
+	daily *budgetAccount
+	// recorder writes every request to the usage ledger; nil means none.
+	recorder *usageRecorder
+
+	usageMu sync.Mutex
+	usage   Usage
+}
+
+func (t *llmTranslator) Name() string {
+	return t.name
+}
+
+func (t *llmTranslator) Model() string {
+	return t.model
+}
+
+// SetWebSocketBroadcaster sets the WebSocket broadcaster for LLM logging
+func (t *llmTranslator) SetWebSocketBroadcaster(wsHub WebSocketBroadcaster) {
+	t.wsHub = wsHub
+}
+
+// SetScheduler makes every request wait for admission by scheduler.
+func (t *llmTranslator) SetScheduler(scheduler *Scheduler) {
+	t.scheduler = scheduler
+}
+
+func (t *llmTranslator) Usage() Usage {
+	t.usageMu.Lock()
+	defer t.usageMu.Unlock()
+	return t.usage
+}
+
+func (t *llmTranslator) recordUsage(usage Usage) {
+	t.usageMu.Lock()
+	defer t.usageMu.Unlock()
+	t.usage.Add(usage)
+}
+
+func (t *llmTranslator) DetectLanguage(ctx context.Context, text string) (string, error) {
+	prompt := languageDetectionPrompt(text, t.structured)
+
+	requestContext := map[string]interface{}{
+		"input_length":  len(text),
+		"input_preview": truncateText(text, 100),
+	}
+
+	if t.structured {
+		req := newChatRequest(prompt)
+		req.Schema = languageSchema
+		response, err := t.makeRequestWithType(ctx, req, "language_detection", requestContext, func(content string) error {
+			_, err := parseLanguage(content)
+			return err
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to detect language: %w", err)
+		}
+		lang, _ := parseLanguage(response.Content)
+		t.logger.Debugf("Detected language: %s", lang)
+		return lang, nil
+	}
+
+	response, err := t.makeRequestWithType(ctx, newChatRequest(prompt), "language_detection", requestContext, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to detect language: %w", err)
+	}
+
+	lang := strings.TrimSpace(strings.ToLower(response.Content))
+	if len(lang) > 3 {
+		lang = lang[:2]
+	}
+
+	t.logger.Debugf("Detected language: %s", lang)
+	return lang, nil
+}
+
+// ProposeTerms asks the model for glossary translations of the candidates.
+func (t *llmTranslator) ProposeTerms(ctx context.Context, req TermRequest) ([]glossary.Term, error) {
+	if len(req.Candidates) == 0 && req.MaxExtra == 0 {
+		return nil, nil
+	}
+
+	requestContext := map[string]interface{}{
+		"source_lang": req.SourceLang,
+		"target_lang": req.TargetLang,
+		"candidates":  len(req.Candidates),
+	}
+
+	response, err := t.makeRequestWithType(ctx, newChatRequest(termProposalPrompt(req)), "terminology", requestContext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to propose terms: %w", err)
+	}
+
+	var terms []glossary.Term
+	if err := json.Unmarshal([]byte(extractJSON(response.Content, '[', ']')), &terms); err != nil {
+		return nil, fmt.Errorf("failed to parse proposed terms: %w", err)
+	}
+	return terms, nil
+}
+
+// UpdateMemory asks the model to update the book memory with a translated
+// chapter.
+func (t *llmTranslator) UpdateMemory(ctx context.Context, req MemoryRequest) (*BookMemory, error) {
+	requestContext := map[string]interface{}{
+		"source_lang": req.SourceLang,
+		"target_lang": req.TargetLang,
+		"chapter":     req.ChapterTitle,
+		"paragraphs":  len(req.Paragraphs),
+	}
+
+	chatReq := newChatRequest(memoryUpdatePrompt(req, t.structured))
+	if t.structured {
+		chatReq.Schema = memorySchema
+	}
+
+	response, err := t.makeRequestWithType(ctx, chatReq, "book_memory", requestContext, func(content string) error {
+		_, err := parseBookMemory(content)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update book memory: %w", err)
+	}
+	return parseBookMemory(response.Content)
+}
+
+// extractJSON returns the outermost JSON value delimited by open and closeChar,
+// dropping the code fences and prose models like to wrap around it.
+func extractJSON(content string, open, closeChar byte) string {
+	start := strings.IndexByte(content, open)
+	end := strings.LastIndexByte(content, closeChar)
+	if start < 0 || end < start {
+		return content
+	}
+	return content[start : end+1]
+}
+
+// TranslateText translates plain text, satisfying epub.Translator.
+func (t *llmTranslator) TranslateText(ctx context.Context, text, sourceLang, targetLang string) (string, error) {
+	result, err := t.TranslateSegment(ctx, SegmentRequest{Text: text, SourceLang: sourceLang, TargetLang: targetLang, Format: FormatText})
+	if err != nil {
+		return "", err
+	}
+	return result.Text, nil
+}
+
+// TranslateHTML translates an HTML fragment, satisfying epub.Translator. Each
+// block is translated on its own with its inline markup sent as placeholders,
+// so the markup is restored by the segmenter rather than left to the model.
+func (t *llmTranslator) TranslateHTML(ctx context.Context, htmlContent, sourceLang, targetLang string) (string, error) {
+	body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(htmlContent), body)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse HTML: %w", err)
+	}
+	for _, node := range nodes {
+		body.AppendChild(node)
+	}
+
+	for _, seg := range collectSegments(body) {
+		req, tags := segmentRequest(seg, sourceLang, targetLang)
+		leading, trailing := surroundingSpace(seg.Text())
+
+		result, err := t.TranslateSegment(ctx, req)
+		if err != nil {
+			return "", err
+		}
+		for attempt := 0; attempt < correctionRetries; attempt++ {
+			failures := checkTranslation(req, result)
+			if len(failures) == 0 {
+				break
+			}
+			retry := req
+			for _, failure := range failures {
+				retry.Corrections = append(retry.Corrections, failure.Correction)
+			}
+			if result, err = t.TranslateSegment(ctx, retry); err != nil {
+				return "", err
+			}
+		}
+
+		if err := seg.Restore(req.Text, leading+strings.TrimSpace(result.Text)+trailing, tags); err != nil {
+			t.logger.Warnf("Inline markup could not be restored: %v", err)
+		}
+	}
+
+	var b strings.Builder
+	for node := body.FirstChild; node != nil; node = node.NextSibling {
+		if err := html.Render(&b, node); err != nil {
+			return "", fmt.Errorf("failed to render HTML: %w", err)
+		}
+	}
+	return b.String(), nil
+}
+
+// ChunkTranslationResult represents the result of translating a single chunk
+type ChunkTranslationResult struct {
+	ChunkID          string
+	Index            int
+	TranslatedText   string
+	Usage            Usage
+	Truncated        bool
+	Error            error
+	TranslationJobID string
+}
+
+// TranslateSegment splits large segments into chunks, translates the chunks
+// concurrently and reassembles them in order.
+func (t *llmTranslator) TranslateSegment(ctx context.Context, req SegmentRequest) (*SegmentResult, error) {
+	if req.Text == "" {
+		return &SegmentResult{}, nil
+	}
+
+	requestType := "text_translation"
+	label := "Text"
+	if req.Format == FormatHTML {
+		requestType = "html_translation"
+		label = "HTML content"
+	}
+
+	translationJobID := uuid.New().String()
+	chunks := chunkText(req.Text, t.chunkBudget(), t.tokenizer().Count)
+
+	if len(chunks) > 1 {
+		t.logger.Infof("%s is large and has been split into %d chunks for translation (Job ID: %s)", label, len(chunks), translationJobID)
+	}
+
+	// Use a slice to collect translation results in order
+	results := make([]ChunkTranslationResult, len(chunks))
+	var wg sync.WaitGroup
+
+	// Process chunks concurrently but maintain order
+	for i, piece := range chunks {
+		wg.Add(1)
+		go func(index int, chunkText string) {
+			defer wg.Done()
+
+			chunkID := fmt.Sprintf("%s_%d", translationJobID, index)
+			t.logger.Debugf("Translating %s chunk %d/%d (ID: %s)...", req.Format, index+1, len(chunks), chunkID)
+
+			requestContext := map[string]interface{}{
+				"source_lang":        req.SourceLang,
+				"target_lang":        req.TargetLang,
+				"input_length":       len(chunkText),
+				"input_preview":      truncateText(chunkText, 100),
+				"chunk_index":        index + 1,
+				"total_chunks":       len(chunks),
+				"chunk_id":           chunkID,
+				"translation_job_id": translationJobID,
+			}
+			if req.Format == FormatHTML {
+				requestContext["content_type"] = "html"
+			}
+
+			result, err := t.translateChunk(ctx, req, chunkText, requestType, requestContext)
+			results[index] = ChunkTranslationResult{
+				ChunkID:          chunkID,
+				Index:            index,
+				Error:            err,
+				TranslationJobID: translationJobID,
+			}
+			if result != nil {
+				results[index].TranslatedText = result.Text
+				results[index].Usage = result.Usage
+				results[index].Truncated = result.Truncated
+			}
+		}(i, piece.Text)
+	}
+
+	// Wait for all chunks to complete
+	wg.Wait()
+
+	// Reassemble chunks in order and check for errors
+	segment := &SegmentResult{}
+	var translatedBuilder strings.Builder
+	for i, result := range results {
+		if result.Error != nil {
+			return nil, fmt.Errorf("failed to translate %s chunk %d/%d (ID: %s): %w", req.Format, i+1, len(chunks), result.ChunkID, result.Error)
+		}
+		segment.Usage.Add(result.Usage)
+		segment.Truncated = segment.Truncated || result.Truncated
+
+		// Put back the paragraph break or space the text was split at
+		translatedBuilder.WriteString(result.TranslatedText)
+		if i < len(chunks)-1 {
+			translatedBuilder.WriteString(chunks[i].Separator)
+		}
+	}
+	segment.Text = translatedBuilder.String()
+
+	t.logger.Infof("Translation job %s completed successfully with %d chunks", translationJobID, len(chunks))
+	return segment, nil
+}
+
+// translateChunk translates one chunk of a segment. A chunk the model rejects
+// as too long for its context window, or whose translation it cuts off at its
+// output limit, is split into pieces of half its size at sentence or word
+// boundaries and translated piece by piece. A cut off translation that cannot
+// be split is returned with Truncated set.
+func (t *llmTranslator) translateChunk(ctx context.Context, req SegmentRequest, text, requestType string, requestContext map[string]interface{}) (*SegmentResult, error) {
+	prompt := textTranslationPrompt(req, text, t.structured)
+	if req.Format == FormatHTML {
+		prompt = htmlTranslationPrompt(req, text, t.structured)
+	}
+
+	chatReq := newChatRequest(prompt)
+	var validate func(string) error
+	if t.structured {
+		chatReq.Schema = translationSchema
+		validate = func(content string) error {
+			_, err := parseTranslation(content)
+			return err
+		}
+	}
+
+	response, err := t.makeRequestWithType(ctx, chatReq, requestType, requestContext, validate)
+	if err != nil && !errors.Is(err, ErrContextLength) {
+		return nil, err
+	}
+	if err == nil && !response.truncated() {
+		return &SegmentResult{Text: t.replyText(response, text), Usage: response.Usage}, nil
+	}
+
+	pieces := chunkText(text, t.tokenizer().Count(text)/2, t.tokenizer().Count)
+	if len(pieces) < 2 {
+		if err != nil {
+			return nil, err
+		}
+		t.logger.Warnf("%s cut off the translation of a chunk at its output limit and it cannot be split further", t.name)
+		return &SegmentResult{Text: t.replyText(response, text), Usage: response.Usage, Truncated: true}, nil
+	}
+
+	result := &SegmentResult{}
+	if err != nil {
+		t.logger.Warnf("%s rejected a chunk as too long for its context window, retrying it in %d pieces", t.name, len(pieces))
+	} else {
+		t.logger.Warnf("%s cut off the translation of a chunk at its output limit, retrying it in %d pieces", t.name, len(pieces))
+		result.Usage = response.Usage
+	}
+
+	var b strings.Builder
+	for i, piece := range pieces {
+		translated, err := t.translateChunk(ctx, req, piece.Text, requestType, requestContext)
+		if err != nil {
+			return nil, err
+		}
+		result.Usage.Add(translated.Usage)
+		result.Truncated = result.Truncated || translated.Truncated
+		b.WriteString(translated.Text)
+		if i < len(pieces)-1 {
+			b.WriteString(piece.Separator)
+		}
+	}
+	result.Text = b.String()
+	return result, nil
+}
+
+// replyText returns the translation of source in a reply. A structured reply
+// that was cut off cannot be parsed: the part of the translation it holds is
+// returned, or the source if it holds none.
+func (t *llmTranslator) replyText(response *completion, source string) string {
+	if !t.structured {
+		return response.Content
+	}
+	if translated, err := parseTranslation(response.Content); err == nil {
+		return translated
+	}
+	if translated, ok := partialTranslation(response.Content); ok {
+		return translated
+	}
+	return source
+}
+
+// batchItem is one segment of a batch request or reply.
+type batchItem struct {
+	ID   json.RawMessage `json:"id"`
+	Text string          `json:"text"`
+}
+
+// TranslateBatch sends several segments as a JSON array in one request and maps
+// the translations back by ID.
+func (t *llmTranslator) TranslateBatch(ctx context.Context, reqs []SegmentRequest) (*BatchResult, error) {
+	chatReq, err := t.batchRequest(reqs)
+	if err != nil {
+		return nil, err
+	}
+
+	requestContext := map[string]interface{}{
+		"source_lang": reqs[0].SourceLang,
+		"target_lang": reqs[0].TargetLang,
+		"segments":    len(reqs),
+	}
+
+	response, err := t.makeRequestWithType(ctx, chatReq, "batch_translation", requestContext, func(content string) error {
+		_, err := parseBatchResponse(content, len(reqs))
+		return err
+	})
+	if errors.Is(err, ErrInvalidResponse) {
+		return nil, fmt.Errorf("%w: %v", ErrBatchMismatch, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to translate batch: %w", err)
+	}
+	if response.truncated() {
+		return nil, fmt.Errorf("%w: batch of %d segments", ErrTruncated, len(reqs))
+	}
+
+	texts, err := parseBatchResponse(response.Content, len(reqs))
+	if err != nil {
+		return nil, err
+	}
+	return &BatchResult{Texts: texts, Usage: response.Usage}, nil
+}
+
+// batchRequest encodes the segments as a JSON array with IDs starting at 1.
+func (t *llmTranslator) batchRequest(reqs []SegmentRequest) (chatRequest, error) {
+	items := make([]batchItem, len(reqs))
+	for i, req := range reqs {
+		items[i] = batchItem{ID: json.RawMessage(strconv.Quote(strconv.Itoa(i + 1))), Text: req.Text}
+	}
+	encoded, err := json.MarshalIndent(items, "", "  ")
+	if err != nil {
+		return chatRequest{}, fmt.Errorf("failed to encode batch: %w", err)
+	}
+
+	chatReq := newChatRequest(batchTranslationPrompt(reqs, string(encoded), t.structured))
+	if t.structured {
+		chatReq.Schema = batchSchema
+	}
+	return chatReq, nil
+}
+
+// parseBatchResponse returns the translations of a batch reply in request
+// order. The reply may be the bare array or wrapped in {"translations": [...]},
+// and IDs may be numbers or strings.
+func parseBatchResponse(content string, count int) ([]string, error) {
+	var items []batchItem
+	if err := json.Unmarshal([]byte(extractJSON(content, '[', ']')), &items); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBatchMismatch, err)
+	}
+	if len(items) != count {
+		return nil, fmt.Errorf("%w: got %d translations for %d segments", ErrBatchMismatch, len(items), count)
+	}
+
+	texts := make([]string, count)
+	filled := make([]bool, count)
+	for _, item := range items {
+		id := strings.Trim(string(item.ID), `"`)
+		index, err := strconv.Atoi(id)
+		if err != nil || index < 1 || index > count || filled[index-1] {
+			return nil, fmt.Errorf("%w: unexpected id %s", ErrBatchMismatch, item.ID)
+		}
+		texts[index-1] = item.Text
+		filled[index-1] = true
+	}
+	return texts, nil
+}
+
+// makeRequestWithType sends req and, when validate is given, checks the reply.
+// A reply that fails validation is sent back with a corrective message up to
+// maxReasks times; the reason is recorded in the LLM log. The returned usage
+// covers every attempt.
+func (t *llmTranslator) makeRequestWithType(ctx context.Context, req chatRequest, requestType string, requestContext map[string]interface{}, validate func(content string) error) (*completion, error) {
+	var usage Usage
+	for reask := 0; ; reask++ {
+		var response *completion
+		var err error
+		if t.wsHub != nil {
+			response, err = t.makeRequestWithLLMLogging(ctx, req, requestType, requestContext, validate)
+		} else {
+			response, err = t.makeRequest(ctx, req, requestType)
+		}
+		if err != nil {
+			return nil, err
+		}
+		usage.Add(response.Usage)
+
+		// A reply cut off at the output limit is not re-asked, as it would be
+		// cut off again; the caller splits the request instead
+		if validate == nil || response.truncated() {
+			response.Usage = usage
+			return response, nil
+		}
+		invalid := validate(response.Content)
+		if invalid == nil {
+			response.Usage = usage
+			return response, nil
+		}
+
+		t.logger.Warnf("%s reply to %s request failed validation (re-ask %d/%d): %v", t.name, requestType, reask+1, maxReasks, invalid)
+		if reask >= maxReasks {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidResponse, invalid)
+		}
+
+		req.Messages = append(req.Messages,
+			chatMessage{Role: "assistant", Content: response.Content},
+			chatMessage{Role: "user", Content: reaskPrompt(invalid)},
+		)
+		reaskContext := make(map[string]interface{}, len(requestContext)+2)
+		for key, value := range requestContext {
+			reaskContext[key] = value
+		}
+		reaskContext["reask"] = reask + 1
+		reaskContext["reask_reason"] = invalid.Error()
+		requestContext = reaskContext
+	}
+}
+
+func (t *llmTranslator) makeRequest(ctx context.Context, req chatRequest, requestType string) (*completion, error) {
+	response, err := t.sendWithRetries(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	t.recordRequest(ctx, requestType, response.Usage)
+	return response, nil
+}
+
+// sendWithRetries sends req until it succeeds, fails with an error that is not
+// worth retrying, or maxRetries retries are used up. The wait between attempts
+// grows exponentially with jitter unless the server asks for a specific delay.
+// Cancelling ctx aborts the request in flight and any wait between attempts.
+func (t *llmTranslator) sendWithRetries(ctx context.Context, req chatRequest) (*completion, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := t.complete(ctx, req)
+		if err == nil {
+			t.recordUsage(resp.Usage)
+			return resp, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		if !retryable(err) {
+			t.logger.Warnf("%s request failed and is not retried: %v", t.name, err)
+			return nil, err
+		}
+		if attempt >= t.maxRetries {
+			return nil, fmt.Errorf("max retries exceeded, last error: %w", err)
+		}
+
+		delay := backoff(t.retryDelay, attempt, err)
+		t.logger.Warnf("%s request failed (attempt %d/%d), retrying in %s: %v", t.name, attempt+1, t.maxRetries+1, delay.Round(time.Millisecond), err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// complete sends a single attempt of req once the budgets and the scheduler
+// admit it. The timeout only starts when the request is actually sent.
+func (t *llmTranslator) complete(ctx context.Context, req chatRequest) (*completion, error) {
+	expected := t.expectedUsage(req)
+	var used Usage
+
+	settle, err := t.reserveBudget(ctx, expected)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { settle(used) }()
+
+	if t.scheduler != nil {
+		release, err := t.scheduler.Acquire(ctx, expected.TotalTokens)
+		if err != nil {
+			return nil, err
+		}
+		defer func() { release(used.TotalTokens) }()
+	}
+
+	resp, err := t.sendCompletion(ctx, req)
+	if err == nil {
+		used = resp.Usage
+	}
+	return resp, err
+}
+
+func (t *llmTranslator) sendCompletion(ctx context.Context, req chatRequest) (*completion, error) {
+	timeout := t.requestTimeout
+	if timeout <= 0 {
+		timeout = defaultRequestTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return t.backend.createCompletion(ctx, req)
+}
+
+// expectedUsage guesses the tokens a request will consume before it is sent:
+// the prompt and a reply as long as the prompt, up to the completion limit.
+func (t *llmTranslator) expectedUsage(req chatRequest) Usage {
+	return t.estimateUsage(req, t.promptTokens(req))
+}
+
+// promptTokens counts the tokens of the messages of a request.
+func (t *llmTranslator) promptTokens(req chatRequest) int {
+	tokens := 1
+	for _, message := range req.Messages {
+		tokens += t.tokenizer().Count(message.Content)
+	}
+	return tokens
+}
+
+// tokenizer returns the tokenizer of the model the requests go to.
+func (t *llmTranslator) tokenizer() *tokenizer {
+	return tokenizerFor(t.model)
+}
+
+// chunkBudget is the number of source tokens sent in one request. A
+// translation often needs more tokens than its source, so the chunk may use
+// half of the completion limit, and together with the prompt and the reply it
+// has to fit into the model's context window.
+func (t *llmTranslator) chunkBudget() int {
+	maxTokens := t.maxTokens
+	if maxTokens <= 0 {
+		maxTokens = 2048
+	}
+
+	budget := maxTokens / 2
+	if room := contextWindow(t.model) - maxTokens - promptReserveTokens; room < budget {
+		budget = room
+	}
+	if budget < minChunkTokens {
+		budget = minChunkTokens
+	}
+	return budget
+}
+
+// truncateText safely truncates text to a specified length in bytes, cutting
+// between runes so that the result stays valid UTF-8
+func truncateText(text string, maxLength int) string {
+	if len(text) <= maxLength {
+		return text
+	}
+	if maxLength <= 3 {
+		return "..."
+	}
+	cut := maxLength - 3
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut] + "..."
+}
+
+// makeRequestWithLLMLogging performs a request with comprehensive logging
+func (t *llmTranslator) makeRequestWithLLMLogging(ctx context.Context, req chatRequest, requestType string, requestContext map[string]interface{}, validate func(content string) error) (*completion, error) {
+	prompt := req.Messages[len(req.Messages)-1].Content
+	requestID := uuid.New().String()
+	startTime := time.Now()
+
+	// Log the request
+	if t.wsHub != nil {
+		reqMsg := map[string]interface{}{
+			"request_id":   requestID,
+			"provider":     t.name,
+			"model":        t.model,
+			"prompt":       truncateText(prompt, 1000), // Truncate for display
+			"max_tokens":   t.maxTokens,
+			"temperature":  t.temperature,
+			"timestamp":    startTime,
+			"request_type": requestType,
+			"context":      requestContext,
+		}
+		t.wsHub.BroadcastMessage("llm_request", reqMsg)
+	}
+
+	response, lastErr := t.sendWithRetries(ctx, req)
+
+	duration := time.Since(startTime)
+	success := lastErr == nil
+
+	var rec usage.Record
+	if success {
+		rec = t.recordRequest(ctx, requestType, response.Usage)
+	}
+
+	// Log the response
+	if t.wsHub != nil {
+		respMsg := map[string]interface{}{
+			"request_id": requestID,
+			"duration":   duration.String(),
+			"success":    success,
+			"timestamp":  time.Now(),
+			"context":    requestContext,
+		}
+
+		if success {
+			respMsg["response"] = truncateText(response.Content, 1000) // Truncate for display
+			respMsg["tokens_used"] = rec.TotalTokens
+			respMsg["prompt_tokens"] = rec.PromptTokens
+			respMsg["completion_tokens"] = rec.CompletionTokens
+			respMsg["cost"] = rec.Cost
+			respMsg["finish_reason"] = response.FinishReason
+			if validate != nil {
+				if err := validate(response.Content); err != nil {
+					respMsg["validation_error"] = err.Error()
+				}
+			}
+		} else {
+			respMsg["error"] = lastErr.Error()
+		}
+
+		t.wsHub.BroadcastMessage("llm_response", respMsg)
+	}
+
+	if !success {
+		return nil, lastErr
+	}
+
+	return response, nil
+}
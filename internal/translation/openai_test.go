@@ -1,68 +1,232 @@
 package translation
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"unicode/utf8"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/sirupsen/logrus"
 )
 
-func TestChunkHTML(t *testing.T) {
+func TestChunkText(t *testing.T) {
+	words := func(s string) int { return len(strings.Fields(s)) }
+	runes := func(s string) int { return utf8.RuneCountInString(s) }
+
 	testCases := []struct {
-		name      string
-		text      string
-		chunkSize int
-		expected  []string
+		name     string
+		text     string
+		budget   int
+		count    func(string) int
+		expected []string
 	}{
 		{
-			name:      "Empty string",
-			text:      "",
-			chunkSize: 100,
-			expected:  []string{""},
+			name:     "Empty string",
+			text:     "",
+			budget:   100,
+			count:    words,
+			expected: []string{""},
+		},
+		{
+			name:     "Text within budget",
+			text:     "Hello, world!",
+			budget:   2,
+			count:    words,
+			expected: []string{"Hello, world!"},
 		},
 		{
-			name:      "Text smaller than chunk size",
-			text:      "Hello, world!",
-			chunkSize: 100,
-			expected:  []string{"Hello, world!"},
+			name:     "Split between sentences",
+			text:     "One two three. Four five six? Seven eight.",
+			budget:   6,
+			count:    words,
+			expected: []string{"One two three. Four five six?", "Seven eight."},
 		},
 		{
-			name:      "Text exactly chunk size",
-			text:      "This is a test string of exactly 50 characters.",
-			chunkSize: 50,
-			expected:  []string{"This is a test string of exactly 50 characters."},
+			name:     "Split between paragraphs",
+			text:     "One two three.\n\nFour five six.",
+			budget:   4,
+			count:    words,
+			expected: []string{"One two three.", "Four five six."},
 		},
 		{
-			name:      "Text larger than chunk size, split by word",
-			text:      "This is a very long single paragraph that will need to be split in the middle of the text because it exceeds the chunk size.",
-			chunkSize: 50,
-			expected: []string{
-				"This is a very long single paragraph that will need",
-				"to be split in the middle of the text because it",
-				"exceeds the chunk size.",
-			},
+			name:     "Long sentence is split between words",
+			text:     "This is a very long single sentence without any break",
+			budget:   4,
+			count:    words,
+			expected: []string{"This is a very", "long single sentence without", "any break"},
 		},
 		{
-			name:      "HTML tags should not be split",
-			text:      "<p>This is a paragraph with <b>bold</b> text.</p>",
-			chunkSize: 30,
-			expected: []string{
-				"<p>This is a paragraph with",
-				"<b>bold</b> text.</p>",
-			},
+			name:     "Tags are not split",
+			text:     "<p>Some <b>bold</b> text here</p>",
+			budget:   3,
+			count:    words,
+			expected: []string{"<p>Some <b>", "bold</b> text", "here</p>"},
+		},
+		{
+			name:     "CJK sentences without spaces",
+			text:     "你好。我很好。谢谢你。",
+			budget:   7,
+			count:    runes,
+			expected: []string{"你好。我很好。", "谢谢你。"},
 		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			chunks := chunkHTML(tc.text, tc.chunkSize)
+			chunks := chunkText(tc.text, tc.budget, tc.count)
 
 			if len(chunks) != len(tc.expected) {
-				t.Fatalf("Expected %d chunks, but got %d. Chunks: %v", len(tc.expected), len(chunks), chunks)
+				t.Fatalf("Expected %d chunks, but got %d. Chunks: %q", len(tc.expected), len(chunks), chunks)
 			}
 
+			var joined strings.Builder
 			for i, chunk := range chunks {
-				if chunk != tc.expected[i] {
-					t.Errorf("Chunk %d does not match.\nExpected: %q\nGot:      %q", i, tc.expected[i], chunk)
+				if chunk.Text != tc.expected[i] {
+					t.Errorf("Chunk %d does not match.\nExpected: %q\nGot:      %q", i, tc.expected[i], chunk.Text)
 				}
+				joined.WriteString(chunk.Text + chunk.Separator)
+			}
+			if joined.String() != tc.text {
+				t.Errorf("Chunks do not add up to the text.\nExpected: %q\nGot:      %q", tc.text, joined.String())
 			}
 		})
 	}
 }
+
+func TestTokenizerCount(t *testing.T) {
+	english := "The quick brown fox jumps over the lazy dog."
+	persian := "روباه قهوه‌ای سریع از روی سگ تنبل می‌پرد."
+
+	// The same sentence takes more tokens in Persian, and fewer with o200k
+	if cl100kTokenizer.Count(persian) <= cl100kTokenizer.Count(english) {
+		t.Errorf("Expected Persian to need more tokens than English with cl100k")
+	}
+	if o200kTokenizer.Count(persian) >= cl100kTokenizer.Count(persian) {
+		t.Errorf("Expected o200k to need fewer tokens than cl100k for Persian")
+	}
+	if tokenizerFor("gpt-4o-mini") != o200kTokenizer || tokenizerFor("gpt-4") != cl100kTokenizer {
+		t.Errorf("Unexpected tokenizer for model")
+	}
+	if contextWindow("gpt-4.5-preview") != 128000 || contextWindow("chatgpt-4o-latest") != 128000 {
+		t.Errorf("Unexpected context window for model")
+	}
+}
+
+func TestParseBatchResponse(t *testing.T) {
+	testCases := []struct {
+		name     string
+		content  string
+		expected []string
+		mismatch bool
+	}{
+		{
+			name:     "Out of order with code fence",
+			content:  "```json\n[{\"id\": \"2\", \"text\": \"deux\"}, {\"id\": \"1\", \"text\": \"un\"}]\n```",
+			expected: []string{"un", "deux"},
+		},
+		{
+			name:     "Numeric ids",
+			content:  `[{"id": 1, "text": "un"}, {"id": 2, "text": "deux"}]`,
+			expected: []string{"un", "deux"},
+		},
+		{
+			name:     "Missing item",
+			content:  `[{"id": "1", "text": "un"}]`,
+			mismatch: true,
+		},
+		{
+			name:     "Duplicated id",
+			content:  `[{"id": "1", "text": "un"}, {"id": "1", "text": "deux"}]`,
+			mismatch: true,
+		},
+		{
+			name:     "Not JSON",
+			content:  "un\ndeux",
+			mismatch: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			texts, err := parseBatchResponse(tc.content, 2)
+			if tc.mismatch {
+				if !errors.Is(err, ErrBatchMismatch) {
+					t.Fatalf("Expected ErrBatchMismatch, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseBatchResponse failed: %v", err)
+			}
+			for i, text := range texts {
+				if text != tc.expected[i] {
+					t.Errorf("Text %d does not match.\nExpected: %q\nGot:      %q", i, tc.expected[i], text)
+				}
+			}
+		})
+	}
+}
+
+func TestResponseFormatFallback(t *testing.T) {
+	var formats []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			ResponseFormat *struct {
+				Type string `json:"type"`
+			} `json:"response_format"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		format := "none"
+		if body.ResponseFormat != nil {
+			format = body.ResponseFormat.Type
+		}
+		formats = append(formats, format)
+
+		w.Header().Set("Content-Type", "application/json")
+		if format == "json_schema" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error": {"message": "response_format of type json_schema is not supported", "type": "invalid_request_error"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "{\"translation\": \"Bonjour\"}"}, "finish_reason": "stop"}]}`))
+	}))
+	defer server.Close()
+
+	clientConfig := openai.DefaultConfig("")
+	clientConfig.BaseURL = server.URL
+	client := newOpenAIClient("openai_compatible", clientConfig, "local", 100, 0, 0, 0, logrus.New())
+	client.structured = true
+
+	for i := 0; i < 2; i++ {
+		result, err := client.TranslateSegment(context.Background(), SegmentRequest{Text: "Hello", SourceLang: "en", TargetLang: "fr", Format: FormatText})
+		if err != nil {
+			t.Fatalf("TranslateSegment() error = %v", err)
+		}
+		if result.Text != "Bonjour" {
+			t.Errorf("TranslateSegment() = %q, want Bonjour", result.Text)
+		}
+	}
+
+	// The schema is refused once and not asked for again
+	if strings.Join(formats, ",") != "json_schema,json_object,json_object" {
+		t.Errorf("response formats = %v, want json_schema once, then json_object", formats)
+	}
+}
+
+func TestTruncateText(t *testing.T) {
+	persian := "نگهبان فانوس دریایی"
+	for limit := 4; limit < len(persian); limit++ {
+		got := truncateText(persian, limit)
+		if !utf8.ValidString(got) || len(got) > limit || !strings.HasSuffix(got, "...") {
+			t.Errorf("truncateText(%d) = %q, want valid UTF-8 of at most %d bytes", limit, got, limit)
+		}
+	}
+	if got := truncateText("short", 10); got != "short" {
+		t.Errorf("truncateText() = %q, want the text unchanged", got)
+	}
+}
@@ -0,0 +1,185 @@
+package translation
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Inline markup is sent to the model as numbered placeholders instead of raw
+// tags: an element with content becomes <1>…</1> and an element without
+// translatable content becomes <2/>. The original elements are put back in
+// whatever order the translation uses them.
+var placeholderPattern = regexp.MustCompile(`<(/?)(\d+)(/?)>`)
+
+// opaqueElements are replaced by a single placeholder and restored untouched.
+var opaqueElements = map[string]bool{
+	"br": true, "img": true, "wbr": true, "hr": true, "rt": true, "rp": true,
+	"svg": true, "math": true, "script": true, "style": true, "input": true,
+}
+
+// encodePlaceholders renders nodes as text with numbered placeholders. The
+// returned slice maps each placeholder number to its node, starting at 1.
+func encodePlaceholders(nodes []*html.Node) (string, []*html.Node) {
+	var b strings.Builder
+	tags := []*html.Node{nil}
+
+	var walk func(node *html.Node)
+	walk = func(node *html.Node) {
+		switch node.Type {
+		case html.TextNode:
+			b.WriteString(node.Data)
+			return
+		case html.ElementNode, html.CommentNode:
+		default:
+			return
+		}
+
+		id := len(tags)
+		tags = append(tags, node)
+		if node.Type == html.CommentNode || opaqueElements[node.Data] || node.FirstChild == nil {
+			fmt.Fprintf(&b, "<%d/>", id)
+			return
+		}
+
+		fmt.Fprintf(&b, "<%d>", id)
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+		fmt.Fprintf(&b, "</%d>", id)
+	}
+
+	for _, node := range nodes {
+		walk(node)
+	}
+	return b.String(), tags
+}
+
+// placeholderKinds returns whether each placeholder of text is paired (true)
+// or self-closing (false).
+func placeholderKinds(text string) map[int]bool {
+	kinds := make(map[int]bool)
+	for _, match := range placeholderPattern.FindAllStringSubmatch(text, -1) {
+		id, _ := strconv.Atoi(match[2])
+		kinds[id] = match[3] == ""
+	}
+	return kinds
+}
+
+// checkPlaceholders verifies that translated uses every placeholder of source
+// exactly once, with the same kind and properly nested.
+func checkPlaceholders(source, translated string) error {
+	expected := placeholderKinds(source)
+	opened := make(map[int]bool)
+	closed := make(map[int]bool)
+	var stack []int
+
+	for _, match := range placeholderPattern.FindAllStringSubmatch(translated, -1) {
+		id, _ := strconv.Atoi(match[2])
+		paired, known := expected[id]
+		isClose, isSelfClosing := match[1] != "", match[3] != ""
+
+		switch {
+		case !known:
+			return fmt.Errorf("unknown placeholder %s", match[0])
+		case isClose && isSelfClosing:
+			return fmt.Errorf("malformed placeholder %s", match[0])
+		case paired == isSelfClosing:
+			return fmt.Errorf("placeholder %s changed its form", match[0])
+		case isClose:
+			if len(stack) == 0 || stack[len(stack)-1] != id {
+				return fmt.Errorf("placeholder %s is not properly nested", match[0])
+			}
+			stack = stack[:len(stack)-1]
+			closed[id] = true
+		default:
+			if opened[id] {
+				return fmt.Errorf("placeholder <%d> is duplicated", id)
+			}
+			opened[id] = true
+			if paired {
+				stack = append(stack, id)
+			}
+		}
+	}
+
+	if len(stack) > 0 {
+		return fmt.Errorf("placeholder <%d> is not closed", stack[len(stack)-1])
+	}
+	for id, paired := range expected {
+		if !opened[id] || (paired && !closed[id]) {
+			return fmt.Errorf("placeholder <%d> is missing", id)
+		}
+	}
+	return nil
+}
+
+// restorePlaceholders turns a checked translation back into nodes, replacing
+// each placeholder with a copy of the element it stands for.
+func restorePlaceholders(translated string, tags []*html.Node) ([]*html.Node, error) {
+	root := &html.Node{Type: html.DocumentNode}
+	stack := []*html.Node{root}
+
+	appendText := func(text string) {
+		if text != "" {
+			stack[len(stack)-1].AppendChild(&html.Node{Type: html.TextNode, Data: text})
+		}
+	}
+
+	offset := 0
+	for _, match := range placeholderPattern.FindAllStringSubmatchIndex(translated, -1) {
+		appendText(translated[offset:match[0]])
+		offset = match[1]
+
+		id, _ := strconv.Atoi(translated[match[4]:match[5]])
+		if id <= 0 || id >= len(tags) {
+			return nil, fmt.Errorf("unknown placeholder %s", translated[match[0]:match[1]])
+		}
+
+		switch {
+		case match[3] > match[2]:
+			if len(stack) == 1 {
+				return nil, fmt.Errorf("placeholder %s is not properly nested", translated[match[0]:match[1]])
+			}
+			stack = stack[:len(stack)-1]
+		case match[7] > match[6]:
+			stack[len(stack)-1].AppendChild(cloneNode(tags[id], true))
+		default:
+			element := cloneNode(tags[id], false)
+			stack[len(stack)-1].AppendChild(element)
+			stack = append(stack, element)
+		}
+	}
+	appendText(translated[offset:])
+
+	var nodes []*html.Node
+	for node := root.FirstChild; node != nil; node = root.FirstChild {
+		root.RemoveChild(node)
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// stripPlaceholders removes all placeholders, leaving the plain translation.
+func stripPlaceholders(text string) string {
+	return placeholderPattern.ReplaceAllString(text, "")
+}
+
+func cloneNode(node *html.Node, deep bool) *html.Node {
+	clone := &html.Node{
+		Type:      node.Type,
+		DataAtom:  node.DataAtom,
+		Data:      node.Data,
+		Namespace: node.Namespace,
+		Attr:      append([]html.Attribute(nil), node.Attr...),
+	}
+	if deep {
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			clone.AppendChild(cloneNode(child, true))
+		}
+	}
+	return clone
+}
@@ -0,0 +1,36 @@
+package translation
+
+import (
+	"testing"
+)
+
+func TestCheckPlaceholders(t *testing.T) {
+	source := "See <1>the <2>old</2> map</1><3/> and <4/>."
+
+	testCases := []struct {
+		name       string
+		translated string
+		valid      bool
+	}{
+		{name: "Same order", translated: "Voir <1>la <2>vieille</2> carte</1><3/> et <4/>.", valid: true},
+		{name: "Reordered", translated: "<4/> Voir <1>la carte <2>ancienne</2></1><3/>.", valid: true},
+		{name: "Missing", translated: "Voir <1>la <2>vieille</2> carte</1> et <4/>.", valid: false},
+		{name: "Duplicated", translated: "Voir <1>la <2>vieille</2> carte</1><3/> et <4/><4/>.", valid: false},
+		{name: "Badly nested", translated: "Voir <1>la <2>vieille</1> carte</2><3/> et <4/>.", valid: false},
+		{name: "Not closed", translated: "Voir <1>la <2>vieille</2> carte<3/> et <4/>.", valid: false},
+		{name: "Changed form", translated: "Voir <1/>la <2>vieille</2> carte<3/> et <4/>.", valid: false},
+		{name: "Unknown", translated: "Voir <1>la <2>vieille</2> carte</1><3/> et <4/><5/>.", valid: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkPlaceholders(source, tc.translated)
+			if tc.valid && err != nil {
+				t.Errorf("Expected a valid translation, got %v", err)
+			}
+			if !tc.valid && err == nil {
+				t.Errorf("Expected an error for %q", tc.translated)
+			}
+		})
+	}
+}
@@ -0,0 +1,255 @@
+package translation
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// promptVersion is part of every cache key. Bump it whenever a prompt changes
+// in a way that should invalidate previously cached translations.
+const promptVersion = "1"
+
+func languageDetectionPrompt(text string, structured bool) string {
+	reply := `Respond with only the ISO 639-1 language code (e.g., "en", "es", "fr", "de").`
+	if structured {
+		reply = `Respond with only a JSON object of the form {"language": "<code>"} where code is the ISO 639-1 language code (e.g., "en", "es", "fr", "de").`
+	}
+	return fmt.Sprintf(`Detect the language of the following text. %s\n\nText: %s`, reply, text)
+}
+
+// jsonReplyInstruction asks for the translation as a JSON object when the
+// provider runs in structured output mode.
+const jsonReplyInstruction = `Respond with only a JSON object of the form {"translation": "<translated text>"}.`
+
+func textTranslationPrompt(req SegmentRequest, text string, structured bool) string {
+	reply := "Return only the translated text."
+	if structured {
+		reply = jsonReplyInstruction
+	}
+
+	//prompt := fmt.Sprintf(`Translate the following text from %s to %s. Maintain the original tone, style, and formatting as much as possible. Return only the translated text without any additional comments or explanations.\n\nText: %s`, sourceLanguage, targetLanguage, chunkText)
+	return fmt.Sprintf(`You are a professional book translator. Translate the following text from %s to %s.
+
+Ensure the translation is:
+- Smooth and natural in the target language
+- Clear and easy to understand for native readers
+- Faithful to the tone, style, and voice of the original author
+- Respectful of formatting, punctuation, and paragraph structure
+- If the content contains inappropriate, offensive, or explicit words, replace them with asterisks (*) while maintaining the sentence structure
+
+Do not add explanations or comments. %s
+Do not translate string literals, code snippets, or any other non-translatable content.%s
+
+Text to translate:
+%s`, getLanguageName(req.SourceLang), getLanguageName(req.TargetLang), reply, promptAdditions(req), text)
+}
+
+func htmlTranslationPrompt(req SegmentRequest, html string, structured bool) string {
+	reply := ""
+	if structured {
+		reply = ". " + jsonReplyInstruction
+	}
+	return fmt.Sprintf(`Translate the following HTML content from %s to %s. \n\nIMPORTANT INSTRUCTIONS:\n1. Preserve ALL HTML tags, attributes, and structure exactly as they are\n2. Only translate the text content between HTML tags\n3. Do NOT translate HTML tag names, attributes, or values\n4. Maintain the original formatting, spacing, and line breaks\n5. Keep any CSS classes, IDs, and other attributes unchanged\n6. If the content contains inappropriate, offensive, or explicit words, replace them with asterisks (*) while maintaining the sentence structure\n7. Return only the translated HTML without any additional comments%s%s\n\nHTML content:\n%s`, getLanguageName(req.SourceLang), getLanguageName(req.TargetLang), reply, promptAdditions(req), html)
+}
+
+func termProposalPrompt(req TermRequest) string {
+	var terms strings.Builder
+	for _, candidate := range req.Candidates {
+		fmt.Fprintf(&terms, "- %s (appears %d times): %s\n", candidate.Term, candidate.Count, candidate.Context)
+	}
+
+	extra := ""
+	if req.MaxExtra > 0 {
+		extra = fmt.Sprintf("\nYou may add up to %d further recurring domain-specific terms from the sentences if they need a consistent translation.", req.MaxExtra)
+	}
+
+	return fmt.Sprintf(`You are preparing a glossary for a book that is being translated from %s to %s.
+
+Below are recurring names and terms found in the book, each followed by a sentence in which it occurs. Propose the translation that should be used consistently throughout the book for each one. Render names of people and places the way they are conventionally written in %s.%s
+
+Respond with only a JSON array of objects with the fields "source", "target" and "note", where note is a short description such as "character", "place" or "term".
+
+Terms:
+%s`, getLanguageName(req.SourceLang), getLanguageName(req.TargetLang), getLanguageName(req.TargetLang), extra, terms.String())
+}
+
+// memoryUpdatePrompt asks the model to fold a translated chapter into the
+// notes kept on the book.
+func memoryUpdatePrompt(req MemoryRequest, structured bool) string {
+	current, _ := json.MarshalIndent(struct {
+		Characters []Character `json:"characters"`
+		Tone       string      `json:"tone"`
+		Phrasings  []Phrasing  `json:"phrasings"`
+		Notes      []string    `json:"notes"`
+	}{req.Memory.Characters, req.Memory.Tone, req.Memory.Phrasings, req.Memory.Notes}, "", "  ")
+
+	var chapter strings.Builder
+	for _, paragraph := range req.Paragraphs {
+		fmt.Fprintf(&chapter, "Source: %s\nTranslation: %s\n", paragraph.Source, paragraph.Translation)
+	}
+
+	reply := `Respond with only the updated notes as a JSON object with the same fields.`
+	if structured {
+		reply = `Respond with only the updated notes as a JSON object with the fields "characters", "tone", "phrasings" and "notes".`
+	}
+
+	return fmt.Sprintf(`You keep the notes a translator relies on to translate a book from %s to %s consistently. The chapter "%s" has just been translated; it is shown below paragraph by paragraph together with its translation.
+
+Update the notes with what the chapter adds:
+- characters: every named character with the %s rendering of the name used in the translation, their gender, how they address others and are addressed (formal or informal forms, titles) and a few words on who they are
+- tone: the narrative voice, tense and register of the book in one or two sentences
+- phrasings: recurring expressions, catchphrases and terms with the translation used for them
+- notes: anything else the translation of later chapters has to stay consistent with
+
+Keep what is still valid, correct what the chapter contradicts and drop minor details so the notes stay short (at most 30 character entries, 30 phrasings and 10 notes). %s
+
+Current notes:
+%s
+
+Chapter:
+%s`, getLanguageName(req.SourceLang), getLanguageName(req.TargetLang), req.ChapterTitle, getLanguageName(req.TargetLang), reply, current, chapter.String())
+}
+
+// batchTranslationPrompt asks for several segments at once. The segments are
+// sent as a JSON array and must come back with the same IDs.
+func batchTranslationPrompt(reqs []SegmentRequest, items string, structured bool) string {
+	reply := `Respond with only a JSON array containing one object per item with the fields "id" (the item's id) and "text" (its translation).`
+	if structured {
+		reply = `Respond with only a JSON object of the form {"translations": [...]} where the array holds one object per item with the fields "id" (the item's id) and "text" (its translation).`
+	}
+
+	merged := SegmentRequest{Format: FormatText, Context: reqs[0].Context, Memory: reqs[0].Memory}
+	seen := make(map[string]bool)
+	for _, req := range reqs {
+		if req.Format == FormatTagged {
+			merged.Format = FormatTagged
+		}
+		for _, term := range req.Glossary {
+			if !seen[term.Source] {
+				seen[term.Source] = true
+				merged.Glossary = append(merged.Glossary, term)
+			}
+		}
+	}
+
+	return fmt.Sprintf(`You are a professional book translator. Translate the text of every item below from %s to %s.
+
+Ensure the translation is:
+- Smooth and natural in the target language
+- Clear and easy to understand for native readers
+- Faithful to the tone, style, and voice of the original author
+- Respectful of formatting, punctuation, and paragraph structure
+- If the content contains inappropriate, offensive, or explicit words, replace them with asterisks (*) while maintaining the sentence structure
+
+The items are consecutive paragraphs of the same book. Translate each item on its own; do not merge, split or skip items.%s
+
+%s
+
+Items:
+%s`, getLanguageName(reqs[0].SourceLang), getLanguageName(reqs[0].TargetLang), promptAdditions(merged), reply, items)
+}
+
+// promptAdditions renders the book memory, context, glossary terms and
+// corrections of a request as extra instructions. It is empty for plain
+// requests so their prompts, and therefore their cache keys, are unchanged.
+func promptAdditions(req SegmentRequest) string {
+	var b strings.Builder
+
+	if notes := req.Memory.promptText(); notes != "" {
+		b.WriteString("\n\nNotes on the book from the chapters translated so far. Follow them for names, genders, forms of address, tone and recurring phrasings:\n")
+		b.WriteString(notes)
+	}
+
+	if len(req.Context) > 0 {
+		b.WriteString("\n\nThe text continues from the paragraphs below, shown with the translations already used in the book. ")
+		b.WriteString("Use them only to keep names, pronouns, tense and dialogue consistent; do not translate them again.\n")
+		for _, paragraph := range req.Context {
+			fmt.Fprintf(&b, "Source: %s\nTranslation: %s\n", paragraph.Source, paragraph.Translation)
+		}
+	}
+
+	if req.Format == FormatTagged {
+		b.WriteString("\n\nThe text contains numbered placeholders such as <1>…</1> and <2/> that stand for formatting. ")
+		b.WriteString("Keep every placeholder exactly once and do not add new ones. ")
+		b.WriteString("A pair must enclose the translation of the words it encloses in the source; move it if the word order changes.\n")
+	}
+
+	if len(req.Glossary) > 0 {
+		b.WriteString("\n\nUse exactly these translations for the following terms:\n")
+		for _, term := range req.Glossary {
+			fmt.Fprintf(&b, "- %s → %s", term.Source, term.Target)
+			if term.Note != "" {
+				fmt.Fprintf(&b, " (%s)", term.Note)
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	if len(req.Corrections) > 0 {
+		b.WriteString("\n\nA previous translation of this text was rejected. Fix the following:\n")
+		for _, correction := range req.Corrections {
+			fmt.Fprintf(&b, "- %s\n", correction)
+		}
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func getLanguageName(code string) string {
+	languages := map[string]string{
+		"en": "English",
+		"es": "Spanish",
+		"fr": "French",
+		"de": "German",
+		"it": "Italian",
+		"pt": "Portuguese",
+		"ru": "Russian",
+		"ja": "Japanese",
+		"ko": "Korean",
+		"zh": "Chinese",
+		"ar": "Arabic",
+		"fa": "Persian",
+		"he": "Hebrew",
+		"hi": "Hindi",
+		"tr": "Turkish",
+		"pl": "Polish",
+		"nl": "Dutch",
+		"sv": "Swedish",
+		"da": "Danish",
+		"no": "Norwegian",
+		"fi": "Finnish",
+		"cs": "Czech",
+		"sk": "Slovak",
+		"hu": "Hungarian",
+		"ro": "Romanian",
+		"bg": "Bulgarian",
+		"hr": "Croatian",
+		"sl": "Slovenian",
+		"et": "Estonian",
+		"lv": "Latvian",
+		"lt": "Lithuanian",
+		"el": "Greek",
+		"th": "Thai",
+		"vi": "Vietnamese",
+		"id": "Indonesian",
+		"ms": "Malay",
+		"tl": "Filipino",
+		"uk": "Ukrainian",
+		"be": "Belarusian",
+		"ka": "Georgian",
+		"hy": "Armenian",
+		"az": "Azerbaijani",
+		"kk": "Kazakh",
+		"ky": "Kyrgyz",
+		"uz": "Uzbek",
+		"tj": "Tajik",
+		"mn": "Mongolian",
+	}
+
+	if name, exists := languages[code]; exists {
+		return name
+	}
+
+	return code
+}
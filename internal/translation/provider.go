@@ -0,0 +1,170 @@
+package translation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"epub-translator/internal/config"
+	"epub-translator/internal/epub"
+	"epub-translator/internal/glossary"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Provider is a translation backend. Providers are registered by name and
+// selected through config.Translation.Provider or per book. Every call stops
+// as soon as its context is cancelled.
+type Provider interface {
+	epub.LanguageDetector
+
+	// Name returns the name the provider is registered under.
+	Name() string
+	// Model returns the model the provider sends requests to.
+	Model() string
+	// TranslateSegment translates a single piece of text or HTML. A reply cut
+	// off at the model's output limit is retried in smaller pieces, and comes
+	// back with Truncated set when the text cannot be split any further.
+	TranslateSegment(ctx context.Context, req SegmentRequest) (*SegmentResult, error)
+	// TranslateBatch translates several segments in one request. It returns an
+	// error wrapping ErrBatchMismatch when the reply cannot be mapped back onto
+	// the segments, and ErrTruncated when it was cut off at the output limit.
+	TranslateBatch(ctx context.Context, reqs []SegmentRequest) (*BatchResult, error)
+	// ProposeTerms suggests glossary translations for recurring terms.
+	ProposeTerms(ctx context.Context, req TermRequest) ([]glossary.Term, error)
+	// UpdateMemory returns the book memory updated with a translated chapter.
+	UpdateMemory(ctx context.Context, req MemoryRequest) (*BookMemory, error)
+	// Usage returns the tokens consumed since the provider was created.
+	Usage() Usage
+}
+
+// SegmentFormat tells the provider how to treat the text of a segment.
+type SegmentFormat string
+
+const (
+	FormatText SegmentFormat = "text"
+	FormatHTML SegmentFormat = "html"
+	// FormatTagged is text whose inline markup was replaced by numbered
+	// placeholders that must come back unchanged.
+	FormatTagged SegmentFormat = "tagged"
+)
+
+// SegmentRequest describes one segment to translate.
+type SegmentRequest struct {
+	Text       string
+	SourceLang string
+	TargetLang string
+	Format     SegmentFormat
+	// Glossary lists the terms of the segment that must be translated as given.
+	Glossary []glossary.Term
+	// Corrections explain why an earlier translation was rejected.
+	Corrections []string
+	// Context holds the paragraphs preceding the segment and their
+	// translations. It is shown to the model but not translated again.
+	Context []ContextParagraph
+	// Memory holds the notes on the book gathered from earlier chapters.
+	Memory *BookMemory
+}
+
+// ContextParagraph is a preceding paragraph and the translation it received.
+type ContextParagraph struct {
+	Source      string
+	Translation string
+}
+
+// TermRequest asks for translations of the candidates of a terminology pass.
+type TermRequest struct {
+	Candidates []glossary.Candidate
+	SourceLang string
+	TargetLang string
+	// MaxExtra is how many further terms the model may add from the contexts.
+	MaxExtra int
+}
+
+// SegmentResult is the translation of a segment and what it cost.
+type SegmentResult struct {
+	Text  string
+	Usage Usage
+
+	// Truncated is set when the translation was cut off at the model's output
+	// limit.
+	Truncated bool
+}
+
+// BatchResult holds the translations of a batch in request order.
+type BatchResult struct {
+	Texts []string
+	Usage Usage
+}
+
+// ErrBatchMismatch reports a batch reply with missing, extra or unknown
+// segments. Callers retry such batches in smaller pieces.
+var ErrBatchMismatch = errors.New("batch response does not match the request")
+
+// ErrTruncated reports a reply cut off at the model's output limit. Callers
+// retry such requests in smaller pieces.
+var ErrTruncated = errors.New("reply was cut off at the output limit")
+
+// Usage counts requests and tokens reported by a provider.
+type Usage struct {
+	Requests         int `json:"requests"`
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// Add accumulates other into u.
+func (u *Usage) Add(other Usage) {
+	u.Requests += other.Requests
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+	u.TotalTokens += other.TotalTokens
+}
+
+// ProviderFactory builds a provider from the application configuration.
+type ProviderFactory func(cfg *config.Config, logger *logrus.Logger) (Provider, error)
+
+var (
+	providerFactoriesMu sync.RWMutex
+	providerFactories   = make(map[string]ProviderFactory)
+)
+
+// RegisterProvider makes a provider available under name. It is meant to be
+// called from init functions and panics on duplicate names.
+func RegisterProvider(name string, factory ProviderFactory) {
+	providerFactoriesMu.Lock()
+	defer providerFactoriesMu.Unlock()
+
+	if _, exists := providerFactories[name]; exists {
+		panic(fmt.Sprintf("translation provider %q registered twice", name))
+	}
+	providerFactories[name] = factory
+}
+
+// NewProvider builds the provider registered under name.
+func NewProvider(name string, cfg *config.Config, logger *logrus.Logger) (Provider, error) {
+	providerFactoriesMu.RLock()
+	factory, exists := providerFactories[name]
+	providerFactoriesMu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("unknown translation provider %q (available: %v)", name, ProviderNames())
+	}
+
+	return factory(cfg, logger)
+}
+
+// ProviderNames lists the registered providers in alphabetical order.
+func ProviderNames() []string {
+	providerFactoriesMu.RLock()
+	defer providerFactoriesMu.RUnlock()
+
+	names := make([]string, 0, len(providerFactories))
+	for name := range providerFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,175 @@
+package translation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRetryDelay caps the exponential backoff and any Retry-After a server asks for.
+const maxRetryDelay = 2 * time.Minute
+
+// ErrContextLength reports a request the model rejected because the prompt does
+// not fit into its context window. Retrying it unchanged is pointless; callers
+// split the input instead.
+var ErrContextLength = errors.New("input exceeds the model's context window")
+
+// apiError is an error response of a provider API.
+type apiError struct {
+	StatusCode int
+	Type       string
+	Message    string
+	// RetryAfter is the delay the server asked for, if any.
+	RetryAfter time.Duration
+}
+
+func (e *apiError) Error() string {
+	if e.Type != "" {
+		return fmt.Sprintf("status %d: %s: %s", e.StatusCode, e.Type, e.Message)
+	}
+	return fmt.Sprintf("status %d: %s", e.StatusCode, e.Message)
+}
+
+// Is makes errors.Is(err, ErrContextLength) hold for context length errors.
+func (e *apiError) Is(target error) bool {
+	return target == ErrContextLength && e.isContextLength()
+}
+
+func (e *apiError) isContextLength() bool {
+	if e.StatusCode != http.StatusBadRequest && e.StatusCode != http.StatusRequestEntityTooLarge {
+		return false
+	}
+	message := strings.ToLower(e.Type + " " + e.Message)
+	for _, marker := range []string{"context_length_exceeded", "maximum context length", "context window", "prompt is too long", "too many tokens", "reduce the length"} {
+		if strings.Contains(message, marker) {
+			return true
+		}
+	}
+	return e.StatusCode == http.StatusRequestEntityTooLarge
+}
+
+// retryable reports whether a failed request may succeed when sent again:
+// network errors, timeouts, rate limits and server errors are retried;
+// authentication, invalid requests, context length errors and requests refused
+// by a budget are not.
+func retryable(err error) bool {
+	if errors.Is(err, ErrContextLength) || errors.Is(err, ErrBudgetExceeded) {
+		return false
+	}
+
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusTooManyRequests ||
+			apiErr.StatusCode == http.StatusRequestTimeout ||
+			apiErr.StatusCode == http.StatusConflict ||
+			apiErr.StatusCode >= 500
+	}
+	// Network errors, timeouts and malformed replies are worth another try
+	return true
+}
+
+// backoff returns how long to wait before retry number attempt (starting at
+// 0): the Retry-After the server asked for, or an exponentially growing delay
+// based on base with up to half of it added as jitter.
+func backoff(base time.Duration, attempt int, err error) time.Duration {
+	var apiErr *apiError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		if apiErr.RetryAfter > maxRetryDelay {
+			return maxRetryDelay
+		}
+		return apiErr.RetryAfter
+	}
+
+	if base <= 0 {
+		base = time.Second
+	}
+	delay := base << attempt
+	if delay > maxRetryDelay || delay <= 0 {
+		delay = maxRetryDelay
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date.
+func parseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(at); delay > 0 {
+			return delay
+		}
+	}
+	return 0
+}
+
+type responseInfoKey struct{}
+
+// responseInfo receives the Retry-After header of a response through the
+// request context, for clients that do not expose response headers on errors.
+type responseInfo struct {
+	mu         sync.Mutex
+	retryAfter time.Duration
+}
+
+func withResponseInfo(ctx context.Context) (context.Context, *responseInfo) {
+	info := &responseInfo{}
+	return context.WithValue(ctx, responseInfoKey{}, info), info
+}
+
+func (info *responseInfo) RetryAfter() time.Duration {
+	info.mu.Lock()
+	defer info.mu.Unlock()
+	return info.retryAfter
+}
+
+// retryAfterTransport records the Retry-After header of every response in the
+// responseInfo of its request.
+type retryAfterTransport struct {
+	base http.RoundTripper
+}
+
+func (t *retryAfterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	if info, ok := req.Context().Value(responseInfoKey{}).(*responseInfo); ok {
+		retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"))
+		if retryAfter == 0 {
+			// Sent by OpenAI and Azure alongside or instead of Retry-After
+			if ms, err := strconv.Atoi(resp.Header.Get("retry-after-ms")); err == nil && ms > 0 {
+				retryAfter = time.Duration(ms) * time.Millisecond
+			}
+		}
+		info.mu.Lock()
+		info.retryAfter = retryAfter
+		info.mu.Unlock()
+	}
+	return resp, nil
+}
+
+// withRetryAfterTransport returns a copy of client whose transport records
+// Retry-After headers.
+func withRetryAfterTransport(client *http.Client) *http.Client {
+	wrapped := &http.Client{}
+	if client != nil {
+		*wrapped = *client
+	}
+	base := wrapped.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	wrapped.Transport = &retryAfterTransport{base: base}
+	return wrapped
+}
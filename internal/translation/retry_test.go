@@ -0,0 +1,64 @@
+package translation
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRetryable(t *testing.T) {
+	testCases := []struct {
+		name          string
+		err           error
+		retry         bool
+		contextLength bool
+	}{
+		{name: "Rate limited", err: &apiError{StatusCode: 429, Message: "slow down"}, retry: true},
+		{name: "Server error", err: &apiError{StatusCode: 503, Type: "overloaded_error"}, retry: true},
+		{name: "Network error", err: errors.New("connection reset by peer"), retry: true},
+		{name: "Invalid API key", err: &apiError{StatusCode: 401, Message: "invalid api key"}},
+		{name: "Invalid request", err: &apiError{StatusCode: 400, Type: "invalid_request_error", Message: "unknown parameter"}},
+		{name: "Budget refused", err: fmt.Errorf("%w: the daily budget of 100 tokens would be exceeded", ErrBudgetExceeded)},
+		{
+			name:          "OpenAI context length",
+			err:           fmt.Errorf("wrapped: %w", &apiError{StatusCode: 400, Message: "context_length_exceeded: This model's maximum context length is 8192 tokens"}),
+			contextLength: true,
+		},
+		{
+			name:          "Anthropic context length",
+			err:           &apiError{StatusCode: 400, Type: "invalid_request_error", Message: "prompt is too long: 210000 tokens > 200000 maximum"},
+			contextLength: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := retryable(tc.err); got != tc.retry {
+				t.Errorf("Expected retryable %t, got %t", tc.retry, got)
+			}
+			if got := errors.Is(tc.err, ErrContextLength); got != tc.contextLength {
+				t.Errorf("Expected context length error %t, got %t", tc.contextLength, got)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	if delay := backoff(time.Second, 0, &apiError{StatusCode: 429, RetryAfter: 7 * time.Second}); delay != 7*time.Second {
+		t.Errorf("Expected the Retry-After delay, got %v", delay)
+	}
+	for attempt := 0; attempt < 4; attempt++ {
+		base := time.Second << attempt
+		delay := backoff(time.Second, attempt, errors.New("timeout"))
+		if delay < base/2 || delay > base {
+			t.Errorf("Attempt %d: delay %v outside [%v, %v]", attempt, delay, base/2, base)
+		}
+	}
+	if delay := backoff(time.Second, 30, errors.New("timeout")); delay > maxRetryDelay {
+		t.Errorf("Delay %v exceeds the maximum", delay)
+	}
+	if delay := parseRetryAfter("1.5"); delay != 1500*time.Millisecond {
+		t.Errorf("Expected 1.5s from Retry-After, got %v", delay)
+	}
+}
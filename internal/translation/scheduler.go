@@ -0,0 +1,127 @@
+package translation
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// rateWindow is the period the request and token budgets apply to.
+const rateWindow = time.Minute
+
+// Scheduler admits the LLM requests of every provider and job in the process:
+// at most maxConcurrent run at once, and within any minute at most
+// requestsPerMinute are sent and tokensPerMinute consumed. Zero means no limit.
+type Scheduler struct {
+	slots             chan struct{}
+	requestsPerMinute int
+	tokensPerMinute   int
+
+	mu   sync.Mutex
+	sent []*scheduledRequest
+}
+
+// scheduledRequest is a request in the rate window. Its tokens are an estimate
+// until the reply reports the actual usage.
+type scheduledRequest struct {
+	at     time.Time
+	tokens int
+}
+
+// NewScheduler creates a scheduler with the given limits.
+func NewScheduler(maxConcurrent, requestsPerMinute, tokensPerMinute int) *Scheduler {
+	s := &Scheduler{
+		requestsPerMinute: requestsPerMinute,
+		tokensPerMinute:   tokensPerMinute,
+	}
+	if maxConcurrent > 0 {
+		s.slots = make(chan struct{}, maxConcurrent)
+	}
+	return s
+}
+
+// Acquire blocks until a request estimated at tokens may be sent. The returned
+// release function must be called with the tokens the request actually used
+// (or 0 if unknown) once it is done.
+func (s *Scheduler) Acquire(ctx context.Context, tokens int) (func(used int), error) {
+	if s.slots != nil {
+		select {
+		case s.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	request, err := s.admit(ctx, tokens)
+	if err != nil {
+		if s.slots != nil {
+			<-s.slots
+		}
+		return nil, err
+	}
+
+	var once sync.Once
+	return func(used int) {
+		once.Do(func() {
+			if used > 0 {
+				s.mu.Lock()
+				request.tokens = used
+				s.mu.Unlock()
+			}
+			if s.slots != nil {
+				<-s.slots
+			}
+		})
+	}, nil
+}
+
+// admit waits until the request fits into the rate window and records it.
+func (s *Scheduler) admit(ctx context.Context, tokens int) (*scheduledRequest, error) {
+	for {
+		request, delay := s.tryAdmit(tokens)
+		if request != nil {
+			return request, nil
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// tryAdmit records the request if it fits, or returns how long to wait before
+// trying again.
+func (s *Scheduler) tryAdmit(tokens int) (*scheduledRequest, time.Duration) {
+	if s.requestsPerMinute <= 0 && s.tokensPerMinute <= 0 {
+		return &scheduledRequest{}, 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for len(s.sent) > 0 && now.Sub(s.sent[0].at) >= rateWindow {
+		s.sent = s.sent[1:]
+	}
+
+	used := 0
+	for _, request := range s.sent {
+		used += request.tokens
+	}
+
+	// A request larger than the whole token budget is let through alone
+	fits := (s.requestsPerMinute <= 0 || len(s.sent) < s.requestsPerMinute) &&
+		(s.tokensPerMinute <= 0 || used+tokens <= s.tokensPerMinute || len(s.sent) == 0)
+	if fits {
+		request := &scheduledRequest{at: now, tokens: tokens}
+		s.sent = append(s.sent, request)
+		return request, 0
+	}
+
+	// Wait for the oldest request to leave the window, then check again
+	return nil, s.sent[0].at.Add(rateWindow).Sub(now)
+}
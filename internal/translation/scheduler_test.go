@@ -0,0 +1,61 @@
+package translation
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSchedulerRateWindow(t *testing.T) {
+	testCases := []struct {
+		name     string
+		rpm      int
+		tpm      int
+		tokens   []int
+		admitted int
+	}{
+		{name: "No limits", tokens: []int{100, 100, 100}, admitted: 3},
+		{name: "Requests per minute", rpm: 2, tokens: []int{1, 1, 1}, admitted: 2},
+		{name: "Tokens per minute", tpm: 250, tokens: []int{100, 100, 100}, admitted: 2},
+		{name: "Oversized request runs alone", tpm: 50, tokens: []int{100, 10}, admitted: 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewScheduler(0, tc.rpm, tc.tpm)
+			admitted := 0
+			for _, tokens := range tc.tokens {
+				request, delay := s.tryAdmit(tokens)
+				if request == nil {
+					if delay <= 0 || delay > rateWindow {
+						t.Errorf("Unexpected delay %v", delay)
+					}
+					break
+				}
+				admitted++
+			}
+			if admitted != tc.admitted {
+				t.Errorf("Expected %d requests to be admitted, but got %d", tc.admitted, admitted)
+			}
+		})
+	}
+}
+
+func TestSchedulerConcurrency(t *testing.T) {
+	s := NewScheduler(1, 0, 0)
+	release, err := s.Acquire(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := s.Acquire(ctx, 1); err == nil {
+		t.Fatal("Expected the second request to wait for the first")
+	}
+
+	release(0)
+	if _, err := s.Acquire(context.Background(), 1); err != nil {
+		t.Fatalf("Acquire after release failed: %v", err)
+	}
+}
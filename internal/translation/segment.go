@@ -0,0 +1,373 @@
+package translation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"epub-translator/internal/cache"
+	"epub-translator/internal/epub"
+	"epub-translator/internal/glossary"
+	"epub-translator/internal/tm"
+)
+
+// maxBatchChars caps the source text sent in one batch request, so that long
+// paragraphs do not push a batch past the model's output limit.
+const maxBatchChars = 6000
+
+// maxContextChars caps the preceding paragraphs sent as context.
+const maxContextChars = 3000
+
+// correctionRetries is how often a segment that fails a check is sent back to
+// the provider, with the problems spelled out, before it is flagged.
+const correctionRetries = 1
+
+// segmentSource tells where the translation of a segment came from.
+type segmentSource int
+
+const (
+	fromProvider segmentSource = iota
+	fromCache
+	fromMemory
+	fromCheckpoint
+)
+
+// segmentTranslation is the outcome of translating one segment.
+type segmentTranslation struct {
+	Text   string
+	Source segmentSource
+	// Issues lists the checks the translation still fails after retries.
+	Issues []epub.TranslationIssue
+}
+
+// translateSegment translates a single segment; see translateSegments.
+func (s *Service) translateSegment(ctx context.Context, provider Provider, bookID string, req SegmentRequest) (*segmentTranslation, error) {
+	results, err := s.translateSegments(ctx, provider, bookID, []SegmentRequest{req}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return results[0], nil
+}
+
+// translateSegments translates reqs with exact translation memory matches where
+// there are some, then with the cache, and sends only the rest to the provider,
+// batchSize segments per request. Glossary terms found in the text are added to
+// each request. Clean translations are cached and recorded in the memory so the
+// book can be exported as TMX. Segments found in saved are not translated
+// again, and every finished batch is recorded there.
+func (s *Service) translateSegments(ctx context.Context, provider Provider, bookID string, reqs []SegmentRequest, saved *chapterCheckpoint) ([]*segmentTranslation, error) {
+	results := make([]*segmentTranslation, len(reqs))
+
+	var pending []int
+	for i := range reqs {
+		reqs[i].Glossary = s.glossaryFor(bookID).Match(reqs[i].Text, reqs[i].TargetLang)
+		if result := saved.lookup(i, reqs[i]); result != nil {
+			results[i] = result
+			continue
+		}
+		if result := s.lookupSegment(provider, bookID, reqs[i]); result != nil {
+			results[i] = result
+			continue
+		}
+		pending = append(pending, i)
+	}
+
+	for _, batch := range s.batches(reqs, pending) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		preceding := precedingContext(reqs, results, batch[0], s.config.Translation.ContextParagraphs)
+		batchReqs := make([]SegmentRequest, len(batch))
+		for j, i := range batch {
+			reqs[i].Context = preceding
+			batchReqs[j] = reqs[i]
+		}
+
+		translated, err := s.translateBatch(ctx, provider, batchReqs)
+		if err != nil {
+			return nil, err
+		}
+
+		for j, i := range batch {
+			result, err := s.checkSegment(ctx, provider, reqs[i], translated[j])
+			if err != nil {
+				return nil, err
+			}
+			s.storeSegment(provider, bookID, reqs[i], result)
+			saved.record(i, reqs[i], result)
+			results[i] = result
+		}
+		if err := saved.save(); err != nil {
+			s.logger.Warnf("Failed to save checkpoint: %v", err)
+		}
+	}
+
+	return results, nil
+}
+
+// lookupSegment returns the translation memory match or the cached translation
+// of req, or nil if the provider has to be asked.
+func (s *Service) lookupSegment(provider Provider, bookID string, req SegmentRequest) *segmentTranslation {
+	if entry, ok := s.memoryMatch(req); ok {
+		if bookID != "" && entry.BookID != bookID {
+			if err := s.memory.Use(entry.Source, entry.SourceLang, entry.TargetLang, bookID); err != nil {
+				s.logger.Warnf("Failed to record translation memory use: %v", err)
+			}
+		}
+		return &segmentTranslation{Text: entry.Target, Source: fromMemory}
+	}
+
+	if s.cache == nil {
+		return nil
+	}
+	if translated, ok := s.cache.Get(segmentCacheKey(provider, req)); ok {
+		s.cacheHits.Add(1)
+		return &segmentTranslation{Text: translated, Source: fromCache}
+	}
+	s.cacheMisses.Add(1)
+	return nil
+}
+
+// memoryMatch returns the translation memory entry for req. Imported entries
+// are taken as they are; machine translations were made without the current
+// glossary and checks, so they are only reused when they still pass them.
+func (s *Service) memoryMatch(req SegmentRequest) (tm.Entry, bool) {
+	if s.memory == nil || req.Format != FormatText {
+		return tm.Entry{}, false
+	}
+	entry, ok := s.memory.Lookup(req.Text, req.SourceLang, req.TargetLang)
+	if !ok {
+		return tm.Entry{}, false
+	}
+	if entry.Origin != tm.OriginImport && len(checkTranslation(req, &SegmentResult{Text: entry.Target})) > 0 {
+		return tm.Entry{}, false
+	}
+	return entry, true
+}
+
+// hasTranslation reports whether req would be answered by the translation
+// memory or the cache, without counting it as a hit or miss.
+func (s *Service) hasTranslation(provider Provider, req SegmentRequest) bool {
+	if _, ok := s.memoryMatch(req); ok {
+		return true
+	}
+	if s.cache == nil {
+		return false
+	}
+	_, ok := s.cache.Get(segmentCacheKey(provider, req))
+	return ok
+}
+
+// storeSegment caches a fresh translation and records it in the memory. Only
+// translations without issues are kept, so flagged segments are retried on the
+// next run.
+func (s *Service) storeSegment(provider Provider, bookID string, req SegmentRequest, result *segmentTranslation) {
+	if len(result.Issues) > 0 {
+		return
+	}
+
+	if s.cache != nil {
+		if err := s.cache.Put(segmentCacheKey(provider, req), result.Text); err != nil {
+			s.logger.Warnf("Failed to cache translation: %v", err)
+		}
+	}
+
+	if s.memory != nil && req.Format == FormatText {
+		s.recordSegment(tm.Entry{
+			Source:     strings.TrimSpace(req.Text),
+			Target:     strings.TrimSpace(result.Text),
+			SourceLang: req.SourceLang,
+			TargetLang: req.TargetLang,
+			Origin:     tm.OriginMachine,
+			BookID:     bookID,
+		})
+	}
+}
+
+func (s *Service) recordSegment(entry tm.Entry) {
+	if _, err := s.memory.Add(entry); err != nil {
+		s.logger.Warnf("Failed to record translation memory entry: %v", err)
+	}
+}
+
+// precedingContext returns up to count segments before index together with
+// their translations, oldest first, keeping within maxContextChars. Earlier
+// batches are translated first, so all of them have results.
+func precedingContext(reqs []SegmentRequest, results []*segmentTranslation, index, count int) []ContextParagraph {
+	var context []ContextParagraph
+	chars := 0
+	for i := index - 1; i >= 0 && len(context) < count; i-- {
+		if results[i] == nil {
+			break
+		}
+		paragraph := contextParagraph(reqs[i], results[i])
+		chars += len(paragraph.Source) + len(paragraph.Translation)
+		if chars > maxContextChars && len(context) > 0 {
+			break
+		}
+		context = append([]ContextParagraph{paragraph}, context...)
+	}
+	return context
+}
+
+func contextParagraph(req SegmentRequest, result *segmentTranslation) ContextParagraph {
+	return ContextParagraph{
+		Source:      stripPlaceholders(req.Text),
+		Translation: strings.TrimSpace(stripPlaceholders(result.Text)),
+	}
+}
+
+// batches groups the pending request indexes into runs of at most batchSize
+// segments and maxBatchChars characters.
+func (s *Service) batches(reqs []SegmentRequest, pending []int) [][]int {
+	batchSize := s.batchSize
+	if batchSize < 1 {
+		batchSize = 1
+	}
+
+	var batches [][]int
+	var current []int
+	chars := 0
+	for _, i := range pending {
+		if len(current) > 0 && (len(current) >= batchSize || chars+len(reqs[i].Text) > maxBatchChars) {
+			batches = append(batches, current)
+			current, chars = nil, 0
+		}
+		current = append(current, i)
+		chars += len(reqs[i].Text)
+	}
+	if len(current) > 0 {
+		batches = append(batches, current)
+	}
+	return batches
+}
+
+// translateBatch asks the provider for a batch of segments. A reply that does
+// not map back onto the segments or was cut off at the output limit, or a
+// batch too long for the model's context window, is retried as two halves,
+// down to single segments.
+func (s *Service) translateBatch(ctx context.Context, provider Provider, reqs []SegmentRequest) ([]*SegmentResult, error) {
+	if len(reqs) == 1 {
+		result, err := provider.TranslateSegment(ctx, reqs[0])
+		if err != nil {
+			return nil, err
+		}
+		return []*SegmentResult{result}, nil
+	}
+
+	batch, err := provider.TranslateBatch(ctx, reqs)
+	if err == nil {
+		results := make([]*SegmentResult, len(batch.Texts))
+		for i, text := range batch.Texts {
+			results[i] = &SegmentResult{Text: text}
+		}
+		return results, nil
+	}
+	if !errors.Is(err, ErrBatchMismatch) && !errors.Is(err, ErrContextLength) && !errors.Is(err, ErrTruncated) {
+		return nil, err
+	}
+
+	s.logger.Warnf("Batch of %d segments failed, splitting it: %v", len(reqs), err)
+	half := len(reqs) / 2
+	first, err := s.translateBatch(ctx, provider, reqs[:half])
+	if err != nil {
+		return nil, err
+	}
+	second, err := s.translateBatch(ctx, provider, reqs[half:])
+	if err != nil {
+		return nil, err
+	}
+	return append(first, second...), nil
+}
+
+// checkSegment verifies a translation, re-asking the provider for the segment
+// alone with the problems spelled out before flagging it.
+func (s *Service) checkSegment(ctx context.Context, provider Provider, req SegmentRequest, result *SegmentResult) (*segmentTranslation, error) {
+	failures := checkTranslation(req, result)
+	for attempt := 0; len(failures) > 0 && attempt < correctionRetries; attempt++ {
+		s.logger.Debugf("Translation failed %d checks, retrying", len(failures))
+
+		retry := req
+		retry.Corrections = nil
+		for _, failure := range failures {
+			retry.Corrections = append(retry.Corrections, failure.Correction)
+		}
+
+		var err error
+		if result, err = provider.TranslateSegment(ctx, retry); err != nil {
+			return nil, err
+		}
+		failures = checkTranslation(req, result)
+	}
+
+	translation := &segmentTranslation{Text: result.Text, Source: fromProvider}
+	for _, failure := range failures {
+		translation.Issues = append(translation.Issues, epub.TranslationIssue{
+			Kind:    failure.Kind,
+			Message: failure.Message,
+		})
+	}
+	return translation, nil
+}
+
+// checkFailure is a problem found in a translation.
+type checkFailure struct {
+	Kind string
+	// Correction is sent back to the provider when the segment is retried.
+	Correction string
+	Message    string
+}
+
+// checkTranslation verifies that the reply is a translation of the request
+// (see checkOutput), that every glossary term of the request was used and
+// that placeholders of tagged text came back intact. An empty reply fails
+// only the first check.
+func checkTranslation(req SegmentRequest, result *SegmentResult) []checkFailure {
+	translated := result.Text
+	if countLetters(stripPlaceholders(translated)).total == 0 && countLetters(stripPlaceholders(req.Text)).total > 0 {
+		return []checkFailure{{
+			Kind:       "empty",
+			Correction: "The translation was empty; translate the whole text",
+			Message:    "The translation is empty",
+		}}
+	}
+
+	failures := checkOutput(req, result)
+
+	for _, term := range glossary.Violations(translated, req.Glossary) {
+		failures = append(failures, checkFailure{
+			Kind:       "glossary",
+			Correction: fmt.Sprintf("%q must be translated as %q", term.Source, term.Target),
+			Message:    fmt.Sprintf("%q was not translated as %q", term.Source, term.Target),
+		})
+	}
+
+	if req.Format == FormatTagged {
+		if err := checkPlaceholders(req.Text, translated); err != nil {
+			failures = append(failures, checkFailure{
+				Kind:       "placeholders",
+				Correction: fmt.Sprintf("The placeholders were not kept intact (%v); every placeholder of the source must appear exactly once", err),
+				Message:    fmt.Sprintf("Inline markup could not be restored: %v", err),
+			})
+		}
+	}
+
+	return failures
+}
+
+// segmentCacheKey identifies a translation by everything that shapes it: the
+// normalized text, the language pair, the provider and model, the prompt and
+// its glossary. The provider is part of the key because OpenAI, Azure and
+// local servers may serve different models under the same name. The context
+// paragraphs and the book memory are left out: they only guide the wording,
+// and keying on them would invalidate the rest of a book whenever one segment
+// or note changes.
+func segmentCacheKey(provider Provider, req SegmentRequest) string {
+	parts := []string{cache.Normalize(req.Text), req.SourceLang, req.TargetLang, string(req.Format), provider.Name(), provider.Model(), promptVersion}
+	for _, term := range req.Glossary {
+		parts = append(parts, term.Source+"="+term.Target)
+	}
+	return cache.Key(parts...)
+}
@@ -0,0 +1,207 @@
+package translation
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// blockElements start a new segment. Everything else (links, emphasis,
+// footnote references, ...) is inline and stays part of the segment around it.
+var blockElements = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "body": true,
+	"caption": true, "dd": true, "details": true, "div": true, "dl": true, "dt": true,
+	"figcaption": true, "figure": true, "footer": true, "h1": true, "h2": true, "h3": true,
+	"h4": true, "h5": true, "h6": true, "header": true, "hgroup": true, "hr": true, "li": true,
+	"main": true, "nav": true, "ol": true, "p": true, "pre": true, "section": true,
+	"summary": true, "table": true, "tbody": true, "td": true, "tfoot": true, "th": true,
+	"thead": true, "tr": true, "ul": true,
+}
+
+// skippedElements are never translated.
+var skippedElements = map[string]bool{
+	"script": true, "style": true, "svg": true, "math": true, "head": true, "title": true,
+}
+
+// segment is a run of sibling nodes translated as one unit: the whole content
+// of a block element without nested blocks, or the loose inline content between
+// the nested blocks of one that has them.
+type segment struct {
+	parent *html.Node
+	nodes  []*html.Node
+}
+
+// collectSegments returns the segments below root in document order. Every
+// piece of text belongs to exactly one segment.
+func collectSegments(root *html.Node) []*segment {
+	var segments []*segment
+
+	var walk func(parent *html.Node)
+	walk = func(parent *html.Node) {
+		var run []*html.Node
+		flush := func() {
+			if len(run) > 0 && strings.TrimSpace(nodesText(run)) != "" {
+				segments = append(segments, &segment{parent: parent, nodes: run})
+			}
+			run = nil
+		}
+
+		for child := parent.FirstChild; child != nil; child = child.NextSibling {
+			switch {
+			case child.Type == html.ElementNode && skippedElements[child.Data]:
+				flush()
+			case child.Type == html.ElementNode && (blockElements[child.Data] || containsBlock(child)):
+				flush()
+				walk(child)
+			default:
+				run = append(run, child)
+			}
+		}
+		flush()
+	}
+
+	walk(root)
+	return segments
+}
+
+// segmentRequest builds the request for a segment. Segments with inline markup
+// are sent as tagged text, and tags maps their placeholders back to elements.
+func segmentRequest(seg *segment, sourceLang, targetLang string) (SegmentRequest, []*html.Node) {
+	req := SegmentRequest{
+		Text:       strings.TrimSpace(seg.Text()),
+		SourceLang: sourceLang,
+		TargetLang: targetLang,
+		Format:     FormatText,
+	}
+	if !seg.HasMarkup() {
+		return req, nil
+	}
+
+	text, tags := encodePlaceholders(seg.nodes)
+	req.Text = strings.TrimSpace(text)
+	req.Format = FormatTagged
+	return req, tags
+}
+
+// Text returns the plain text of the segment.
+func (seg *segment) Text() string {
+	return nodesText(seg.nodes)
+}
+
+// HasMarkup reports whether the segment contains inline elements or comments
+// that have to survive the translation.
+func (seg *segment) HasMarkup() bool {
+	for _, node := range seg.nodes {
+		if node.Type != html.TextNode {
+			return true
+		}
+	}
+	return false
+}
+
+// HTML renders the segment's nodes.
+func (seg *segment) HTML() (string, error) {
+	var b strings.Builder
+	for _, node := range seg.nodes {
+		if err := html.Render(&b, node); err != nil {
+			return "", err
+		}
+	}
+	return b.String(), nil
+}
+
+// ReplaceText replaces the segment with a single text node.
+func (seg *segment) ReplaceText(text string) {
+	seg.replace([]*html.Node{{Type: html.TextNode, Data: text}})
+}
+
+// Restore replaces the segment with its translation. When the segment was sent
+// as tagged text, the placeholders are turned back into the elements of tags;
+// if they did not survive the translation it is inserted as plain text.
+func (seg *segment) Restore(source, translated string, tags []*html.Node) error {
+	if tags == nil {
+		seg.ReplaceText(translated)
+		return nil
+	}
+
+	if err := checkPlaceholders(source, translated); err != nil {
+		seg.ReplaceText(stripPlaceholders(translated))
+		return err
+	}
+	nodes, err := restorePlaceholders(translated, tags)
+	if err != nil {
+		seg.ReplaceText(stripPlaceholders(translated))
+		return err
+	}
+	seg.replace(nodes)
+	return nil
+}
+
+func (seg *segment) replace(nodes []*html.Node) {
+	if len(seg.nodes) == 0 {
+		return
+	}
+
+	next := seg.nodes[len(seg.nodes)-1].NextSibling
+	for _, node := range seg.nodes {
+		seg.parent.RemoveChild(node)
+	}
+	for _, node := range nodes {
+		if next != nil {
+			seg.parent.InsertBefore(node, next)
+		} else {
+			seg.parent.AppendChild(node)
+		}
+	}
+	seg.nodes = nodes
+}
+
+// SetAttr sets an attribute on the element that holds the segment.
+func (seg *segment) SetAttr(key, value string) {
+	for i, attr := range seg.parent.Attr {
+		if attr.Key == key {
+			seg.parent.Attr[i].Val = value
+			return
+		}
+	}
+	seg.parent.Attr = append(seg.parent.Attr, html.Attribute{Key: key, Val: value})
+}
+
+func containsBlock(node *html.Node) bool {
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && (blockElements[child.Data] || containsBlock(child)) {
+			return true
+		}
+	}
+	return false
+}
+
+func nodesText(nodes []*html.Node) string {
+	var b strings.Builder
+	var walk func(node *html.Node)
+	walk = func(node *html.Node) {
+		switch {
+		case node.Type == html.TextNode:
+			b.WriteString(node.Data)
+		case node.Type == html.ElementNode && skippedElements[node.Data]:
+			return
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	for _, node := range nodes {
+		walk(node)
+	}
+	return b.String()
+}
+
+// surroundingSpace returns the leading and trailing whitespace of s, which the
+// provider does not see but the document layout may depend on.
+func surroundingSpace(s string) (string, string) {
+	trimmed := strings.TrimLeft(s, " \t\r\n")
+	leading := s[:len(s)-len(trimmed)]
+	trimmed = strings.TrimRight(trimmed, " \t\r\n")
+	trailing := s[len(leading)+len(trimmed):]
+	return leading, trailing
+}
@@ -0,0 +1,109 @@
+package translation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestCollectSegments(t *testing.T) {
+	testCases := []struct {
+		name     string
+		html     string
+		expected []string
+	}{
+		{
+			name:     "Inline markup stays in its paragraph",
+			html:     `<p>See <a href="#n1"><sup>1</sup></a> and <em>this</em>.</p>`,
+			expected: []string{`See <a href="#n1"><sup>1</sup></a> and <em>this</em>.`},
+		},
+		{
+			name:     "Nested blocks are translated once",
+			html:     `<div><div><p>One</p><span>Two</span></div></div>`,
+			expected: []string{`One`, `<span>Two</span>`},
+		},
+		{
+			name:     "Loose text between blocks",
+			html:     `<div>Intro <b>text</b><p>Para</p>Outro</div>`,
+			expected: []string{`Intro <b>text</b>`, `Para`, `Outro`},
+		},
+		{
+			name:     "Scripts and empty blocks are skipped",
+			html:     `<p> </p><script>var x = 1;</script><li>Item</li>`,
+			expected: []string{`Item`},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			doc, err := goquery.NewDocumentFromReader(strings.NewReader(tc.html))
+			if err != nil {
+				t.Fatalf("Failed to parse HTML: %v", err)
+			}
+
+			segments := collectSegments(doc.Get(0))
+			if len(segments) != len(tc.expected) {
+				t.Fatalf("Expected %d segments, but got %d", len(tc.expected), len(segments))
+			}
+			for i, seg := range segments {
+				rendered, err := seg.HTML()
+				if err != nil {
+					t.Fatalf("Failed to render segment %d: %v", i, err)
+				}
+				if strings.TrimSpace(rendered) != tc.expected[i] {
+					t.Errorf("Segment %d does not match.\nExpected: %q\nGot:      %q", i, tc.expected[i], rendered)
+				}
+			}
+		})
+	}
+}
+
+func TestSegmentRestore(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<div>Before<p>The <em>red</em> <a href="#n1">car</a>.</p>After</div>`))
+	if err != nil {
+		t.Fatalf("Failed to parse HTML: %v", err)
+	}
+
+	segments := collectSegments(doc.Get(0))
+	req, tags := segmentRequest(segments[1], "en", "fr")
+	if req.Format != FormatTagged || req.Text != "The <1>red</1> <2>car</2>." {
+		t.Fatalf("Unexpected request: %+v", req)
+	}
+
+	if err := segments[1].Restore(req.Text, "La <2>voiture</2> <1>rouge</1>.", tags); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if err := segments[0].Restore("Before", "Avant", nil); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if err := segments[2].Restore("After", "Après", nil); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+
+	got, _ := doc.Find("body").Html()
+	expected := `<div>Avant<p>La <a href="#n1">voiture</a> <em>rouge</em>.</p>Après</div>`
+	if got != expected {
+		t.Errorf("Document does not match.\nExpected: %q\nGot:      %q", expected, got)
+	}
+}
+
+func TestPrecedingContext(t *testing.T) {
+	reqs := []SegmentRequest{{Text: "One"}, {Text: "The <1>two</1>"}, {Text: "Three"}, {Text: "Four"}}
+	results := []*segmentTranslation{{Text: "Un"}, {Text: "Le <1>deux</1>"}, {Text: "Trois"}, nil}
+
+	context := precedingContext(reqs, results, 3, 2)
+	expected := []ContextParagraph{{Source: "The two", Translation: "Le deux"}, {Source: "Three", Translation: "Trois"}}
+	if len(context) != len(expected) {
+		t.Fatalf("Expected %d paragraphs, but got %d", len(expected), len(context))
+	}
+	for i := range expected {
+		if context[i] != expected[i] {
+			t.Errorf("Paragraph %d does not match.\nExpected: %+v\nGot:      %+v", i, expected[i], context[i])
+		}
+	}
+
+	if context := precedingContext(reqs, results, 0, 2); len(context) != 0 {
+		t.Errorf("Expected no context for the first segment, but got %+v", context)
+	}
+}
@@ -1,15 +1,27 @@
 package translation
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"epub-translator/internal/cache"
+	"epub-translator/internal/config"
 	"epub-translator/internal/epub"
+	"epub-translator/internal/glossary"
+	"epub-translator/internal/jobs"
+	"epub-translator/internal/tm"
+	"epub-translator/internal/usage"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/net/html"
 )
 
 // WebSocketBroadcaster interface for broadcasting messages
@@ -19,28 +31,284 @@ type WebSocketBroadcaster interface {
 }
 
 type Service struct {
-	openai     *OpenAIClient
-	logger     *logrus.Logger
-	batchSize  int
-	progress   map[string]*epub.TranslationProgress
-	progressMu sync.RWMutex
-	wsHub      WebSocketBroadcaster
+	config      *config.Config
+	provider    Provider
+	providers   map[string]Provider
+	providersMu sync.Mutex
+	logger      *logrus.Logger
+	batchSize   int
+	progress    map[string]*epub.TranslationProgress
+	progressMu  sync.RWMutex
+	wsHub       WebSocketBroadcaster
+	// parser saves and restores the chapter checkpoints
+	parser *epub.Parser
+
+	// cache is nil when caching is disabled
+	cache       *cache.Store
+	cacheHits   atomic.Int64
+	cacheMisses atomic.Int64
+
+	// memory is nil when the translation memory is disabled
+	memory *tm.Memory
+	// jobStore persists the progress of translations; it is nil until
+	// RestoreJobs opens it
+	jobStore *jobs.Store
+	// recorder writes the usage of every LLM request to the usage ledger
+	recorder *usageRecorder
+
+	globalGlossary *glossary.Glossary
+	glossaries     map[string]*glossary.Glossary
+	glossariesMu   sync.RWMutex
+
+	drafts   map[string]*TerminologyDraft
+	draftsMu sync.RWMutex
+
+	// scheduler limits the LLM requests of every provider
+	scheduler *Scheduler
+	// daily charges the LLM requests of every provider to the daily budget;
+	// it is nil without a daily limit
+	daily *budgetAccount
+
+	bookMemories   map[string]*BookMemory
+	bookMemoriesMu sync.RWMutex
+
+	// bookStateDir keeps the glossary, terminology draft and memory of every
+	// book across restarts; it is empty until RestoreJobs sets it
+	bookStateDir string
+	bookStatesMu sync.Mutex
+
+	// jobs holds the running book translations by book ID
+	jobs   map[string]*runningJob
+	jobsMu sync.Mutex
 }
 
-func NewService(openai *OpenAIClient, logger *logrus.Logger, batchSize int, wsHub WebSocketBroadcaster) *Service {
-	return &Service{
-		openai:    openai,
-		logger:    logger,
-		batchSize: batchSize,
-		progress:  make(map[string]*epub.TranslationProgress),
-		wsHub:     wsHub,
+// translationJob carries the settings of one book translation.
+type translationJob struct {
+	book       *epub.EPUB
+	provider   Provider
+	sourceLang string
+	targetLang string
+	progressID string
+	checkpoint *checkpoint
+	// budget is charged with the requests of the translation
+	budget *budgetAccount
+	// usage adds up the requests of the translation by chapter
+	usage *jobUsage
+
+	cacheHits   atomic.Int64
+	cacheMisses atomic.Int64
+	memoryHits  atomic.Int64
+
+	// issues holds the issues flagged so far by chapter ID, as chapters
+	// translated in parallel report them out of order
+	issuesMu sync.Mutex
+	issues   map[string][]epub.TranslationIssue
+}
+
+func (job *translationJob) addIssue(issue epub.TranslationIssue) {
+	job.issuesMu.Lock()
+	defer job.issuesMu.Unlock()
+	if job.issues == nil {
+		job.issues = make(map[string][]epub.TranslationIssue)
+	}
+	job.issues[issue.ChapterID] = append(job.issues[issue.ChapterID], issue)
+}
+
+// Issues returns a copy of the issues flagged so far in chapter order.
+func (job *translationJob) Issues() []epub.TranslationIssue {
+	job.issuesMu.Lock()
+	defer job.issuesMu.Unlock()
+
+	var issues []epub.TranslationIssue
+	for i := range job.book.Chapters {
+		issues = append(issues, job.issues[job.book.Chapters[i].ID]...)
+	}
+	return issues
+}
+
+// NewService creates a translation service whose default provider is
+// cfg.Translation.Provider. Other registered providers are created on demand.
+func NewService(cfg *config.Config, logger *logrus.Logger, wsHub WebSocketBroadcaster) (*Service, error) {
+	s := &Service{
+		config:     cfg,
+		providers:  make(map[string]Provider),
+		logger:     logger,
+		batchSize:  cfg.Translation.BatchSize,
+		progress:   make(map[string]*epub.TranslationProgress),
+		wsHub:      wsHub,
+		parser:     epub.NewParser(logger, cfg.App.TempDir),
+		glossaries: make(map[string]*glossary.Glossary),
+		drafts:     make(map[string]*TerminologyDraft),
+
+		bookMemories: make(map[string]*BookMemory),
+		jobs:         make(map[string]*runningJob),
+		scheduler: NewScheduler(
+			cfg.RateLimit.MaxConcurrent,
+			cfg.RateLimit.RequestsPerMinute,
+			cfg.RateLimit.TokensPerMinute,
+		),
+	}
+
+	globalGlossary, err := loadGlobalGlossary(cfg)
+	if err != nil {
+		return nil, err
+	}
+	s.globalGlossary = globalGlossary
+
+	daily, err := openDailyBudget(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+	s.daily = daily
+
+	ledger, err := usage.Open(cfg.UsageLedgerPath())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open usage ledger: %w", err)
+	}
+	logger.Debugf("Usage ledger %s loaded with %d requests", ledger.Path(), ledger.Len())
+	s.recorder = &usageRecorder{ledger: ledger, price: cfg.ModelPrice, logger: logger}
+
+	if cfg.Cache.Enabled {
+		store, err := cache.Open(cfg.CachePath())
+		if err != nil {
+			return nil, fmt.Errorf("failed to open translation cache: %w", err)
+		}
+		logger.Debugf("Translation cache %s loaded with %d entries", store.Path(), store.Len())
+		s.cache = store
+	}
+
+	if cfg.TranslationMemory.Enabled {
+		memory, err := tm.Open(cfg.TranslationMemoryPath())
+		if err != nil {
+			return nil, fmt.Errorf("failed to open translation memory: %w", err)
+		}
+		logger.Debugf("Translation memory %s loaded with %d segments", cfg.TranslationMemoryPath(), memory.Len())
+		s.memory = memory
+	}
+
+	defaultProvider := cfg.Translation.Provider
+	if defaultProvider == "" {
+		defaultProvider = "openai"
+	}
+
+	provider, err := s.Provider(defaultProvider)
+	if err != nil {
+		return nil, err
+	}
+	s.provider = provider
+
+	return s, nil
+}
+
+// Close releases the translation cache, memory, job store and usage ledger.
+func (s *Service) Close() error {
+	var firstErr error
+	if s.cache != nil {
+		firstErr = s.cache.Close()
+	}
+	if s.recorder != nil {
+		if err := s.recorder.ledger.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if s.memory != nil {
+		if err := s.memory.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if s.jobStore != nil {
+		if err := s.jobStore.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
 	}
+	return firstErr
 }
 
-func (s *Service) DetectLanguage(epubContent *epub.EPUB) (string, error) {
+// ImportTMX adds the translation units of a TMX document to the translation
+// memory and returns the number of new entries.
+func (s *Service) ImportTMX(r io.Reader) (int, error) {
+	if s.memory == nil {
+		return 0, fmt.Errorf("translation memory is disabled")
+	}
+
+	entries, err := tm.ReadTMX(r)
+	if err != nil {
+		return 0, err
+	}
+
+	added, err := s.memory.Add(entries...)
+	if err != nil {
+		return added, err
+	}
+
+	s.logger.Infof("Imported %d translation memory entries (%d units read)", added, len(entries))
+	return added, nil
+}
+
+// ExportTMX writes the memory entries of a book as TMX. Empty arguments match
+// every book or language.
+func (s *Service) ExportTMX(w io.Writer, bookID, sourceLang, targetLang string) (int, error) {
+	if s.memory == nil {
+		return 0, fmt.Errorf("translation memory is disabled")
+	}
+
+	entries := s.memory.Entries(bookID, sourceLang, targetLang)
+	return len(entries), tm.WriteTMX(w, entries)
+}
+
+// CacheStats returns the cache hits and misses since the service started.
+func (s *Service) CacheStats() (hits, misses int64) {
+	return s.cacheHits.Load(), s.cacheMisses.Load()
+}
+
+// Provider returns the provider registered under name, creating it on first
+// use. An empty name selects the default provider.
+func (s *Service) Provider(name string) (Provider, error) {
+	if name == "" {
+		return s.provider, nil
+	}
+
+	s.providersMu.Lock()
+	defer s.providersMu.Unlock()
+
+	if provider, exists := s.providers[name]; exists {
+		return provider, nil
+	}
+
+	provider, err := NewProvider(name, s.config, s.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.wsHub != nil {
+		if llmLogger, ok := provider.(interface{ SetWebSocketBroadcaster(WebSocketBroadcaster) }); ok {
+			llmLogger.SetWebSocketBroadcaster(s.wsHub)
+		}
+	}
+	if limited, ok := provider.(interface{ SetScheduler(*Scheduler) }); ok {
+		limited.SetScheduler(s.scheduler)
+	}
+	if budgeted, ok := provider.(interface{ setDailyBudget(*budgetAccount) }); ok && s.daily != nil {
+		budgeted.setDailyBudget(s.daily)
+	}
+	if recorded, ok := provider.(interface{ setUsageRecorder(*usageRecorder) }); ok && s.recorder != nil {
+		recorded.setUsageRecorder(s.recorder)
+	}
+	if counted, ok := provider.(interface{ tokenizer() *tokenizer }); ok {
+		if tk := counted.tokenizer(); tk.encoding != "" && tk.encoder() == nil {
+			s.logger.Warnf("The %s vocabulary of %s is not bundled, its token counts are estimated; run make vocab and rebuild", tk.name, provider.Model())
+		}
+	}
+
+	s.providers[name] = provider
+	return provider, nil
+}
+
+func (s *Service) DetectLanguage(ctx context.Context, epubContent *epub.EPUB) (string, error) {
 	if len(epubContent.Chapters) == 0 {
 		return "", fmt.Errorf("no chapters found for language detection")
 	}
+	ctx = withBook(ctx, epubContent.ID)
 
 	var textSamples []string
 	maxSamples := 3
@@ -65,8 +333,8 @@ func (s *Service) DetectLanguage(epubContent *epub.EPUB) (string, error) {
 	}
 
 	combinedText := strings.Join(textSamples, "\n\n")
-	
-	detectedLang, err := s.openai.DetectLanguage(combinedText)
+
+	detectedLang, err := s.provider.DetectLanguage(ctx, combinedText)
 	if err != nil {
 		return "", fmt.Errorf("failed to detect language: %w", err)
 	}
@@ -75,115 +343,453 @@ func (s *Service) DetectLanguage(epubContent *epub.EPUB) (string, error) {
 	return detectedLang, nil
 }
 
-func (s *Service) StartTranslation(epubContent *epub.EPUB, sourceLang, targetLang string) error {
-	progressID := epubContent.ID
+// StartTranslation translates the book in the background with the named
+// provider (empty for the default one). The translation stops when ctx is
+// cancelled or CancelTranslation is called for the book, and pauses when its
+// next request would go over budget; limits budget leaves unset come from the
+// configuration. A checkpoint left by an earlier translation into targetLang
+// is discarded.
+func (s *Service) StartTranslation(ctx context.Context, epubContent *epub.EPUB, sourceLang, targetLang, providerName string, budget Budget) error {
+	cp := newCheckpoint(s.checkpointPath(epubContent.ID, targetLang), epubContent.ID, sourceLang, targetLang, providerName)
+	cp.Budget = withDefaults(budget, s.config)
+	return s.startJob(ctx, epubContent, cp)
+}
 
-	progress := &epub.TranslationProgress{
-		ID:               progressID,
-		SourceLanguage:   sourceLang,
-		TargetLanguage:   targetLang,
-		TotalChapters:    len(epubContent.Chapters),
-		CompletedChapters: 0,
-		Status:           "in_progress",
-		StartedAt:        time.Now(),
+// ResumeTranslation continues a paused, cancelled or failed translation of the
+// book into targetLang in the background. Chapters and segments finished
+// before it stopped are taken from its checkpoint, and so is what it has spent
+// of its budget. The limits set in budget replace the ones of the checkpoint.
+func (s *Service) ResumeTranslation(ctx context.Context, epubContent *epub.EPUB, targetLang string, budget Budget) error {
+	cp, err := loadCheckpoint(s.checkpointPath(epubContent.ID, targetLang))
+	if err != nil {
+		return err
+	}
+	if budget.MaxTokens != 0 {
+		cp.Budget.MaxTokens = budget.MaxTokens
+	}
+	if budget.MaxCost != 0 {
+		cp.Budget.MaxCost = budget.MaxCost
 	}
 
-	s.setProgress(progressID, progress)
+	s.logger.Infof("Resuming translation of book %s into %s after %d chapters", epubContent.ID, targetLang, cp.completedChapters())
+	return s.startJob(ctx, epubContent, cp)
+}
+
+func (s *Service) startJob(ctx context.Context, epubContent *epub.EPUB, cp *checkpoint) error {
+	provider, err := s.Provider(cp.Provider)
+	if err != nil {
+		return err
+	}
+	if err := s.checkBudget(provider, cp.Budget); err != nil {
+		return err
+	}
+
+	jobCtx, done, err := s.beginJob(ctx, epubContent.ID)
+	if err != nil {
+		return err
+	}
 
 	go func() {
-		if err := s.translateChapters(epubContent, sourceLang, targetLang, progressID); err != nil {
-			s.logger.Errorf("Translation failed: %v", err)
-			progress.Status = "failed"
-			progress.ErrorMessage = err.Error()
-			progress.CompletedAt = time.Now()
-			s.setProgress(progressID, progress)
-		} else {
-			s.logger.Infof("Translation completed successfully")
-			progress.Status = "completed"
-			progress.CompletedAt = time.Now()
-			s.setProgress(progressID, progress)
-		}
+		defer done()
+		_ = s.translateBook(jobCtx, epubContent, provider, cp)
 	}()
 
 	return nil
 }
 
-func (s *Service) translateChapters(epubContent *epub.EPUB, sourceLang, targetLang, progressID string) error {
-	for i := range epubContent.Chapters {
-		chapter := &epubContent.Chapters[i]
-		
-		progress := s.getProgress(progressID)
+// TranslateBook translates every chapter of the book with the configured
+// budget and blocks until it is done. Progress is recorded under the book ID
+// exactly as for StartTranslation. A checkpoint left by an earlier
+// translation into targetLang with the same languages and provider is
+// continued, together with what it has spent.
+func (s *Service) TranslateBook(ctx context.Context, epubContent *epub.EPUB, sourceLang, targetLang, providerName string) error {
+	provider, err := s.Provider(providerName)
+	if err != nil {
+		return err
+	}
+	budget := withDefaults(Budget{}, s.config)
+	if err := s.checkBudget(provider, budget); err != nil {
+		return err
+	}
+
+	jobCtx, done, err := s.beginJob(ctx, epubContent.ID)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	path := s.checkpointPath(epubContent.ID, targetLang)
+	cp, err := loadCheckpoint(path)
+	switch {
+	case err == nil && cp.SourceLang == sourceLang && cp.Provider == providerName:
+		s.logger.Infof("Resuming translation of book %s into %s after %d chapters", epubContent.ID, targetLang, cp.completedChapters())
+	case err != nil && !errors.Is(err, ErrNoCheckpoint):
+		s.logger.Warnf("Ignoring checkpoint: %v", err)
+		fallthrough
+	default:
+		cp = newCheckpoint(path, epubContent.ID, sourceLang, targetLang, providerName)
+	}
+	cp.Budget = budget
+	return s.translateBook(jobCtx, epubContent, provider, cp)
+}
+
+// checkpointPath is where the checkpoint of the book's translation into
+// targetLang is kept, next to the book's _translated_<lang> directory.
+func (s *Service) checkpointPath(bookID, targetLang string) string {
+	return filepath.Join(s.config.App.TempDir, fmt.Sprintf("%s_checkpoint_%s.json", bookID, targetLang))
+}
+
+// ClearCheckpoints removes the checkpoints of every translation of the book.
+func (s *Service) ClearCheckpoints(bookID string) {
+	paths, _ := filepath.Glob(s.checkpointPath(bookID, "*"))
+	for _, path := range paths {
+		if err := (&checkpoint{path: path}).remove(); err != nil {
+			s.logger.Warnf("Failed to remove checkpoint %s: %v", path, err)
+		}
+	}
+}
+
+func (s *Service) translateBook(ctx context.Context, epubContent *epub.EPUB, provider Provider, cp *checkpoint) error {
+	job := &translationJob{
+		book:       epubContent,
+		provider:   provider,
+		sourceLang: cp.SourceLang,
+		targetLang: cp.TargetLang,
+		progressID: epubContent.ID,
+		checkpoint: cp,
+		usage:      newJobUsage(cp.Usage),
+	}
+	spent, chapterUsage := job.usage.snapshot()
+	job.budget = newBudgetAccount("job", cp.Budget, Spend{Tokens: spent.TotalTokens, Cost: spent.Cost}, s.config)
+
+	// A request refused by a budget pauses the translation
+	ctx, stop := context.WithCancelCause(ctx)
+	defer stop(nil)
+	ctx = withJobBudget(ctx, job.budget, stop)
+	ctx = withUsageScope(ctx, usageScope{bookID: epubContent.ID, jobID: cp.JobID, job: job.usage})
+	s.startBookMemory(epubContent.ID, job.targetLang)
+
+	if err := cp.save(); err != nil {
+		s.logger.Warnf("Failed to save checkpoint: %v", err)
+	}
+
+	s.setProgress(job.progressID, &epub.TranslationProgress{
+		ID:                job.progressID,
+		SourceLanguage:    job.sourceLang,
+		TargetLanguage:    job.targetLang,
+		Provider:          provider.Name(),
+		Model:             provider.Model(),
+		TotalChapters:     len(epubContent.Chapters),
+		CompletedChapters: 0,
+		Status:            "in_progress",
+		StartedAt:         time.Now(),
+		JobID:             cp.JobID,
+		Usage:             spent,
+		ChapterUsage:      chapterUsage,
+	})
+
+	err := s.translateChapters(ctx, job)
+
+	cp.setUsage(job.usage)
+	progress := s.getProgress(job.progressID)
+	if progress == nil {
+		return err
+	}
+
+	progress.CompletedAt = time.Now()
+	progress.CacheHits = int(job.cacheHits.Load())
+	progress.CacheMisses = int(job.cacheMisses.Load())
+	progress.MemoryHits = int(job.memoryHits.Load())
+	progress.Issues = job.Issues()
+	progress.Usage, progress.ChapterUsage = job.usage.snapshot()
+	switch {
+	case err != nil && errors.Is(context.Cause(ctx), ErrBudgetExceeded):
+		reason := context.Cause(ctx).Error()
+		s.logger.Warnf("Translation paused after %d/%d chapters: %s", progress.CompletedChapters, progress.TotalChapters, reason)
+		progress.Status = "paused"
+		progress.PauseReason = reason
+		err = context.Cause(ctx)
+		if s.wsHub != nil {
+			s.wsHub.BroadcastMessage("budget_exceeded", map[string]interface{}{
+				"epub_id": progress.ID,
+				"reason":  reason,
+				"budget":  cp.Budget,
+				"spent":   job.budget.Spent(),
+			})
+		}
+	case err != nil && errors.Is(context.Cause(ctx), ErrPaused):
+		s.logger.Infof("Translation paused after %d/%d chapters", progress.CompletedChapters, progress.TotalChapters)
+		progress.Status = "paused"
+	case err != nil && errors.Is(context.Cause(ctx), ErrShutdown):
+		s.logger.Infof("Translation interrupted after %d/%d chapters", progress.CompletedChapters, progress.TotalChapters)
+		progress.Status = "interrupted"
+	case err != nil && ctx.Err() != nil:
+		s.logger.Infof("Translation cancelled after %d/%d chapters", progress.CompletedChapters, progress.TotalChapters)
+		progress.Status = "cancelled"
+	case err != nil:
+		s.logger.Errorf("Translation failed: %v", err)
+		progress.Status = "failed"
+		progress.ErrorMessage = err.Error()
+	default:
+		s.logger.Infof("Translation completed successfully")
+		progress.Status = "completed"
+		if err := cp.remove(); err != nil {
+			s.logger.Warnf("Failed to remove checkpoint: %v", err)
+		}
+	}
+	if progress.Status != "completed" {
+		if err := cp.save(); err != nil {
+			s.logger.Warnf("Failed to save checkpoint: %v", err)
+		}
+	}
+	s.setProgress(job.progressID, progress)
+
+	return err
+}
+
+// chapterResult is the outcome of translating one chapter.
+type chapterResult struct {
+	// restored is set for a chapter taken from the checkpoint
+	restored   bool
+	paragraphs []ContextParagraph
+	err        error
+}
+
+// translateChapters translates up to translation.parallel_chapters chapters at
+// once. Finished chapters are checkpointed, summarised into the book memory and
+// counted in the progress strictly in chapter order, so that progress events
+// and the result match a sequential run. A chapter starts only once the
+// chapter parallel_chapters before it is done, so it is translated with the
+// book memory of every chapter up to that one.
+func (s *Service) translateChapters(ctx context.Context, job *translationJob) error {
+	chapters := job.book.Chapters
+	parallel := s.config.Translation.ParallelChapters
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	// A failed chapter stops the ones translated alongside it
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	pending := make([]chan chapterResult, len(chapters))
+	started := 0
+	for i := range chapters {
+		for started < len(chapters) && started < i+parallel && ctx.Err() == nil {
+			result := make(chan chapterResult, 1)
+			pending[started] = result
+			// The memory is taken now rather than when the goroutine runs, so
+			// that the chapter sees the notes of exactly the chapters before
+			go func(chapter *epub.Chapter, memory *BookMemory) {
+				result <- s.translateChapter(ctx, job, chapter, memory)
+			}(&chapters[started], s.BookMemory(job.book.ID))
+			started++
+		}
+		if pending[i] == nil {
+			return ctx.Err()
+		}
+
+		chapter := &chapters[i]
+		progress := s.getProgress(job.progressID)
 		if progress != nil {
 			progress.CurrentChapter = chapter.Title
-			s.setProgress(progressID, progress)
+			s.setProgress(job.progressID, progress)
 		}
 
-		s.logger.Debugf("Translating chapter %d/%d: %s", i+1, len(epubContent.Chapters), chapter.Title)
-
-		translatedContent, err := s.translateChapterContent(chapter.Content, sourceLang, targetLang)
-		if err != nil {
-			return fmt.Errorf("failed to translate chapter %s: %w", chapter.Title, err)
+		result := <-pending[i]
+		if result.err != nil {
+			cancel()
+			for _, other := range pending[i+1 : started] {
+				<-other
+			}
+			return fmt.Errorf("failed to translate chapter %s: %w", chapter.Title, result.err)
 		}
 
-		chapter.TranslatedContent = translatedContent
-		chapter.IsTranslated = true
+		if result.restored {
+			s.logger.Debugf("Chapter %d/%d restored from checkpoint: %s", i+1, len(chapters), chapter.Title)
+		} else {
+			s.checkpointChapter(job, chapter)
+
+			// The last chapter has no later prompts to inform
+			if i < len(chapters)-1 {
+				s.updateBookMemory(withChapter(ctx, chapter.ID), job, chapter, result.paragraphs)
+			}
+			s.logger.Debugf("Completed chapter %d/%d", i+1, len(chapters))
+		}
 
 		if progress != nil {
 			progress.CompletedChapters++
-			s.setProgress(progressID, progress)
+			progress.CacheHits = int(job.cacheHits.Load())
+			progress.CacheMisses = int(job.cacheMisses.Load())
+			progress.MemoryHits = int(job.memoryHits.Load())
+			progress.Issues = job.Issues()
+			progress.Usage, progress.ChapterUsage = job.usage.snapshot()
+			s.setProgress(job.progressID, progress)
 		}
-
-		s.logger.Debugf("Completed chapter %d/%d", i+1, len(epubContent.Chapters))
 	}
 
 	return nil
 }
 
-func (s *Service) translateChapterContent(htmlContent, sourceLang, targetLang string) (string, error) {
-	if strings.TrimSpace(htmlContent) == "" {
-		return htmlContent, nil
+// translateChapter restores the chapter from the checkpoint or translates it
+// with the given book memory.
+func (s *Service) translateChapter(ctx context.Context, job *translationJob, chapter *epub.Chapter, memory *BookMemory) chapterResult {
+	if s.restoreChapter(job, chapter) {
+		return chapterResult{restored: true}
 	}
 
-	doc, err := goquery.NewDocumentFromReader(strings.NewReader(htmlContent))
+	s.logger.Debugf("Translating chapter: %s", chapter.Title)
+
+	translatedContent, paragraphs, err := s.translateChapterContent(withChapter(ctx, chapter.ID), job, chapter, memory)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse HTML: %w", err)
+		return chapterResult{err: err}
 	}
 
-	var translationErr error
+	chapter.TranslatedContent = translatedContent
+	chapter.IsTranslated = true
+	return chapterResult{paragraphs: paragraphs}
+}
 
-	doc.Find("p, h1, h2, h3, h4, h5, h6, div, span, li, td, th").Each(func(i int, selection *goquery.Selection) {
-		if translationErr != nil {
-			return
-		}
+// translateChapterContent returns the translated chapter and its paragraphs
+// with their translations.
+func (s *Service) translateChapterContent(ctx context.Context, job *translationJob, chapter *epub.Chapter, memory *BookMemory) (string, []ContextParagraph, error) {
+	htmlContent := chapter.Content
+	if strings.TrimSpace(htmlContent) == "" {
+		return htmlContent, nil, nil
+	}
 
-		text := strings.TrimSpace(selection.Text())
-		if text == "" {
-			return
-		}
+	doc, segments, err := parseChapter(htmlContent)
+	if err != nil {
+		return "", nil, err
+	}
 
-		translatedText, err := s.openai.TranslateText(text, sourceLang, targetLang)
-		if err != nil {
-			translationErr = fmt.Errorf("failed to translate text segment: %w", err)
-			return
-		}
+	reqs := make([]SegmentRequest, len(segments))
+	tags := make([][]*html.Node, len(segments))
+	for i, seg := range segments {
+		reqs[i], tags[i] = segmentRequest(seg, job.sourceLang, job.targetLang)
+		reqs[i].Memory = memory
+	}
 
-		selection.SetText(translatedText)
-	})
+	results, err := s.translateSegments(ctx, job.provider, job.book.ID, reqs, job.checkpoint.chapter(chapter.ID))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to translate text segments: %w", err)
+	}
 
-	if translationErr != nil {
-		return "", translationErr
+	paragraphs := make([]ContextParagraph, len(segments))
+	for i, seg := range segments {
+		paragraphs[i] = contextParagraph(reqs[i], results[i])
+		s.applySegment(job, chapter, seg, reqs[i], tags[i], results[i])
 	}
 
 	result, err := doc.Find("body").Html()
 	if err != nil {
 		html, htmlErr := doc.Html()
 		if htmlErr != nil {
-			return "", fmt.Errorf("failed to extract HTML: %w", htmlErr)
+			return "", nil, fmt.Errorf("failed to extract HTML: %w", htmlErr)
 		}
-		return html, nil
+		return html, paragraphs, nil
 	}
 
-	return result, nil
+	return result, paragraphs, nil
+}
+
+// parseChapter parses the HTML of a chapter and collects the segments of its
+// body.
+func parseChapter(htmlContent string) (*goquery.Document, []*segment, error) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(htmlContent))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse HTML: %w", err)
+	}
+
+	root := doc.Selection
+	if body := doc.Find("body"); body.Length() > 0 {
+		root = body
+	}
+	return doc, collectSegments(root.Get(0)), nil
+}
+
+// applySegment writes the translation of a segment into the chapter, restoring
+// its inline markup from the placeholders, and records the segment's issues.
+func (s *Service) applySegment(job *translationJob, chapter *epub.Chapter, seg *segment, req SegmentRequest, tags []*html.Node, result *segmentTranslation) {
+	source := seg.Text()
+	leading, trailing := surroundingSpace(source)
+
+	switch {
+	case result.Source == fromCheckpoint:
+	case result.Source == fromMemory:
+		job.memoryHits.Add(1)
+	case result.Source == fromCache:
+		job.cacheHits.Add(1)
+	case s.cache != nil:
+		job.cacheMisses.Add(1)
+	}
+
+	// A translation with broken placeholders is inserted as plain text. It has
+	// already been flagged unless it came from an older cache entry.
+	issues := result.Issues
+	if err := seg.Restore(req.Text, leading+strings.TrimSpace(result.Text)+trailing, tags); err != nil && !hasIssue(issues, "placeholders") {
+		issues = append(issues, epub.TranslationIssue{
+			Kind:    "placeholders",
+			Message: fmt.Sprintf("Inline markup could not be restored: %v", err),
+		})
+	}
+
+	if len(issues) > 0 {
+		seg.SetAttr("data-translation-issue", issues[0].Kind)
+		for _, issue := range issues {
+			issue.ChapterID = chapter.ID
+			issue.Segment = truncateText(strings.TrimSpace(source), 200)
+			chapter.Issues = append(chapter.Issues, issue)
+			job.addIssue(issue)
+		}
+	}
+}
+
+// checkpointChapter saves a finished chapter to the book's _translated_<lang>
+// directory and marks it done in the checkpoint. A failed save only costs the
+// chapter being translated again on resume.
+func (s *Service) checkpointChapter(job *translationJob, chapter *epub.Chapter) {
+	if err := s.parser.SaveTranslatedChapter(job.book.ID, chapter.FilePath, chapter.TranslatedContent, job.targetLang); err != nil {
+		s.logger.Warnf("Failed to save checkpoint of chapter %s: %v", chapter.Title, err)
+		return
+	}
+	job.checkpoint.setUsage(job.usage)
+	if err := job.checkpoint.finishChapter(chapter.ID, chapter.Issues); err != nil {
+		s.logger.Warnf("Failed to save checkpoint: %v", err)
+	}
+}
+
+// restoreChapter loads a chapter the checkpoint lists as done from the
+// _translated_<lang> directory. It reports false if the chapter has to be
+// translated.
+func (s *Service) restoreChapter(job *translationJob, chapter *epub.Chapter) bool {
+	issues, done := job.checkpoint.chapterDone(chapter.ID)
+	if !done {
+		return false
+	}
+
+	content, err := s.parser.LoadTranslatedChapter(job.book.ID, chapter.FilePath, job.targetLang)
+	if err != nil {
+		s.logger.Warnf("Failed to restore chapter %s from checkpoint, translating it again: %v", chapter.Title, err)
+		return false
+	}
+
+	chapter.TranslatedContent = content
+	chapter.IsTranslated = true
+	chapter.Issues = issues
+	for _, issue := range issues {
+		job.addIssue(issue)
+	}
+	return true
+}
+
+func hasIssue(issues []epub.TranslationIssue, kind string) bool {
+	for _, issue := range issues {
+		if issue.Kind == kind {
+			return true
+		}
+	}
+	return false
 }
 
 func (s *Service) extractPlainText(htmlContent string) string {
@@ -202,28 +808,34 @@ func (s *Service) GetProgress(progressID string) *epub.TranslationProgress {
 func (s *Service) getProgress(progressID string) *epub.TranslationProgress {
 	s.progressMu.RLock()
 	defer s.progressMu.RUnlock()
-	
+
 	if progress, exists := s.progress[progressID]; exists {
 		progressCopy := *progress
 		return &progressCopy
 	}
-	
+
 	return nil
 }
 
 func (s *Service) setProgress(progressID string, progress *epub.TranslationProgress) {
 	s.progressMu.Lock()
 	defer s.progressMu.Unlock()
-	
+
 	s.progress[progressID] = progress
-	
+
+	if s.jobStore != nil {
+		if err := s.jobStore.Put(progress); err != nil {
+			s.logger.Warnf("Failed to save job %s: %v", progressID, err)
+		}
+	}
+
 	// Broadcast progress update via WebSocket if hub is available
 	if s.wsHub != nil {
 		progressPercent := float64(0)
 		if progress.TotalChapters > 0 {
 			progressPercent = (float64(progress.CompletedChapters) / float64(progress.TotalChapters)) * 100
 		}
-		
+
 		progressMsg := map[string]interface{}{
 			"epub_id":            progress.ID,
 			"total_chapters":     progress.TotalChapters,
@@ -232,19 +844,28 @@ func (s *Service) setProgress(progressID string, progress *epub.TranslationProgr
 			"progress_percent":   progressPercent,
 			"status":             progress.Status,
 		}
-		
+
 		s.wsHub.BroadcastMessage("translation_progress", progressMsg)
-		
+
 		// Broadcast status change logs
 		switch progress.Status {
 		case "in_progress":
 			if progress.CurrentChapter != "" {
-				s.wsHub.BroadcastLog("info", fmt.Sprintf("Translating chapter: %s (%d/%d)", 
+				s.wsHub.BroadcastLog("info", fmt.Sprintf("Translating chapter: %s (%d/%d)",
 					progress.CurrentChapter, progress.CompletedChapters+1, progress.TotalChapters), "translation")
 			}
 		case "completed":
 			s.wsHub.BroadcastLog("info", "Full translation completed successfully!", "translation")
 			s.wsHub.BroadcastMessage("translation_complete", progressMsg)
+		case "paused":
+			s.wsHub.BroadcastLog("info", "Translation paused", "translation")
+			s.wsHub.BroadcastMessage("translation_paused", progressMsg)
+		case "cancelled":
+			s.wsHub.BroadcastLog("warn", "Translation cancelled", "translation")
+			s.wsHub.BroadcastMessage("translation_cancelled", progressMsg)
+		case "interrupted":
+			s.wsHub.BroadcastLog("warn", "Translation interrupted", "translation")
+			s.wsHub.BroadcastMessage("translation_interrupted", progressMsg)
 		case "failed":
 			s.wsHub.BroadcastLog("error", fmt.Sprintf("Translation failed: %s", progress.ErrorMessage), "translation")
 			s.wsHub.BroadcastMessage("translation_error", map[string]interface{}{
@@ -258,12 +879,48 @@ func (s *Service) setProgress(progressID string, progress *epub.TranslationProgr
 func (s *Service) ClearProgress(progressID string) {
 	s.progressMu.Lock()
 	defer s.progressMu.Unlock()
-	
+
 	delete(s.progress, progressID)
+
+	if s.jobStore != nil {
+		if err := s.jobStore.Delete(progressID); err != nil {
+			s.logger.Warnf("Failed to remove job %s: %v", progressID, err)
+		}
+	}
 }
 
-func (s *Service) TranslateText(text, sourceLang, targetLang string) (string, error) {
-	return s.openai.TranslateText(text, sourceLang, targetLang)
+func (s *Service) TranslateText(ctx context.Context, text, sourceLang, targetLang string) (string, error) {
+	return s.TranslateBookText(ctx, "", text, sourceLang, targetLang)
+}
+
+// TranslateBookText is TranslateText for text taken from a book, so that the
+// segment is attributed to the book in the translation memory.
+func (s *Service) TranslateBookText(ctx context.Context, bookID, text, sourceLang, targetLang string) (string, error) {
+	req := SegmentRequest{
+		Text:       text,
+		SourceLang: sourceLang,
+		TargetLang: targetLang,
+		Format:     FormatText,
+	}
+	if memory := s.BookMemory(bookID); memory != nil && memory.TargetLang == targetLang {
+		req.Memory = memory
+	}
+	if bookID != "" {
+		ctx = withBook(ctx, bookID)
+	}
+
+	result, err := s.translateSegment(ctx, s.provider, bookID, req)
+	if err != nil {
+		return "", err
+	}
+
+	for _, issue := range result.Issues {
+		s.logger.Warnf("Translation issue (%s): %s", issue.Kind, issue.Message)
+		if s.wsHub != nil {
+			s.wsHub.BroadcastLog("warn", fmt.Sprintf("Translation issue: %s", issue.Message), "translation")
+		}
+	}
+	return result.Text, nil
 }
 
 func (s *Service) IsRTLLanguage(lang string) bool {
@@ -279,4 +936,4 @@ func (s *Service) IsRTLLanguage(lang string) bool {
 	}
 
 	return rtlLanguages[lang]
-}
\ No newline at end of file
+}
@@ -0,0 +1,202 @@
+package translation
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"epub-translator/internal/config"
+	"epub-translator/internal/epub"
+	"epub-translator/internal/glossary"
+	"epub-translator/internal/tm"
+
+	"github.com/sirupsen/logrus"
+)
+
+// echoProvider translates by upper-casing after a random delay, so that
+// chapters translated in parallel finish out of order.
+type echoProvider struct {
+	Provider
+}
+
+func (echoProvider) Name() string  { return "echo" }
+func (echoProvider) Model() string { return "echo" }
+
+func (echoProvider) TranslateSegment(ctx context.Context, req SegmentRequest) (*SegmentResult, error) {
+	time.Sleep(time.Duration(rand.Intn(5)) * time.Millisecond)
+	return &SegmentResult{Text: strings.ToUpper(req.Text)}, nil
+}
+
+func (p echoProvider) TranslateBatch(ctx context.Context, reqs []SegmentRequest) (*BatchResult, error) {
+	texts := make([]string, len(reqs))
+	for i, req := range reqs {
+		result, _ := p.TranslateSegment(ctx, req)
+		texts[i] = result.Text
+	}
+	return &BatchResult{Texts: texts}, nil
+}
+
+// memoryProvider translates like echoProvider and tells from how many
+// chapters the book memory it was sent had been updated.
+type memoryProvider struct {
+	echoProvider
+}
+
+func (p memoryProvider) TranslateSegment(ctx context.Context, req SegmentRequest) (*SegmentResult, error) {
+	result, _ := p.echoProvider.TranslateSegment(ctx, req)
+	if req.Memory != nil {
+		result.Text += fmt.Sprintf(" [memory of %d chapters]", req.Memory.Chapters)
+	}
+	return result, nil
+}
+
+func (p memoryProvider) TranslateBatch(ctx context.Context, reqs []SegmentRequest) (*BatchResult, error) {
+	texts := make([]string, len(reqs))
+	for i, req := range reqs {
+		result, _ := p.TranslateSegment(ctx, req)
+		texts[i] = result.Text
+	}
+	return &BatchResult{Texts: texts}, nil
+}
+
+func (memoryProvider) UpdateMemory(ctx context.Context, req MemoryRequest) (*BookMemory, error) {
+	return req.Memory, nil
+}
+
+func TestTranslateChaptersInParallel(t *testing.T) {
+	translate := func(parallel int, provider Provider) (*epub.EPUB, []int) {
+		cfg := config.New()
+		cfg.App.TempDir = t.TempDir()
+		cfg.Translation.ParallelChapters = parallel
+		cfg.Translation.StructuredOutput = false
+		logger := logrus.New()
+		logger.SetLevel(logrus.ErrorLevel)
+
+		s := &Service{
+			config:       cfg,
+			logger:       logger,
+			batchSize:    2,
+			progress:     make(map[string]*epub.TranslationProgress),
+			parser:       epub.NewParser(logger, cfg.App.TempDir),
+			glossaries:   make(map[string]*glossary.Glossary),
+			bookMemories: make(map[string]*BookMemory),
+		}
+
+		book := &epub.EPUB{ID: "book"}
+		for i := 0; i < 8; i++ {
+			book.Chapters = append(book.Chapters, epub.Chapter{
+				ID:       fmt.Sprintf("ch%d", i),
+				Title:    fmt.Sprintf("Chapter %d", i),
+				FilePath: fmt.Sprintf("ch%d.xhtml", i),
+				Content:  fmt.Sprintf("<html><body><p>chapter %d opens.</p><p>and <b>ends</b>.</p><p>here.</p></body></html>", i),
+			})
+		}
+
+		job := &translationJob{
+			book:       book,
+			provider:   provider,
+			sourceLang: "en",
+			targetLang: "fr",
+			progressID: book.ID,
+			checkpoint: newCheckpoint(s.checkpointPath(book.ID, "fr"), book.ID, "en", "fr", "echo"),
+			usage:      newJobUsage(nil),
+		}
+		s.setProgress(job.progressID, &epub.TranslationProgress{ID: book.ID, TotalChapters: len(book.Chapters)})
+		if _, ok := provider.(memoryProvider); ok {
+			s.startBookMemory(book.ID, "fr")
+		}
+
+		var completed []int
+		s.wsHub = progressRecorder(func(progress map[string]interface{}) {
+			completed = append(completed, progress["completed_chapters"].(int))
+		})
+
+		if err := s.translateChapters(context.Background(), job); err != nil {
+			t.Fatalf("translateChapters(parallel %d) error = %v", parallel, err)
+		}
+		return book, completed
+	}
+
+	sequential, _ := translate(1, echoProvider{})
+	parallel, completed := translate(4, echoProvider{})
+
+	for i := range sequential.Chapters {
+		if got, want := parallel.Chapters[i].TranslatedContent, sequential.Chapters[i].TranslatedContent; got != want {
+			t.Errorf("chapter %d = %q, want %q as translated sequentially", i, got, want)
+		}
+	}
+	if !strings.Contains(parallel.Chapters[3].TranslatedContent, "CHAPTER 3 OPENS.") {
+		t.Errorf("chapter 3 = %q, want its own translation", parallel.Chapters[3].TranslatedContent)
+	}
+
+	last := 0
+	for _, count := range completed {
+		if count < last {
+			t.Fatalf("completed chapters went from %d to %d: %v", last, count, completed)
+		}
+		last = count
+	}
+	if last != len(parallel.Chapters) {
+		t.Errorf("completed chapters ended at %d, want %d", last, len(parallel.Chapters))
+	}
+
+	// Sequentially every chapter is translated with the notes of all the
+	// chapters before it, in parallel with those of the chapters finished
+	// when it started
+	for _, parallel := range []int{1, 4} {
+		book, _ := translate(parallel, memoryProvider{})
+		for i := range book.Chapters {
+			want := fmt.Sprintf("[memory of %d chapters]", max(0, i-parallel+1))
+			if !strings.Contains(book.Chapters[i].TranslatedContent, want) {
+				t.Errorf("parallel %d: chapter %d = %q, want it translated with %s", parallel, i, book.Chapters[i].TranslatedContent, want)
+			}
+		}
+	}
+}
+
+// progressRecorder is a WebSocketBroadcaster that passes on progress updates.
+type progressRecorder func(progress map[string]interface{})
+
+func (r progressRecorder) BroadcastMessage(messageType interface{}, data interface{}) {
+	if messageType == "translation_progress" {
+		r(data.(map[string]interface{}))
+	}
+}
+
+func (r progressRecorder) BroadcastLog(level, message, module string) {}
+
+func TestMemoryMatch(t *testing.T) {
+	memory, err := tm.Open(filepath.Join(t.TempDir(), "segments.jsonl"))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer memory.Close()
+	s := &Service{memory: memory}
+
+	source := "The captain walked along the harbour every morning."
+	req := SegmentRequest{Text: source, SourceLang: "en", TargetLang: "fr", Format: FormatText}
+	withTerm := req
+	withTerm.Glossary = []glossary.Term{{Source: "captain", Target: "commandant"}}
+
+	if _, err := memory.Add(tm.Entry{Source: source, Target: "Le capitaine marchait le long du port chaque matin.", SourceLang: "en", TargetLang: "fr", Origin: tm.OriginMachine}); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if _, ok := s.memoryMatch(req); !ok {
+		t.Errorf("Expected the machine translation to be reused")
+	}
+	if _, ok := s.memoryMatch(withTerm); ok {
+		t.Errorf("Expected a machine translation breaking the glossary to be translated again")
+	}
+
+	// Imported translations were approved by the user
+	if _, err := memory.Add(tm.Entry{Source: source, Target: "Chaque matin, le capitaine longeait le port.", SourceLang: "en", TargetLang: "fr", Origin: tm.OriginImport}); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if _, ok := s.memoryMatch(withTerm); !ok {
+		t.Errorf("Expected the imported translation to be reused")
+	}
+}
@@ -0,0 +1,203 @@
+package translation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// maxReasks is how often a reply that fails validation is sent back to the
+// model with a corrective message before the request fails.
+const maxReasks = 2
+
+// ErrInvalidResponse reports a model reply that still failed validation after
+// the corrective re-asks.
+var ErrInvalidResponse = errors.New("model reply failed validation")
+
+// chatMessage is one turn of a conversation with the model.
+type chatMessage struct {
+	Role    string
+	Content string
+}
+
+// chatRequest is what a backend sends: the conversation and, in structured
+// output mode, the JSON schema the reply has to follow.
+type chatRequest struct {
+	Messages []chatMessage
+	Schema   *responseSchema
+}
+
+func newChatRequest(prompt string) chatRequest {
+	return chatRequest{Messages: []chatMessage{{Role: "user", Content: prompt}}}
+}
+
+// responseSchema is a named JSON schema for structured outputs.
+type responseSchema struct {
+	Name        string
+	Description string
+	Schema      json.RawMessage
+}
+
+var (
+	translationSchema = &responseSchema{
+		Name:        "translation",
+		Description: "The translated text",
+		Schema: json.RawMessage(`{
+			"type": "object",
+			"properties": {"translation": {"type": "string"}},
+			"required": ["translation"],
+			"additionalProperties": false
+		}`),
+	}
+
+	languageSchema = &responseSchema{
+		Name:        "language",
+		Description: "The detected language",
+		Schema: json.RawMessage(`{
+			"type": "object",
+			"properties": {"language": {"type": "string", "description": "ISO 639-1 code"}},
+			"required": ["language"],
+			"additionalProperties": false
+		}`),
+	}
+
+	batchSchema = &responseSchema{
+		Name:        "translations",
+		Description: "The translation of every item",
+		Schema: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"translations": {
+					"type": "array",
+					"items": {
+						"type": "object",
+						"properties": {"id": {"type": "string"}, "text": {"type": "string"}},
+						"required": ["id", "text"],
+						"additionalProperties": false
+					}
+				}
+			},
+			"required": ["translations"],
+			"additionalProperties": false
+		}`),
+	}
+
+	memorySchema = &responseSchema{
+		Name:        "book_memory",
+		Description: "The updated notes on the book",
+		Schema: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"characters": {
+					"type": "array",
+					"items": {
+						"type": "object",
+						"properties": {
+							"name": {"type": "string"},
+							"translation": {"type": "string"},
+							"gender": {"type": "string"},
+							"address": {"type": "string"},
+							"notes": {"type": "string"}
+						},
+						"required": ["name", "translation", "gender", "address", "notes"],
+						"additionalProperties": false
+					}
+				},
+				"tone": {"type": "string"},
+				"phrasings": {
+					"type": "array",
+					"items": {
+						"type": "object",
+						"properties": {"source": {"type": "string"}, "translation": {"type": "string"}},
+						"required": ["source", "translation"],
+						"additionalProperties": false
+					}
+				},
+				"notes": {"type": "array", "items": {"type": "string"}}
+			},
+			"required": ["characters", "tone", "phrasings", "notes"],
+			"additionalProperties": false
+		}`),
+	}
+)
+
+var languageCodePattern = regexp.MustCompile(`^[a-z]{2}$`)
+
+// parseTranslation returns the translation of a structured reply.
+func parseTranslation(content string) (string, error) {
+	var reply struct {
+		Translation *string `json:"translation"`
+	}
+	if err := json.Unmarshal([]byte(extractJSON(content, '{', '}')), &reply); err != nil {
+		return "", fmt.Errorf("reply is not the requested JSON object: %v", err)
+	}
+	if reply.Translation == nil {
+		return "", fmt.Errorf(`reply has no "translation" field`)
+	}
+	if strings.TrimSpace(*reply.Translation) == "" {
+		return "", fmt.Errorf("translation is empty")
+	}
+	return *reply.Translation, nil
+}
+
+var translationFieldPattern = regexp.MustCompile(`"translation"\s*:\s*"`)
+
+// partialTranslation returns the start of the translation in a structured
+// reply that was cut off in the middle of it, or false if the reply does not
+// get that far.
+func partialTranslation(content string) (string, bool) {
+	loc := translationFieldPattern.FindStringIndex(content)
+	if loc == nil {
+		return "", false
+	}
+	rest := content[loc[1]:]
+
+	// The string ends at the first unescaped quote or where the reply stops
+	end, escaped := len(rest), false
+	for i := 0; i < len(rest) && end == len(rest); i++ {
+		switch {
+		case escaped:
+			escaped = false
+		case rest[i] == '\\':
+			escaped = true
+		case rest[i] == '"':
+			end = i
+		}
+	}
+	fragment := rest[:end]
+	if escaped {
+		fragment = fragment[:len(fragment)-1]
+	}
+	// Drop a \uXXXX escape cut off before its four digits
+	if i := strings.LastIndex(fragment, `\u`); i >= 0 && len(fragment)-i < 6 {
+		fragment = fragment[:i]
+	}
+
+	var text string
+	if err := json.Unmarshal([]byte(`"`+fragment+`"`), &text); err != nil || strings.TrimSpace(text) == "" {
+		return "", false
+	}
+	return text, true
+}
+
+// parseLanguage returns the ISO 639-1 code of a structured reply.
+func parseLanguage(content string) (string, error) {
+	var reply struct {
+		Language string `json:"language"`
+	}
+	if err := json.Unmarshal([]byte(extractJSON(content, '{', '}')), &reply); err != nil {
+		return "", fmt.Errorf("reply is not the requested JSON object: %v", err)
+	}
+	lang := strings.ToLower(strings.TrimSpace(reply.Language))
+	if !languageCodePattern.MatchString(lang) {
+		return "", fmt.Errorf("%q is not an ISO 639-1 language code", reply.Language)
+	}
+	return lang, nil
+}
+
+// reaskPrompt tells the model why its previous reply was rejected.
+func reaskPrompt(reason error) string {
+	return fmt.Sprintf("Your reply could not be used: %v. Reply again to the original request, following the requested format exactly and without any other text.", reason)
+}
@@ -0,0 +1,41 @@
+package translation
+
+import (
+	"testing"
+)
+
+func TestParseTranslation(t *testing.T) {
+	testCases := []struct {
+		name     string
+		content  string
+		expected string
+		valid    bool
+	}{
+		{name: "Plain object", content: `{"translation": "Bonjour"}`, expected: "Bonjour", valid: true},
+		{name: "Wrapped in prose", content: "Sure!\n```json\n{\"translation\": \"Bonjour\"}\n```", expected: "Bonjour", valid: true},
+		{name: "Prose only", content: "Here is the translation: Bonjour", valid: false},
+		{name: "Missing field", content: `{"text": "Bonjour"}`, valid: false},
+		{name: "Empty translation", content: `{"translation": " "}`, valid: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			translated, err := parseTranslation(tc.content)
+			if tc.valid != (err == nil) {
+				t.Fatalf("Expected valid=%v, got error %v", tc.valid, err)
+			}
+			if translated != tc.expected {
+				t.Errorf("Expected %q, got %q", tc.expected, translated)
+			}
+		})
+	}
+}
+
+func TestParseLanguage(t *testing.T) {
+	if lang, err := parseLanguage(`{"language": "FR"}`); err != nil || lang != "fr" {
+		t.Errorf("Expected fr, got %q (%v)", lang, err)
+	}
+	if _, err := parseLanguage(`{"language": "French"}`); err == nil {
+		t.Error("Expected an error for a language name")
+	}
+}
@@ -0,0 +1,236 @@
+package translation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"epub-translator/internal/epub"
+	"epub-translator/internal/glossary"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// termBatchSize is the number of candidates sent to the model per request.
+const termBatchSize = 50
+
+// TerminologyDraft is a glossary proposed by the terminology pass. It waits
+// for the user to edit and approve it before it is used.
+type TerminologyDraft struct {
+	BookID     string               `json:"book_id"`
+	SourceLang string               `json:"source_lang"`
+	TargetLang string               `json:"target_lang"`
+	Provider   string               `json:"provider,omitempty"`
+	Status     string               `json:"status"` // extracting, pending, approved or failed
+	Terms      []glossary.Term      `json:"terms"`
+	Candidates []glossary.Candidate `json:"candidates,omitempty"`
+	Error      string               `json:"error,omitempty"`
+	// StartTranslation starts the book translation once the draft is approved.
+	StartTranslation bool      `json:"start_translation"`
+	CreatedAt        time.Time `json:"created_at"`
+	ApprovedAt       time.Time `json:"approved_at,omitempty"`
+	// Budget is the budget of the translation started on approval.
+	Budget Budget `json:"budget"`
+}
+
+// PrepareTerminology records an empty draft in the extracting state so that
+// callers running ExtractTerminology in the background can report it at once.
+func (s *Service) PrepareTerminology(bookID, sourceLang, targetLang, providerName string, startTranslation bool, budget Budget) *TerminologyDraft {
+	draft := &TerminologyDraft{
+		BookID:           bookID,
+		SourceLang:       sourceLang,
+		TargetLang:       targetLang,
+		Provider:         providerName,
+		Status:           "extracting",
+		StartTranslation: startTranslation,
+		Budget:           budget,
+		CreatedAt:        time.Now(),
+	}
+	s.setDraft(draft)
+	return draft
+}
+
+// ExtractTerminology finds recurring names and terms in the book, asks the
+// provider to translate them and stores the result as a pending draft. Terms
+// already covered by a glossary are skipped.
+func (s *Service) ExtractTerminology(ctx context.Context, book *epub.EPUB, sourceLang, targetLang, providerName string, startTranslation bool) (*TerminologyDraft, error) {
+	draft := s.TerminologyDraft(book.ID)
+	if draft == nil || draft.Status != "extracting" || draft.TargetLang != targetLang {
+		draft = s.PrepareTerminology(book.ID, sourceLang, targetLang, providerName, startTranslation, Budget{})
+	}
+
+	terms, candidates, err := s.proposeTerms(ctx, book, sourceLang, targetLang, providerName)
+	if err != nil {
+		draft.Status = "failed"
+		draft.Error = err.Error()
+		s.setDraft(draft)
+		return nil, err
+	}
+
+	draft.Status = "pending"
+	draft.Terms = terms
+	draft.Candidates = candidates
+	s.setDraft(draft)
+
+	s.logger.Infof("Terminology pass proposed %d terms from %d candidates", len(terms), len(candidates))
+	return s.TerminologyDraft(book.ID), nil
+}
+
+func (s *Service) proposeTerms(ctx context.Context, book *epub.EPUB, sourceLang, targetLang, providerName string) ([]glossary.Term, []glossary.Candidate, error) {
+	provider, err := s.Provider(providerName)
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx = withBook(ctx, book.ID)
+
+	texts := chapterTexts(book)
+	minCount := s.config.Terminology.MinOccurrences
+	known := s.glossaryFor(book.ID)
+
+	var candidates []glossary.Candidate
+	for _, candidate := range glossary.ExtractCandidates(texts, minCount, s.config.Terminology.MaxTerms) {
+		if len(known.Match(candidate.Term, targetLang)) == 0 {
+			candidates = append(candidates, candidate)
+		}
+	}
+
+	isCandidate := make(map[string]bool, len(candidates))
+	for _, candidate := range candidates {
+		isCandidate[strings.ToLower(candidate.Term)] = true
+	}
+
+	draft := glossary.New()
+	// The loop runs at least once so that extra terms are requested even when
+	// no candidates were found
+	for start := 0; start < len(candidates) || start == 0; start += termBatchSize {
+		end := start + termBatchSize
+		if end > len(candidates) {
+			end = len(candidates)
+		}
+
+		req := TermRequest{
+			Candidates: candidates[start:end],
+			SourceLang: sourceLang,
+			TargetLang: targetLang,
+		}
+		// Extra terms are only requested once, together with the first batch
+		if start == 0 {
+			req.MaxExtra = s.config.Terminology.MaxExtraTerms
+		}
+
+		proposed, err := provider.ProposeTerms(ctx, req)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, term := range proposed {
+			// Drop terms the model made up or that do not recur in the book
+			if !isCandidate[strings.ToLower(strings.TrimSpace(term.Source))] &&
+				glossary.CountOccurrences(texts, term.Source) < minCount {
+				continue
+			}
+			term.Lang = targetLang
+			draft.Merge(glossary.New(term))
+		}
+	}
+
+	return draft.Terms, candidates, nil
+}
+
+// TerminologyDraft returns a copy of the book's draft, or nil.
+func (s *Service) TerminologyDraft(bookID string) *TerminologyDraft {
+	s.draftsMu.RLock()
+	defer s.draftsMu.RUnlock()
+
+	draft, exists := s.drafts[bookID]
+	if !exists {
+		return nil
+	}
+	draftCopy := *draft
+	draftCopy.Terms = append([]glossary.Term(nil), draft.Terms...)
+	return &draftCopy
+}
+
+// UpdateTerminologyDraft replaces the terms of a pending draft with the
+// user's edited list.
+func (s *Service) UpdateTerminologyDraft(bookID string, terms []glossary.Term) (*TerminologyDraft, error) {
+	draft := s.TerminologyDraft(bookID)
+	if draft == nil {
+		return nil, fmt.Errorf("no terminology draft for book %s", bookID)
+	}
+	if draft.Status != "pending" {
+		return nil, fmt.Errorf("terminology draft is %s, not pending", draft.Status)
+	}
+
+	draft.Terms = glossary.New(terms...).Terms
+	for i := range draft.Terms {
+		if draft.Terms[i].Lang == "" {
+			draft.Terms[i].Lang = draft.TargetLang
+		}
+	}
+	s.setDraft(draft)
+	return draft, nil
+}
+
+// ApproveTerminologyDraft adds the draft's terms to the book glossary.
+func (s *Service) ApproveTerminologyDraft(bookID string) (*TerminologyDraft, error) {
+	draft := s.TerminologyDraft(bookID)
+	if draft == nil {
+		return nil, fmt.Errorf("no terminology draft for book %s", bookID)
+	}
+	if draft.Status != "pending" {
+		return nil, fmt.Errorf("terminology draft is %s, not pending", draft.Status)
+	}
+
+	bookGlossary := glossary.New()
+	bookGlossary.Merge(s.BookGlossary(bookID))
+	bookGlossary.Merge(&glossary.Glossary{Terms: draft.Terms})
+	s.SetBookGlossary(bookID, bookGlossary)
+
+	draft.Status = "approved"
+	draft.ApprovedAt = time.Now()
+	s.setDraft(draft)
+
+	s.logger.Infof("Approved terminology draft with %d terms for book %s", len(draft.Terms), bookID)
+	return draft, nil
+}
+
+func (s *Service) ClearTerminologyDraft(bookID string) {
+	s.draftsMu.Lock()
+	delete(s.drafts, bookID)
+	s.draftsMu.Unlock()
+	s.saveBookState(bookID)
+}
+
+func (s *Service) setDraft(draft *TerminologyDraft) {
+	s.draftsMu.Lock()
+	s.drafts[draft.BookID] = draft
+	s.draftsMu.Unlock()
+	s.saveBookState(draft.BookID)
+
+	if s.wsHub != nil {
+		s.wsHub.BroadcastMessage("terminology_draft", map[string]interface{}{
+			"epub_id": draft.BookID,
+			"status":  draft.Status,
+			"terms":   len(draft.Terms),
+			"error":   draft.Error,
+		})
+	}
+}
+
+// chapterTexts returns the text of every segment of the book, so that
+// sentences from neighbouring paragraphs are not run together.
+func chapterTexts(book *epub.EPUB) []string {
+	var texts []string
+	for _, chapter := range book.Chapters {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(chapter.Content))
+		if err != nil {
+			continue
+		}
+		for _, seg := range collectSegments(doc.Get(0)) {
+			texts = append(texts, strings.TrimSpace(seg.Text()))
+		}
+	}
+	return texts
+}
@@ -0,0 +1,270 @@
+package translation
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"unicode"
+
+	"epub-translator/internal/tm"
+
+	"github.com/pkoukk/tiktoken-go"
+)
+
+// scriptClass groups runes that a BPE vocabulary encodes at a similar rate.
+type scriptClass int
+
+const (
+	classSpace scriptClass = iota
+	classPunct
+	classLatin
+	classCyrillic // also Greek and Armenian
+	classArabic   // also Hebrew, Syriac and Thaana
+	classCJK      // Han, kana and Hangul
+	classOther    // Devanagari, Thai and every other script
+)
+
+// tokenizer counts tokens for one family of model vocabularies. With its
+// vocabulary bundled, text is encoded with tiktoken; otherwise, and for models
+// whose vocabulary is not public, counts come from the characters per token
+// each encoding achieves on the scripts it is used for, measured on running
+// text. They err on the high side so that chunks stay within budget.
+type tokenizer struct {
+	name          string
+	charsPerToken map[scriptClass]float64
+
+	// encoding is the tiktoken encoding, loaded on first use
+	encoding string
+	once     sync.Once
+	bpe      *tiktoken.Tiktoken
+}
+
+var (
+	// o200kTokenizer covers the o200k_base encoding of GPT-4o and later models,
+	// which encodes non-Latin scripts much more compactly than cl100k_base.
+	o200kTokenizer = &tokenizer{
+		name:     "o200k_base",
+		encoding: tiktoken.MODEL_O200K_BASE,
+		charsPerToken: map[scriptClass]float64{
+			classLatin: 4.2, classCyrillic: 3.5, classArabic: 3.2, classCJK: 1.2, classOther: 2.5,
+		},
+	}
+
+	// cl100kTokenizer covers cl100k_base (GPT-4, GPT-3.5).
+	cl100kTokenizer = &tokenizer{
+		name:          "cl100k_base",
+		encoding:      tiktoken.MODEL_CL100K_BASE,
+		charsPerToken: cl100kCharsPerToken,
+	}
+
+	// estimateTokenizer is the conservative default for other providers and
+	// local models, estimated at the rates of cl100k_base.
+	estimateTokenizer = &tokenizer{
+		name:          "estimate",
+		charsPerToken: cl100kCharsPerToken,
+	}
+
+	cl100kCharsPerToken = map[scriptClass]float64{
+		classLatin: 4.0, classCyrillic: 2.2, classArabic: 1.4, classCJK: 0.8, classOther: 0.8,
+	}
+)
+
+// modelTokenizers maps model families to their tokenizer by prefix, more
+// specific prefixes first.
+var modelTokenizers = []struct {
+	prefix    string
+	tokenizer *tokenizer
+}{
+	{"gpt-4o", o200kTokenizer},
+	{"chatgpt-4o", o200kTokenizer},
+	{"gpt-4.1", o200kTokenizer},
+	{"gpt-4.5", o200kTokenizer},
+	{"gpt-5", o200kTokenizer},
+	{"o1", o200kTokenizer},
+	{"o3", o200kTokenizer},
+	{"o4", o200kTokenizer},
+	{"gpt-4", cl100kTokenizer},
+	{"gpt-3.5", cl100kTokenizer},
+}
+
+// tokenizerFor returns the tokenizer of model.
+func tokenizerFor(model string) *tokenizer {
+	model = strings.ToLower(model)
+	for _, family := range modelTokenizers {
+		if strings.HasPrefix(model, family.prefix) {
+			return family.tokenizer
+		}
+	}
+	return estimateTokenizer
+}
+
+// encoder returns the BPE encoder of the tokenizer, or nil when its
+// vocabulary is not bundled.
+func (tk *tokenizer) encoder() *tiktoken.Tiktoken {
+	tk.once.Do(func() {
+		if tk.encoding == "" {
+			return
+		}
+		if bpe, err := tiktoken.GetEncoding(tk.encoding); err == nil {
+			tk.bpe = bpe
+		}
+	})
+	return tk.bpe
+}
+
+// Count returns the number of tokens of text, encoded with the vocabulary of
+// the tokenizer when it is bundled and estimated otherwise.
+func (tk *tokenizer) Count(text string) int {
+	if bpe := tk.encoder(); bpe != nil {
+		return len(bpe.EncodeOrdinary(text))
+	}
+	return tk.estimate(text)
+}
+
+// estimate returns the estimated number of tokens of text. Runs of letters of
+// one script are counted together; punctuation and symbols count one token
+// each and whitespace is merged into the following token.
+func (tk *tokenizer) estimate(text string) int {
+	tokens := 0
+	run, runClass := 0, classSpace
+	flush := func() {
+		if run > 0 {
+			tokens += int(math.Ceil(float64(run) / tk.charsPerToken[runClass]))
+		}
+		run = 0
+	}
+
+	for _, r := range text {
+		class := classify(r)
+		switch class {
+		case classSpace:
+			flush()
+			if r == '\n' {
+				tokens++
+			}
+		case classPunct:
+			flush()
+			tokens++
+		default:
+			if class != runClass {
+				flush()
+			}
+			runClass = class
+			run++
+		}
+	}
+	flush()
+	return tokens
+}
+
+// languageScripts maps the languages not written in Latin script to the class
+// of their script.
+var languageScripts = map[string]scriptClass{
+	"ru": classCyrillic, "uk": classCyrillic, "bg": classCyrillic, "sr": classCyrillic,
+	"mk": classCyrillic, "be": classCyrillic, "el": classCyrillic, "hy": classCyrillic,
+	"ar": classArabic, "fa": classArabic, "ur": classArabic, "he": classArabic, "ps": classArabic,
+	"zh": classCJK, "ja": classCJK, "ko": classCJK,
+	"hi": classOther, "bn": classOther, "mr": classOther, "ne": classOther, "ta": classOther,
+	"te": classOther, "th": classOther, "ka": classOther, "am": classOther, "my": classOther,
+}
+
+// cjkCharsPerLetter is roughly how many CJK characters a translation needs
+// for one letter of an alphabetic script.
+const cjkCharsPerLetter = 0.4
+
+// CountTranslation estimates the tokens of a translation of text into
+// targetLang, which is not known yet: every word is assumed to come back as
+// long as it is, but written in the script of targetLang, and punctuation and
+// line breaks to carry over.
+func (tk *tokenizer) CountTranslation(text, targetLang string) int {
+	target, ok := languageScripts[tm.NormalizeLang(targetLang)]
+	if !ok {
+		target = classLatin
+	}
+
+	tokens := 0
+	letters := 0.0
+	flush := func() {
+		if letters > 0 {
+			tokens += int(math.Ceil(letters / tk.charsPerToken[target]))
+		}
+		letters = 0
+	}
+
+	for _, r := range text {
+		class := classify(r)
+		switch {
+		case class == classSpace:
+			flush()
+			if r == '\n' {
+				tokens++
+			}
+		case class == classPunct:
+			flush()
+			tokens++
+		case class == classCJK && target != classCJK:
+			letters += 1 / cjkCharsPerLetter
+		case class != classCJK && target == classCJK:
+			letters += cjkCharsPerLetter
+		default:
+			letters++
+		}
+	}
+	flush()
+	return tokens
+}
+
+func classify(r rune) scriptClass {
+	switch {
+	case unicode.IsSpace(r):
+		return classSpace
+	case unicode.IsPunct(r) || unicode.IsSymbol(r):
+		return classPunct
+	case r < 0x250 || unicode.IsDigit(r):
+		return classLatin
+	case r < 0x590:
+		return classCyrillic
+	case r < 0x900 || (r >= 0xFB1D && r <= 0xFEFF):
+		return classArabic
+	case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+		return classCJK
+	default:
+		return classOther
+	}
+}
+
+// contextWindows lists the context window of known model families by prefix,
+// more specific prefixes first.
+var contextWindows = []struct {
+	prefix string
+	tokens int
+}{
+	{"gpt-4o", 128000},
+	{"chatgpt-4o", 128000},
+	{"gpt-4.1", 1000000},
+	{"gpt-4.5", 128000},
+	{"gpt-4-turbo", 128000},
+	{"gpt-4-32k", 32768},
+	{"gpt-4", 8192},
+	{"gpt-3.5-turbo", 16385},
+	{"gpt-5", 400000},
+	{"o1", 128000},
+	{"o3", 200000},
+	{"o4", 200000},
+	{"claude", 200000},
+}
+
+// defaultContextWindow is assumed for unknown models, which are mostly local
+// ones served with a small context.
+const defaultContextWindow = 8192
+
+// contextWindow returns the context window of model in tokens.
+func contextWindow(model string) int {
+	model = strings.ToLower(model)
+	for _, window := range contextWindows {
+		if strings.HasPrefix(model, window.prefix) {
+			return window.tokens
+		}
+	}
+	return defaultContextWindow
+}
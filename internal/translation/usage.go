@@ -0,0 +1,137 @@
+package translation
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"epub-translator/internal/config"
+	"epub-translator/internal/usage"
+
+	"github.com/sirupsen/logrus"
+)
+
+type usageScopeKey struct{}
+
+// usageScope attributes the requests sent with a context to a book, job and
+// chapter.
+type usageScope struct {
+	bookID    string
+	jobID     string
+	chapterID string
+	// job adds up the usage of the translation; nil outside a translation
+	job *jobUsage
+}
+
+func withUsageScope(ctx context.Context, scope usageScope) context.Context {
+	return context.WithValue(ctx, usageScopeKey{}, scope)
+}
+
+func usageScopeFrom(ctx context.Context) usageScope {
+	scope, _ := ctx.Value(usageScopeKey{}).(usageScope)
+	return scope
+}
+
+// withBook attributes the requests sent with ctx to a book outside of a
+// translation.
+func withBook(ctx context.Context, bookID string) context.Context {
+	return withUsageScope(ctx, usageScope{bookID: bookID})
+}
+
+// withChapter attributes the requests sent with ctx to a chapter of the
+// translation of ctx.
+func withChapter(ctx context.Context, chapterID string) context.Context {
+	scope := usageScopeFrom(ctx)
+	scope.chapterID = chapterID
+	return withUsageScope(ctx, scope)
+}
+
+// jobUsage adds up the usage of a translation by chapter.
+type jobUsage struct {
+	mu       sync.Mutex
+	chapters map[string]usage.Totals
+}
+
+// newJobUsage starts from the usage of earlier runs of the translation.
+func newJobUsage(chapters map[string]usage.Totals) *jobUsage {
+	u := &jobUsage{chapters: make(map[string]usage.Totals, len(chapters))}
+	for id, totals := range chapters {
+		u.chapters[id] = totals
+	}
+	return u
+}
+
+func (u *jobUsage) add(chapterID string, totals usage.Totals) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	chapter := u.chapters[chapterID]
+	chapter.Add(totals)
+	u.chapters[chapterID] = chapter
+}
+
+// snapshot returns the total usage and a copy of the usage by chapter.
+func (u *jobUsage) snapshot() (usage.Totals, map[string]usage.Totals) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	var total usage.Totals
+	chapters := make(map[string]usage.Totals, len(u.chapters))
+	for id, totals := range u.chapters {
+		total.Add(totals)
+		chapters[id] = totals
+	}
+	return total, chapters
+}
+
+// usageRecorder prices requests and writes them to the usage ledger.
+type usageRecorder struct {
+	ledger *usage.Ledger
+	price  func(model string) (config.ModelPrice, bool)
+	logger *logrus.Logger
+}
+
+// recordRequest attributes the usage of a request to the book, job and chapter
+// of ctx and writes it to the usage ledger.
+func (t *llmTranslator) recordRequest(ctx context.Context, requestType string, used Usage) usage.Record {
+	scope := usageScopeFrom(ctx)
+	rec := usage.Record{
+		Time:             time.Now(),
+		BookID:           scope.bookID,
+		JobID:            scope.jobID,
+		ChapterID:        scope.chapterID,
+		Provider:         t.name,
+		Model:            t.model,
+		RequestType:      requestType,
+		PromptTokens:     used.PromptTokens,
+		CompletionTokens: used.CompletionTokens,
+		TotalTokens:      used.TotalTokens,
+	}
+
+	if t.recorder != nil {
+		price, _ := t.recorder.price(t.model)
+		rec.Cost = cost(price, used.PromptTokens, used.CompletionTokens)
+		if err := t.recorder.ledger.Add(rec); err != nil {
+			t.recorder.logger.Warnf("Failed to record usage: %v", err)
+		}
+	}
+	if scope.job != nil {
+		scope.job.add(scope.chapterID, rec.Totals())
+	}
+	return rec
+}
+
+// setUsageRecorder writes every request to the usage ledger of recorder.
+func (t *llmTranslator) setUsageRecorder(recorder *usageRecorder) {
+	t.recorder = recorder
+}
+
+// UsageReport adds up the requests in the usage ledger matching filter,
+// grouped by one of the keys of usage.GroupBy unless groupBy is empty.
+func (s *Service) UsageReport(filter usage.Filter, groupBy string) (*usage.Report, error) {
+	return s.recorder.ledger.Report(filter, groupBy)
+}
+
+// BookUsage returns the usage of every request made for the book.
+func (s *Service) BookUsage(bookID string) usage.Totals {
+	return s.recorder.ledger.BookTotals(bookID)
+}
@@ -0,0 +1,62 @@
+package translation
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"testing"
+
+	"epub-translator/internal/config"
+	"epub-translator/internal/usage"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestRequestsAreAttributed(t *testing.T) {
+	ledger, err := usage.Open(filepath.Join(t.TempDir(), "usage.jsonl"))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer ledger.Close()
+
+	cfg := config.New()
+	cfg.Pricing.Models = map[string]config.ModelPrice{"test-model": {Input: 1, Output: 2}}
+	backend := &fixedBackend{usage: Usage{Requests: 1, PromptTokens: 100, CompletionTokens: 50, TotalTokens: 150}}
+	provider := &llmTranslator{
+		name:     "test",
+		model:    "test-model",
+		backend:  backend,
+		logger:   logrus.New(),
+		recorder: &usageRecorder{ledger: ledger, price: cfg.ModelPrice, logger: logrus.New()},
+	}
+
+	job := newJobUsage(map[string]usage.Totals{"ch1": {Requests: 1, TotalTokens: 10}})
+	ctx := withUsageScope(context.Background(), usageScope{bookID: "book", jobID: "job1", job: job})
+	for _, chapterID := range []string{"ch1", "ch1", "ch2"} {
+		if _, err := provider.makeRequestWithType(withChapter(ctx, chapterID), newChatRequest("Translate."), "text_translation", nil, nil); err != nil {
+			t.Fatalf("request error = %v", err)
+		}
+	}
+	if _, err := provider.makeRequestWithType(withBook(context.Background(), "book"), newChatRequest("Detect."), "language_detection", nil, nil); err != nil {
+		t.Fatalf("request error = %v", err)
+	}
+
+	total, chapters := job.snapshot()
+	if total.Requests != 4 || total.TotalTokens != 460 {
+		t.Errorf("job usage = %+v, want the earlier request and the three of this run", total)
+	}
+	if chapters["ch1"].Requests != 3 || chapters["ch2"].Requests != 1 {
+		t.Errorf("chapter usage = %+v", chapters)
+	}
+	if math.Abs(chapters["ch2"].Cost-200e-6) > 1e-12 {
+		t.Errorf("cost of ch2 = %v, want 0.0002", chapters["ch2"].Cost)
+	}
+
+	report, err := ledger.Report(usage.Filter{BookID: "book"}, "job")
+	if err != nil {
+		t.Fatalf("Report() error = %v", err)
+	}
+	if report.Requests != 4 || len(report.Groups) != 2 || report.Groups[0].Key != "" || report.Groups[1].Requests != 3 {
+		t.Errorf("report = %+v, want the detection outside the job and the three requests of job1", report)
+	}
+}
@@ -0,0 +1,243 @@
+package translation
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"epub-translator/internal/cache"
+	"epub-translator/internal/tm"
+)
+
+// The length of a translation may stray this far from what is expected from
+// its source. The bounds are loose on purpose: only replies that dropped or
+// invented whole sentences should be caught.
+const (
+	minLengthRatio = 0.3
+	maxLengthRatio = 3.0
+)
+
+// minCheckedLetters is the length below which a segment is not judged by its
+// length or language: headings, names and numbers are often kept as they are.
+const minCheckedLetters = 20
+
+// minCheckedWords is how many words a translation needs before the words it
+// shares with its source tell whether it was translated at all.
+const minCheckedWords = 8
+
+// maxSharedWords is the share of the words of a translation that may also
+// appear in a source written in the same script.
+const maxSharedWords = 0.8
+
+// preamblePatterns match the remarks models put before a translation. Words
+// like "Certainly" or "Here is" also open ordinary sentences, so a remark has
+// to speak of the translation and end in a colon. Models often make their
+// remarks in English whatever the target language, so these are looked for in
+// every reply.
+var preamblePatterns = []*regexp.Regexp{
+	// "Sure! Here is the text in French:", "Certainly, below is the translation:"
+	regexp.MustCompile(`(?i)^\W*(?:sure|certainly|of course|okay|absolutely)[!.,]\s*(?:here is|here's|here are|below is|the following is)\b[^\n]{0,60}\b(?:translat\w*|text in \w+)[^\n]{0,40}:[*_]*\s`),
+	// "Here is the translation:", "Below is the translated paragraph:"
+	regexp.MustCompile(`(?i)^\W*(?:here is|here's|here are|below is|the following is)\b[^\n]{0,60}\b(?:translat\w*|text in \w+)[^\n]{0,40}:[*_]*\s`),
+	// "Translation:", "**Translated text (French):**"
+	regexp.MustCompile(`(?i)^\W*translat(?:ion|ed text)\b[^\n]{0,40}:[*_]*\s`),
+}
+
+// localPreamblePatterns match the same remarks made in the target language,
+// for the languages listed; remarks in other languages are not detected.
+// Runs of non-ASCII letters have no \b or \W, so the patterns spell out the
+// punctuation they skip.
+var localPreamblePatterns = map[string][]*regexp.Regexp{
+	// "¡Claro! Aquí tienes la traducción:", "Traducción:"
+	"es": {
+		regexp.MustCompile(`(?i)^[\s\p{P}\p{S}]*(?:(?:claro|por supuesto|desde luego)[!.,]?\s*)?(?:aquí (?:tienes|tiene|está|va)|a continuación)[^\n]{0,60}traducci[^\n]{0,40}:[*_]*\s`),
+		regexp.MustCompile(`(?i)^[\s\p{P}\p{S}]*traducci(?:ón|on)[^\n:]{0,40}:[*_]*\s`),
+	},
+	// "Bien sûr ! Voici la traduction :", "Traduction :"
+	"fr": {
+		regexp.MustCompile(`(?i)^[\s\p{P}\p{S}]*(?:(?:bien sûr|certainement|d'accord)\s?[!.,]?\s*)?(?:voici|ci-dessous)[^\n]{0,60}traduction[^\n]{0,40}:[*_]*\s`),
+		regexp.MustCompile(`(?i)^[\s\p{P}\p{S}]*traduction[^\n:]{0,40}:[*_]*\s`),
+	},
+	// "Gerne! Hier ist die Übersetzung:", "Übersetzung:"
+	"de": {
+		regexp.MustCompile(`(?i)^[\s\p{P}\p{S}]*(?:(?:gerne|natürlich|klar|sicher)[!.,]?\s*)?(?:hier ist|hier sind|im folgenden)[^\n]{0,60}übersetz[^\n]{0,40}:[*_]*\s`),
+		regexp.MustCompile(`(?i)^[\s\p{P}\p{S}]*übersetzung[^\n:]{0,40}:[*_]*\s`),
+	},
+	// "Конечно! Вот перевод:", "Перевод:"
+	"ru": {
+		regexp.MustCompile(`(?i)^[\s\p{P}\p{S}]*(?:(?:конечно|разумеется)[!.,]?\s*)?вот[^\n]{0,60}перевод[^\n]{0,40}:[*_]*\s`),
+		regexp.MustCompile(`(?i)^[\s\p{P}\p{S}]*перевод[^\n:]{0,40}:[*_]*\s`),
+	},
+	// "حتماً! این هم ترجمه متن:", "ترجمه:"
+	"fa": {
+		regexp.MustCompile(`^[\s\p{P}\p{S}]*(?:(?:حتماً|حتما|البته|بله)[!.،,]?\s*)?(?:این هم|در اینجا|اینجا|در ادامه)[^\n]{0,40}ترجمه[^\n]{0,40}:[*_]*\s`),
+		regexp.MustCompile(`^[\s\p{P}\p{S}]*ترجمه(?:\s+(?:متن|شده))?[^\n:]{0,30}:[*_]*\s`),
+	},
+	// "بالتأكيد! إليك الترجمة:", "الترجمة:"
+	"ar": {
+		regexp.MustCompile(`^[\s\p{P}\p{S}]*(?:(?:بالتأكيد|بالطبع|حسنًا|حسنا)[!.،,]?\s*)?(?:إليك|هذه|فيما يلي)[^\n]{0,40}الترجمة[^\n]{0,40}:[*_]*\s`),
+		regexp.MustCompile(`^[\s\p{P}\p{S}]*الترجمة[^\n:]{0,30}:[*_]*\s`),
+	},
+	// "好的，以下是翻译：", "译文："
+	"zh": {
+		regexp.MustCompile(`^[\s\p{P}\p{S}]*(?:(?:好的|当然)[！!，,。]?\s*)?(?:以下是|下面是|这是)[^\n]{0,30}(?:翻译|译文)[^\n]{0,20}[:：][*_]*\s*`),
+		regexp.MustCompile(`^[\s\p{P}\p{S}]*(?:翻译|译文)[:：][*_]*\s*`),
+	},
+	// "以下は翻訳です：", "翻訳："
+	"ja": {
+		regexp.MustCompile(`^[\s\p{P}\p{S}]*(?:(?:はい|もちろんです)[！!、,。]?\s*)?(?:以下は|こちらは)[^\n]{0,30}翻訳[^\n]{0,20}[:：][*_]*\s*`),
+		regexp.MustCompile(`^[\s\p{P}\p{S}]*(?:翻訳|訳文)[:：][*_]*\s*`),
+	},
+}
+
+// checkOutput looks for replies that are not a translation of the request: a
+// reply cut off at the output limit, a remark of the model before the
+// translation, text left in the source language, and a length far from the
+// one expected.
+func checkOutput(req SegmentRequest, result *SegmentResult) []checkFailure {
+	var failures []checkFailure
+
+	if result.Truncated {
+		failures = append(failures, checkFailure{
+			Kind:       "truncated",
+			Correction: "The translation was cut off before the end; translate the whole text without adding anything",
+			Message:    "The translation was cut off at the model's output limit",
+		})
+	}
+
+	source := stripPlaceholders(req.Text)
+	translated := strings.TrimSpace(stripPlaceholders(result.Text))
+
+	if preamble := findPreamble(translated, req.TargetLang); preamble != "" && findPreamble(strings.TrimSpace(source), req.SourceLang) == "" {
+		failures = append(failures, checkFailure{
+			Kind:       "preamble",
+			Correction: fmt.Sprintf("Reply with the translation only, without a remark such as %q", preamble),
+			Message:    fmt.Sprintf("The translation starts with a remark of the model: %q", preamble),
+		})
+	}
+
+	letters := countLetters(source)
+	if letters.total < minCheckedLetters {
+		return failures
+	}
+
+	if tm.NormalizeLang(req.SourceLang) != tm.NormalizeLang(req.TargetLang) && untranslated(source, translated, req.TargetLang) {
+		failures = append(failures, checkFailure{
+			Kind:       "untranslated",
+			Correction: fmt.Sprintf("The text was left in the source language; translate it into %s", req.TargetLang),
+			Message:    "The translation is still in the source language",
+		})
+		return failures
+	}
+
+	ratio := float64(countLetters(translated).total) / expectedLetters(letters, req.TargetLang)
+	switch {
+	case ratio < minLengthRatio:
+		failures = append(failures, checkFailure{
+			Kind:       "length",
+			Correction: "The translation is much shorter than the source; translate every sentence without leaving anything out",
+			Message:    fmt.Sprintf("The translation is suspiciously short (%.0f%% of the expected length)", ratio*100),
+		})
+	case ratio > maxLengthRatio:
+		failures = append(failures, checkFailure{
+			Kind:       "length",
+			Correction: "The translation is much longer than the source; translate the text only, without explanations or additions",
+			Message:    fmt.Sprintf("The translation is suspiciously long (%.0f%% of the expected length)", ratio*100),
+		})
+	}
+
+	return failures
+}
+
+// findPreamble returns the remark text in lang starts with, if any.
+func findPreamble(text, lang string) string {
+	for _, patterns := range [][]*regexp.Regexp{preamblePatterns, localPreamblePatterns[tm.NormalizeLang(lang)]} {
+		for _, pattern := range patterns {
+			if match := pattern.FindString(text); match != "" {
+				return strings.TrimSpace(match)
+			}
+		}
+	}
+	return ""
+}
+
+// letterCount counts the letters of a text by script.
+type letterCount struct {
+	total    int
+	byScript map[scriptClass]int
+}
+
+func countLetters(text string) letterCount {
+	count := letterCount{byScript: make(map[scriptClass]int)}
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		count.total++
+		count.byScript[classify(r)]++
+	}
+	return count
+}
+
+// script returns the script most letters are written in.
+func (c letterCount) script() scriptClass {
+	best := classLatin
+	for class, n := range c.byScript {
+		if n > c.byScript[best] {
+			best = class
+		}
+	}
+	return best
+}
+
+// targetScript returns the script lang is written in.
+func targetScript(lang string) scriptClass {
+	if class, ok := languageScripts[tm.NormalizeLang(lang)]; ok {
+		return class
+	}
+	return classLatin
+}
+
+// expectedLetters estimates the letters of a translation into targetLang of a
+// source with the given letters, which only changes between CJK and
+// alphabetic scripts.
+func expectedLetters(source letterCount, targetLang string) float64 {
+	cjk := float64(source.byScript[classCJK])
+	alphabetic := float64(source.total) - cjk
+	if targetScript(targetLang) == classCJK {
+		return cjk + alphabetic*cjkCharsPerLetter
+	}
+	return cjk/cjkCharsPerLetter + alphabetic
+}
+
+// untranslated reports whether translated is still the source text: the same
+// text, mostly written in the script of the source when the target language
+// uses another one, or mostly made of the words of the source otherwise.
+func untranslated(source, translated, targetLang string) bool {
+	if strings.EqualFold(cache.Normalize(source), cache.Normalize(translated)) {
+		return true
+	}
+
+	sourceScript := countLetters(source).script()
+	if sourceScript != targetScript(targetLang) {
+		letters := countLetters(translated)
+		return letters.total > 0 && float64(letters.byScript[sourceScript]) > float64(letters.total)/2
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(translated), func(r rune) bool { return !unicode.IsLetter(r) })
+	if len(words) < minCheckedWords {
+		return false
+	}
+	sourceWords := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(source), func(r rune) bool { return !unicode.IsLetter(r) }) {
+		sourceWords[word] = true
+	}
+	shared := 0
+	for _, word := range words {
+		if sourceWords[word] {
+			shared++
+		}
+	}
+	return float64(shared) > float64(len(words))*maxSharedWords
+}
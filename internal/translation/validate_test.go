@@ -0,0 +1,229 @@
+package translation
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestCheckTranslation(t *testing.T) {
+	source := "The old lighthouse keeper climbed the stairs every evening to light the lamp."
+
+	testCases := []struct {
+		name       string
+		req        SegmentRequest
+		translated string
+		truncated  bool
+		want       []string
+	}{
+		{
+			name:       "Clean",
+			translated: "Le vieux gardien du phare montait l'escalier chaque soir pour allumer la lampe.",
+		},
+		{
+			name:       "Empty",
+			translated: " <1/> ",
+			want:       []string{"empty"},
+		},
+		{
+			name:       "Left as it was",
+			translated: source,
+			want:       []string{"untranslated"},
+		},
+		{
+			name:       "Mostly the source words",
+			translated: "The old lighthouse keeper climbed the stairs every evening to light la lampe.",
+			want:       []string{"untranslated"},
+		},
+		{
+			name:       "Source script",
+			req:        SegmentRequest{TargetLang: "fa"},
+			translated: "The old lighthouse keeper climbed the stairs every evening, " + "نگهبان",
+			want:       []string{"untranslated"},
+		},
+		{
+			name:       "Other script",
+			req:        SegmentRequest{TargetLang: "ja"},
+			translated: "年老いた灯台守は毎晩階段を上ってランプを灯した。",
+		},
+		{
+			name:       "Too short",
+			translated: "Le gardien.",
+			want:       []string{"length"},
+		},
+		{
+			name:       "Too long",
+			translated: strings.Repeat("Le vieux gardien du phare montait l'escalier chaque soir. ", 5),
+			want:       []string{"length"},
+		},
+		{
+			name:       "Preamble",
+			translated: "Sure! Here is the translation:\nLe vieux gardien du phare montait l'escalier chaque soir pour allumer la lampe.",
+			want:       []string{"preamble"},
+		},
+		{
+			name:       "Labelled",
+			translated: "**Translation:** Le vieux gardien du phare montait l'escalier chaque soir pour allumer la lampe.",
+			want:       []string{"preamble"},
+		},
+		{
+			name:       "Sentence opening with Of course",
+			req:        SegmentRequest{Text: "Bien sûr, il ne dit rien.\nLe lendemain, il monta au phare.", SourceLang: "fr", TargetLang: "en"},
+			translated: "Of course, he said nothing.\nThe next day he climbed to the lighthouse.",
+		},
+		{
+			name:       "Sentence opening with Certainly",
+			req:        SegmentRequest{Text: "Certes, la maison était vieille : personne n'y vivait plus.", SourceLang: "fr", TargetLang: "en"},
+			translated: "Certainly, the house was old: nobody lived there anymore.",
+		},
+		{
+			name:       "Sentence opening with Here is",
+			req:        SegmentRequest{Text: "Voici la clé : garde-la bien, et ne la montre à personne.", SourceLang: "fr", TargetLang: "en"},
+			translated: "Here is the key: keep it safe, and show it to no one.",
+		},
+		{
+			name:       "Spanish preamble",
+			req:        SegmentRequest{TargetLang: "es"},
+			translated: "¡Claro! Aquí tienes la traducción:\nEl viejo farero subía las escaleras cada tarde para encender la lámpara.",
+			want:       []string{"preamble"},
+		},
+		{
+			name:       "Persian preamble",
+			req:        SegmentRequest{TargetLang: "fa"},
+			translated: "حتماً! این هم ترجمه متن:\nنگهبان پیر فانوس دریایی هر شب از پله‌ها بالا می‌رفت تا چراغ را روشن کند.",
+			want:       []string{"preamble"},
+		},
+		{
+			name:       "Chinese preamble",
+			req:        SegmentRequest{TargetLang: "zh"},
+			translated: "好的，以下是翻译：年老的灯塔看守人每天晚上都爬上楼梯去点灯。",
+			want:       []string{"preamble"},
+		},
+		{
+			name:       "Spanish sentence opening with Aquí tienes",
+			req:        SegmentRequest{Text: "Here is the key: keep it safe, and show it to no one.", TargetLang: "es"},
+			translated: "Aquí tienes la llave: guárdala bien y no se la enseñes a nadie.",
+		},
+		{
+			name:       "Persian sentence about a translation",
+			req:        SegmentRequest{Text: "The translation of the book took years: nobody had expected it.", TargetLang: "fa"},
+			translated: "ترجمه این کتاب سال‌ها طول کشید و هیچ‌کس چنین انتظاری نداشت.",
+		},
+		{
+			name:       "Truncated",
+			translated: "Le vieux gardien du phare montait l'escalier chaque soir pour",
+			truncated:  true,
+			want:       []string{"truncated"},
+		},
+		{
+			name:       "Short segments are not judged",
+			req:        SegmentRequest{Text: "Chapter One"},
+			translated: "Chapter One",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := tc.req
+			if req.Text == "" {
+				req.Text = source
+			}
+			if req.SourceLang == "" {
+				req.SourceLang = "en"
+			}
+			if req.TargetLang == "" {
+				req.TargetLang = "fr"
+			}
+
+			failures := checkTranslation(req, &SegmentResult{Text: tc.translated, Truncated: tc.truncated})
+			var kinds []string
+			for _, failure := range failures {
+				kinds = append(kinds, failure.Kind)
+			}
+			if strings.Join(kinds, ",") != strings.Join(tc.want, ",") {
+				t.Errorf("checkTranslation() = %v, want %v", failures, tc.want)
+			}
+		})
+	}
+}
+
+// truncatingBackend cuts off the reply to every prompt that truncate matches,
+// replying with cut instead.
+type truncatingBackend struct {
+	truncate func(prompt string) bool
+	cut      string
+}
+
+func (b *truncatingBackend) createCompletion(ctx context.Context, req chatRequest) (*completion, error) {
+	prompt := req.Messages[len(req.Messages)-1].Content
+	if b.truncate(prompt) {
+		return &completion{Content: b.cut, FinishReason: "length", Usage: Usage{Requests: 1}}, nil
+	}
+	return &completion{Content: "whole", FinishReason: "stop", Usage: Usage{Requests: 1}}, nil
+}
+
+func TestTruncatedChunksAreSplit(t *testing.T) {
+	provider := &llmTranslator{
+		name: "test",
+		backend: &truncatingBackend{truncate: func(prompt string) bool {
+			return strings.Contains(prompt, "Alpha") && strings.Contains(prompt, "Omega")
+		}, cut: "cut"},
+		logger: logrus.New(),
+	}
+
+	result, err := provider.TranslateSegment(context.Background(), SegmentRequest{Text: "Alpha comes first. Omega comes last.", SourceLang: "en", TargetLang: "fr", Format: FormatText})
+	if err != nil {
+		t.Fatalf("TranslateSegment() error = %v", err)
+	}
+	if result.Truncated || strings.Contains(result.Text, "cut") || result.Usage.Requests < 3 {
+		t.Errorf("result = %+v, want the pieces translated after the cut off reply", result)
+	}
+
+	provider.backend = &truncatingBackend{truncate: func(string) bool { return true }, cut: "cut"}
+	result, err = provider.TranslateSegment(context.Background(), SegmentRequest{Text: "A", SourceLang: "en", TargetLang: "fr", Format: FormatText})
+	if err != nil {
+		t.Fatalf("TranslateSegment() error = %v", err)
+	}
+	if !result.Truncated || result.Text != "cut" {
+		t.Errorf("result = %+v, want the cut off reply flagged", result)
+	}
+
+	if _, err := provider.TranslateBatch(context.Background(), []SegmentRequest{{Text: "Alpha"}, {Text: "Omega"}}); !errors.Is(err, ErrTruncated) {
+		t.Errorf("TranslateBatch() error = %v, want ErrTruncated", err)
+	}
+}
+
+func TestTruncatedStructuredReply(t *testing.T) {
+	testCases := []struct {
+		name string
+		cut  string
+		want string
+	}{
+		{name: "In the translation", cut: `{"translation": "Le vieux \"gardien\" mon`, want: `Le vieux "gardien" mon`},
+		{name: "In an escape", cut: `{"translation": "Le phare \u00e`, want: "Le phare "},
+		{name: "Before the translation", cut: `{"transl`, want: "A"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			provider := &llmTranslator{
+				name:       "test",
+				backend:    &truncatingBackend{truncate: func(string) bool { return true }, cut: tc.cut},
+				logger:     logrus.New(),
+				structured: true,
+			}
+
+			// A single letter cannot be split any further
+			result, err := provider.TranslateSegment(context.Background(), SegmentRequest{Text: "A", SourceLang: "en", TargetLang: "fr", Format: FormatText})
+			if err != nil {
+				t.Fatalf("TranslateSegment() error = %v", err)
+			}
+			if !result.Truncated || result.Text != tc.want {
+				t.Errorf("result = %+v, want %q flagged as truncated", result, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package translation
+
+import (
+	"bytes"
+	"embed"
+	"encoding/base64"
+	"fmt"
+	"io/fs"
+	"path"
+	"strconv"
+
+	"github.com/pkoukk/tiktoken-go"
+)
+
+// vocabFiles holds the BPE vocabularies of the OpenAI encodings, fetched into
+// the vocab directory with `make vocab`. An encoding whose file is missing is
+// estimated instead of encoded.
+//
+//go:embed vocab
+var vocabFiles embed.FS
+
+func init() {
+	// tiktoken downloads the vocabularies by default; ours never leave the binary
+	tiktoken.SetBpeLoader(&vocabLoader{files: vocabFiles})
+}
+
+// vocabLoader loads the vocabularies tiktoken asks for from files instead of
+// the network.
+type vocabLoader struct {
+	files fs.FS
+}
+
+// LoadTiktokenBpe reads the vocabulary named by the base of url, such as
+// cl100k_base.tiktoken.
+func (l *vocabLoader) LoadTiktokenBpe(url string) (map[string]int, error) {
+	name := path.Base(url)
+	data, err := fs.ReadFile(l.files, path.Join("vocab", name))
+	if err != nil {
+		return nil, fmt.Errorf("vocabulary %s is not bundled: %w", name, err)
+	}
+	ranks, err := parseVocabulary(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse vocabulary %s: %w", name, err)
+	}
+	return ranks, nil
+}
+
+// parseVocabulary parses a .tiktoken file: one base64 encoded token and its
+// rank per line.
+func parseVocabulary(data []byte) (map[string]int, error) {
+	ranks := make(map[string]int)
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		fields := bytes.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected a token and a rank", i+1)
+		}
+		token, err := base64.StdEncoding.DecodeString(string(fields[0]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		rank, err := strconv.Atoi(string(fields[1]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		ranks[string(token)] = rank
+	}
+	return ranks, nil
+}
@@ -0,0 +1,68 @@
+package translation
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/pkoukk/tiktoken-go"
+)
+
+func TestVocabularyTokenizer(t *testing.T) {
+	// Every single byte, then the merges "th", "the" and "at"
+	var vocabulary strings.Builder
+	tokens := []string{"th", "the", "at"}
+	for b := 0; b < 256; b++ {
+		fmt.Fprintf(&vocabulary, "%s %d\n", base64.StdEncoding.EncodeToString([]byte{byte(b)}), b)
+	}
+	for i, token := range tokens {
+		fmt.Fprintf(&vocabulary, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(token)), 256+i)
+	}
+
+	loader := &vocabLoader{files: fstest.MapFS{"vocab/test.tiktoken": {Data: []byte(vocabulary.String())}}}
+	ranks, err := loader.LoadTiktokenBpe("https://example.com/encodings/test.tiktoken")
+	if err != nil {
+		t.Fatalf("LoadTiktokenBpe() error = %v", err)
+	}
+	if len(ranks) != 259 || ranks["the"] != 257 {
+		t.Fatalf("LoadTiktokenBpe() = %d ranks, want 259", len(ranks))
+	}
+	if _, err := loader.LoadTiktokenBpe("https://example.com/encodings/missing.tiktoken"); err == nil {
+		t.Errorf("LoadTiktokenBpe() of a missing vocabulary succeeded")
+	}
+
+	core, err := tiktoken.NewCoreBPE(ranks, map[string]int{}, `\s?\w+|\s+|[^\s\w]+`)
+	if err != nil {
+		t.Fatalf("NewCoreBPE() error = %v", err)
+	}
+	tk := &tokenizer{name: "test", charsPerToken: cl100kCharsPerToken, bpe: tiktoken.NewTiktoken(core, &tiktoken.Encoding{Name: "test"}, nil)}
+	tk.once.Do(func() {})
+
+	// "the" + " " "c" "at" + "!"
+	if got := tk.Count("the cat!"); got != 5 {
+		t.Errorf("Count() = %d, want 5", got)
+	}
+
+	if tokenizerFor("llama3") != estimateTokenizer || estimateTokenizer.encoder() != nil {
+		t.Errorf("Expected unknown models to be estimated")
+	}
+	if got, want := estimateTokenizer.Count("the cat!"), estimateTokenizer.estimate("the cat!"); got != want {
+		t.Errorf("Count() = %d, want the estimate %d", got, want)
+	}
+}
+
+func TestBundledVocabularies(t *testing.T) {
+	if cl100kTokenizer.encoder() == nil || o200kTokenizer.encoder() == nil {
+		t.Skip("The vocabularies are not bundled; run make vocab")
+	}
+
+	if got := cl100kTokenizer.Count("tiktoken is great!"); got != 6 {
+		t.Errorf("cl100k Count() = %d, want 6", got)
+	}
+	persian := "روباه قهوه‌ای سریع از روی سگ تنبل می‌پرد."
+	if o200kTokenizer.Count(persian) >= cl100kTokenizer.Count(persian) {
+		t.Errorf("Expected o200k to need fewer tokens than cl100k for Persian")
+	}
+}
@@ -0,0 +1,245 @@
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Record is the usage of one LLM request. Requests made outside a book
+// translation have no book, job or chapter.
+type Record struct {
+	Time             time.Time `json:"time"`
+	BookID           string    `json:"book_id,omitempty"`
+	JobID            string    `json:"job_id,omitempty"`
+	ChapterID        string    `json:"chapter_id,omitempty"`
+	Provider         string    `json:"provider"`
+	Model            string    `json:"model"`
+	RequestType      string    `json:"request_type"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	TotalTokens      int       `json:"total_tokens"`
+	Cost             float64   `json:"cost"`
+}
+
+// Totals adds up the requests, tokens and cost of several records.
+type Totals struct {
+	Requests         int     `json:"requests"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	TotalTokens      int     `json:"total_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// Add accumulates other into t.
+func (t *Totals) Add(other Totals) {
+	t.Requests += other.Requests
+	t.PromptTokens += other.PromptTokens
+	t.CompletionTokens += other.CompletionTokens
+	t.TotalTokens += other.TotalTokens
+	t.Cost += other.Cost
+}
+
+// Totals returns the record as the totals of a single request.
+func (r Record) Totals() Totals {
+	return Totals{
+		Requests:         1,
+		PromptTokens:     r.PromptTokens,
+		CompletionTokens: r.CompletionTokens,
+		TotalTokens:      r.TotalTokens,
+		Cost:             r.Cost,
+	}
+}
+
+// Filter selects records for a report. Empty fields match every record; To is
+// exclusive.
+type Filter struct {
+	BookID string
+	JobID  string
+	From   time.Time
+	To     time.Time
+}
+
+func (f Filter) match(r Record) bool {
+	return (f.BookID == "" || r.BookID == f.BookID) &&
+		(f.JobID == "" || r.JobID == f.JobID) &&
+		(f.From.IsZero() || !r.Time.Before(f.From)) &&
+		(f.To.IsZero() || r.Time.Before(f.To))
+}
+
+// GroupBy lists the keys a report can be grouped by.
+var GroupBy = map[string]func(Record) string{
+	"book":         func(r Record) string { return r.BookID },
+	"job":          func(r Record) string { return r.JobID },
+	"chapter":      func(r Record) string { return r.BookID + "/" + r.ChapterID },
+	"provider":     func(r Record) string { return r.Provider },
+	"model":        func(r Record) string { return r.Model },
+	"request_type": func(r Record) string { return r.RequestType },
+	"day":          func(r Record) string { return r.Time.Local().Format("2006-01-02") },
+}
+
+// Group is the totals of the records sharing a key.
+type Group struct {
+	Key string `json:"key"`
+	Totals
+}
+
+// Report is the totals of the records matching a filter, optionally broken
+// down into groups sorted by key.
+type Report struct {
+	Totals
+	GroupBy string  `json:"group_by,omitempty"`
+	Groups  []Group `json:"groups,omitempty"`
+}
+
+// Ledger keeps a record of every LLM request in a JSON-lines file. Records
+// are only ever appended, so the file is the full history to bill from.
+type Ledger struct {
+	mu      sync.RWMutex
+	path    string
+	file    *os.File
+	records []Record
+	// books keeps the totals of every book, which are read far more often
+	// than full reports
+	books map[string]Totals
+}
+
+// Open loads the ledger at path, creating the file and its directory if needed.
+func Open(path string) (*Ledger, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create usage ledger directory: %w", err)
+	}
+
+	l := &Ledger{path: path, books: make(map[string]Totals)}
+	if err := l.replay(); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open usage ledger: %w", err)
+	}
+	l.file = file
+	return l, nil
+}
+
+func (l *Ledger) replay() error {
+	file, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open usage ledger: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var rec Record
+		// A torn last line from an interrupted write is skipped
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil || rec.Time.IsZero() {
+			continue
+		}
+		l.append(rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read usage ledger: %w", err)
+	}
+	return nil
+}
+
+// Add appends a record to the ledger.
+func (l *Ledger) Add(rec Record) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, err := l.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write usage record: %w", err)
+	}
+	l.append(rec)
+	return nil
+}
+
+func (l *Ledger) append(rec Record) {
+	l.records = append(l.records, rec)
+	if rec.BookID != "" {
+		totals := l.books[rec.BookID]
+		totals.Add(rec.Totals())
+		l.books[rec.BookID] = totals
+	}
+}
+
+// BookTotals returns the totals of every request made for a book.
+func (l *Ledger) BookTotals(bookID string) Totals {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.books[bookID]
+}
+
+// Report adds up the records matching filter and, unless groupBy is empty,
+// breaks them down by one of the keys of GroupBy.
+func (l *Ledger) Report(filter Filter, groupBy string) (*Report, error) {
+	key, ok := GroupBy[groupBy]
+	if groupBy != "" && !ok {
+		return nil, fmt.Errorf("unknown group %q", groupBy)
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	report := &Report{GroupBy: groupBy}
+	groups := make(map[string]*Group)
+	for _, rec := range l.records {
+		if !filter.match(rec) {
+			continue
+		}
+		report.Add(rec.Totals())
+		if key == nil {
+			continue
+		}
+
+		name := key(rec)
+		group, exists := groups[name]
+		if !exists {
+			group = &Group{Key: name}
+			groups[name] = group
+		}
+		group.Add(rec.Totals())
+	}
+
+	for _, group := range groups {
+		report.Groups = append(report.Groups, *group)
+	}
+	sort.Slice(report.Groups, func(i, j int) bool {
+		return report.Groups[i].Key < report.Groups[j].Key
+	})
+	return report, nil
+}
+
+// Path returns the location of the ledger.
+func (l *Ledger) Path() string {
+	return l.path
+}
+
+// Len returns the number of records.
+func (l *Ledger) Len() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return len(l.records)
+}
+
+func (l *Ledger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
@@ -0,0 +1,102 @@
+package usage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLedgerReport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.jsonl")
+
+	ledger, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	day := time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)
+	records := []Record{
+		{Time: day, BookID: "book1", JobID: "job1", ChapterID: "ch1", Model: "gpt-4o", PromptTokens: 100, CompletionTokens: 50, TotalTokens: 150, Cost: 0.75},
+		{Time: day, BookID: "book1", JobID: "job1", ChapterID: "ch2", Model: "gpt-4o", PromptTokens: 200, CompletionTokens: 100, TotalTokens: 300, Cost: 1.5},
+		{Time: day.AddDate(0, 0, 1), BookID: "book1", JobID: "job2", ChapterID: "ch1", Model: "gpt-4o", PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15, Cost: 0.25},
+		{Time: day.AddDate(0, 0, 1), BookID: "book2", JobID: "job3", Model: "gpt-4o-mini", PromptTokens: 40, TotalTokens: 40},
+	}
+	for _, rec := range records {
+		if err := ledger.Add(rec); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+	if err := ledger.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// A torn line left by a crash is ignored
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"time":"2026-03-02T12:00:00Z","book_id":"bo`)
+	file.Close()
+
+	ledger, err = Open(path)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer ledger.Close()
+
+	testCases := []struct {
+		name    string
+		filter  Filter
+		groupBy string
+		want    Totals
+		groups  []string
+	}{
+		{
+			name:   "everything",
+			want:   Totals{Requests: 4, PromptTokens: 350, CompletionTokens: 155, TotalTokens: 505, Cost: 2.5},
+			groups: nil,
+		},
+		{
+			name:    "book by job",
+			filter:  Filter{BookID: "book1"},
+			groupBy: "job",
+			want:    Totals{Requests: 3, PromptTokens: 310, CompletionTokens: 155, TotalTokens: 465, Cost: 2.5},
+			groups:  []string{"job1", "job2"},
+		},
+		{
+			name:    "one day by chapter",
+			filter:  Filter{From: day.Add(-time.Hour), To: day.Add(time.Hour)},
+			groupBy: "chapter",
+			want:    Totals{Requests: 2, PromptTokens: 300, CompletionTokens: 150, TotalTokens: 450, Cost: 2.25},
+			groups:  []string{"book1/ch1", "book1/ch2"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			report, err := ledger.Report(tc.filter, tc.groupBy)
+			if err != nil {
+				t.Fatalf("Report() error = %v", err)
+			}
+			if report.Totals != tc.want {
+				t.Errorf("totals = %+v, want %+v", report.Totals, tc.want)
+			}
+			if len(report.Groups) != len(tc.groups) {
+				t.Fatalf("groups = %+v, want %v", report.Groups, tc.groups)
+			}
+			for i, key := range tc.groups {
+				if report.Groups[i].Key != key {
+					t.Errorf("group %d = %s, want %s", i, report.Groups[i].Key, key)
+				}
+			}
+		})
+	}
+
+	if got := ledger.BookTotals("book1"); got != testCases[1].want {
+		t.Errorf("BookTotals() = %+v, want %+v", got, testCases[1].want)
+	}
+
+	if _, err := ledger.Report(Filter{}, "colour"); err == nil {
+		t.Errorf("Report() with an unknown group succeeded")
+	}
+}